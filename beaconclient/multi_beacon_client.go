@@ -7,6 +7,8 @@ import (
 	"sync"
 
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/metrics"
 	"github.com/sirupsen/logrus"
 	uberatomic "go.uber.org/atomic"
 )
@@ -24,11 +26,12 @@ type IMultiBeaconClient interface {
 	// FetchValidators returns all active and pending validators from the beacon node
 	FetchValidators(headSlot uint64) (map[types.PubkeyHex]ValidatorResponseEntry, error)
 	GetProposerDuties(epoch uint64) (*ProposerDutiesResponse, error)
-	PublishBlock(block *types.SignedBeaconBlock) (code int, err error)
+	PublishBlock(block *common.SignedBeaconBlock) (code int, err error)
 	GetGenesis() (*GetGenesisResponse, error)
 	GetSpec() (spec *GetSpecResponse, err error)
 	GetBlock(blockID string) (block *GetBlockResponse, err error)
 	GetRandao(slot uint64) (spec *GetRandaoResponse, err error)
+	GetWithdrawals(slot uint64) (spec *GetWithdrawalsResponse, err error)
 }
 
 // IBeaconInstance is the interface for a single beacon client instance
@@ -39,11 +42,12 @@ type IBeaconInstance interface {
 	FetchValidators(headSlot uint64) (map[types.PubkeyHex]ValidatorResponseEntry, error)
 	GetProposerDuties(epoch uint64) (*ProposerDutiesResponse, error)
 	GetURI() string
-	PublishBlock(block *types.SignedBeaconBlock) (code int, err error)
+	PublishBlock(block *common.SignedBeaconBlock) (code int, err error)
 	GetGenesis() (*GetGenesisResponse, error)
 	GetSpec() (spec *GetSpecResponse, err error)
 	GetBlock(blockID string) (*GetBlockResponse, error)
 	GetRandao(slot uint64) (spec *GetRandaoResponse, err error)
+	GetWithdrawals(slot uint64) (spec *GetWithdrawalsResponse, err error)
 }
 
 type MultiBeaconClient struct {
@@ -89,6 +93,7 @@ func (c *MultiBeaconClient) BestSyncStatus() (*SyncStatusPayloadData, error) {
 			syncStatus, err := instance.SyncStatus()
 			if err != nil {
 				log.WithError(err).Error("failed to get sync status")
+				metrics.BeaconNodeErrors.WithLabelValues(instance.GetURI(), "syncStatus").Inc()
 				return
 			}
 
@@ -143,6 +148,7 @@ func (c *MultiBeaconClient) FetchValidators(headSlot uint64) (map[types.PubkeyHe
 		validators, err := client.FetchValidators(headSlot)
 		if err != nil {
 			log.WithError(err).Error("failed to fetch validators")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "fetchValidators").Inc()
 			continue
 		}
 
@@ -167,6 +173,7 @@ func (c *MultiBeaconClient) GetProposerDuties(epoch uint64) (*ProposerDutiesResp
 		duties, err := client.GetProposerDuties(epoch)
 		if err != nil {
 			log.WithError(err).Error("failed to get proposer duties")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "getProposerDuties").Inc()
 			continue
 		}
 
@@ -195,10 +202,10 @@ func (c *MultiBeaconClient) beaconInstancesByLastResponse() []IBeaconInstance {
 }
 
 // PublishBlock publishes the signed beacon block via https://ethereum.github.io/beacon-APIs/#/ValidatorRequiredApi/publishBlock
-func (c *MultiBeaconClient) PublishBlock(block *types.SignedBeaconBlock) (code int, err error) {
+func (c *MultiBeaconClient) PublishBlock(block *common.SignedBeaconBlock) (code int, err error) {
 	log := c.log.WithFields(logrus.Fields{
-		"slot":      block.Message.Slot,
-		"blockHash": block.Message.Body.ExecutionPayload.BlockHash.String(),
+		"slot":      block.Slot(),
+		"blockHash": block.BlockHash(),
 	})
 
 	clients := c.beaconInstancesByLastResponse()
@@ -208,6 +215,7 @@ func (c *MultiBeaconClient) PublishBlock(block *types.SignedBeaconBlock) (code i
 
 		if code, err = client.PublishBlock(block); err != nil {
 			log.WithField("statusCode", code).WithError(err).Warn("failed to publish block")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "publishBlock").Inc()
 			continue
 		}
 
@@ -226,6 +234,7 @@ func (c *MultiBeaconClient) GetGenesis() (genesisInfo *GetGenesisResponse, err e
 		log := c.log.WithField("uri", client.GetURI())
 		if genesisInfo, err = client.GetGenesis(); err != nil {
 			log.WithError(err).Warn("failed to get genesis info")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "getGenesis").Inc()
 			continue
 		}
 
@@ -243,6 +252,7 @@ func (c *MultiBeaconClient) GetSpec() (spec *GetSpecResponse, err error) {
 		log := c.log.WithField("uri", client.GetURI())
 		if spec, err = client.GetSpec(); err != nil {
 			log.WithError(err).Warn("failed to get spec")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "getSpec").Inc()
 			continue
 		}
 
@@ -260,6 +270,7 @@ func (c *MultiBeaconClient) GetBlock(blockID string) (block *GetBlockResponse, e
 		log := c.log.WithField("uri", client.GetURI())
 		if block, err = client.GetBlock(blockID); err != nil {
 			log.WithField("blockID", blockID).WithError(err).Warn("failed to get block")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "getBlock").Inc()
 			continue
 		}
 
@@ -277,6 +288,7 @@ func (c *MultiBeaconClient) GetRandao(slot uint64) (randaoResp *GetRandaoRespons
 		log := c.log.WithField("uri", client.GetURI())
 		if randaoResp, err = client.GetRandao(slot); err != nil {
 			log.WithField("slot", slot).WithError(err).Warn("failed to get randao")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "getRandao").Inc()
 			continue
 		}
 
@@ -286,3 +298,21 @@ func (c *MultiBeaconClient) GetRandao(slot uint64) (randaoResp *GetRandaoRespons
 	c.log.WithField("slot", slot).WithError(err).Warn("failed to get randao from any CL node")
 	return nil, err
 }
+
+// GetWithdrawals - 3500/eth/v1/builder/states/<slot>/expected_withdrawals
+func (c *MultiBeaconClient) GetWithdrawals(slot uint64) (withdrawalsResp *GetWithdrawalsResponse, err error) {
+	clients := c.beaconInstancesByLastResponse()
+	for _, client := range clients {
+		log := c.log.WithField("uri", client.GetURI())
+		if withdrawalsResp, err = client.GetWithdrawals(slot); err != nil {
+			log.WithField("slot", slot).WithError(err).Warn("failed to get withdrawals")
+			metrics.BeaconNodeErrors.WithLabelValues(client.GetURI(), "getWithdrawals").Inc()
+			continue
+		}
+
+		return withdrawalsResp, nil
+	}
+
+	c.log.WithField("slot", slot).WithError(err).Warn("failed to get withdrawals from any CL node")
+	return nil, err
+}
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/attestantio/go-eth2-client/spec/capella"
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/r3labs/sse/v2"
 	"github.com/sirupsen/logrus"
 )
@@ -78,7 +80,15 @@ type ValidatorResponseEntry struct {
 }
 
 type ValidatorResponseValidatorData struct {
-	Pubkey string `json:"pubkey"`
+	Pubkey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Slashed               bool   `json:"slashed"`
+}
+
+// IsSlashed is true for slashed validators, which are still active (active_slashed) until their exit but can't
+// propose anymore
+func (v ValidatorResponseEntry) IsSlashed() bool {
+	return v.Validator.Slashed || v.Status == "active_slashed"
 }
 
 type AllValidatorsResponse struct {
@@ -86,6 +96,7 @@ type AllValidatorsResponse struct {
 }
 
 func fetchAllValidators(endpoint string, headSlot uint64) (*AllValidatorsResponse, error) {
+	// active includes active_slashed, so the validators have to be checked for slashing as well
 	uri := fmt.Sprintf("%s/eth/v1/beacon/states/%d/validators?status=active,pending", endpoint, headSlot)
 	// https://ethereum.github.io/beacon-APIs/#/Beacon/getStateValidators
 	vd := new(AllValidatorsResponse)
@@ -205,7 +216,7 @@ func (c *ProdBeaconInstance) GetURI() string {
 	return c.beaconURI
 }
 
-func (c *ProdBeaconInstance) PublishBlock(block *types.SignedBeaconBlock) (code int, err error) {
+func (c *ProdBeaconInstance) PublishBlock(block *common.SignedBeaconBlock) (code int, err error) {
 	uri := fmt.Sprintf("%s/eth/v1/beacon/blocks", c.beaconURI)
 	return fetchBeacon(http.MethodPost, uri, block, nil)
 }
@@ -256,3 +267,15 @@ func (c *ProdBeaconInstance) GetRandao(slot uint64) (randaoResp *GetRandaoRespon
 	_, err = fetchBeacon(http.MethodGet, uri, nil, resp)
 	return resp, err
 }
+
+type GetWithdrawalsResponse struct {
+	Data []*capella.Withdrawal
+}
+
+// GetWithdrawals returns the withdrawals expected in the block proposed on top of the given slot - /eth/v1/builder/states/<slot>/expected_withdrawals
+func (c *ProdBeaconInstance) GetWithdrawals(slot uint64) (withdrawalsResp *GetWithdrawalsResponse, err error) {
+	uri := fmt.Sprintf("%s/eth/v1/builder/states/%d/expected_withdrawals?proposal_slot=%d", c.beaconURI, slot, slot+1)
+	resp := new(GetWithdrawalsResponse)
+	_, err = fetchBeacon(http.MethodGet, uri, nil, resp)
+	return resp, err
+}
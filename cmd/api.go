@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"net/url"
 	"os"
 	"os/signal"
@@ -14,26 +15,35 @@ import (
 	"github.com/flashbots/mev-boost-relay/database"
 	"github.com/flashbots/mev-boost-relay/datastore"
 	"github.com/flashbots/mev-boost-relay/services/api"
+	"github.com/flashbots/mev-boost-relay/tracing"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
-	apiDefaultListenAddr = common.GetEnv("LISTEN_ADDR", "localhost:9062")
-	apiDefaultBlockSim   = common.GetEnv("BLOCKSIM_URI", "http://localhost:8545")
-	apiDefaultSecretKey  = common.GetEnv("SECRET_KEY", "")
-	apiDefaultLogTag     = os.Getenv("LOG_TAG")
+	apiDefaultListenAddr       = common.GetEnv("LISTEN_ADDR", "localhost:9062")
+	apiDefaultBlockSim         = common.GetSliceEnv("BLOCKSIM_URI", []string{"http://localhost:8545"})
+	apiDefaultBlockSimHighPrio = common.GetSliceEnv("BLOCKSIM_URI_HIGH_PRIO", nil)
+	apiDefaultSecretKey        = common.GetEnv("SECRET_KEY", "")
+	apiDefaultLogTag           = os.Getenv("LOG_TAG")
+	apiDefaultOTLPEndpoint     = common.GetEnv("OTLP_ENDPOINT", "")
+	apiDefaultRegPolicyFile    = common.GetEnv("REGISTRATION_POLICY_FILE", "")
 
 	apiDefaultPprofEnabled       = os.Getenv("PPROF") == "1"
 	apiDefaultInternalAPIEnabled = os.Getenv("ENABLE_INTERNAL_API") == "1"
 
-	apiListenAddr   string
-	apiPprofEnabled bool
-	apiSecretKey    string
-	apiBlockSimURL  string
-	apiDebug        bool
-	apiInternalAPI  bool
-	apiLogTag       string
+	apiListenAddr           string
+	apiPprofEnabled         bool
+	apiSecretKey            string
+	apiBlockSimURLs         []string
+	apiHighPrioBlockSimURLs []string
+	apiRegPolicyFile        string
+	apiDebug                bool
+	apiInternalAPI          bool
+	apiLogTag               string
+
+	apiOTLPEndpoint    string
+	apiOTLPSampleRatio float64
 )
 
 func init() {
@@ -48,11 +58,16 @@ func init() {
 	apiCmd.Flags().StringVar(&redisURI, "redis-uri", defaultRedisURI, "redis uri")
 	apiCmd.Flags().StringVar(&postgresDSN, "db", defaultPostgresDSN, "PostgreSQL DSN")
 	apiCmd.Flags().StringVar(&apiSecretKey, "secret-key", apiDefaultSecretKey, "secret key for signing bids")
-	apiCmd.Flags().StringVar(&apiBlockSimURL, "blocksim", apiDefaultBlockSim, "URL for block simulator")
+	apiCmd.Flags().StringSliceVar(&apiBlockSimURLs, "blocksim", apiDefaultBlockSim, "URLs of the block simulation nodes")
+	apiCmd.Flags().StringSliceVar(&apiHighPrioBlockSimURLs, "blocksim-high-prio", apiDefaultBlockSimHighPrio, "URLs of the block simulation nodes for high-prio builders (default uses --blocksim)")
 	apiCmd.Flags().StringVar(&network, "network", defaultNetwork, "Which network to use")
+	apiCmd.Flags().StringVar(&apiRegPolicyFile, "registration-policy", apiDefaultRegPolicyFile, "JSON file with the validator registration policy, reloaded on changes (disabled if empty)")
 
 	apiCmd.Flags().BoolVar(&apiPprofEnabled, "pprof", apiDefaultPprofEnabled, "enable pprof API")
 	apiCmd.Flags().BoolVar(&apiInternalAPI, "internal-api", apiDefaultInternalAPIEnabled, "enable internal API (/internal/...)")
+	apiCmd.Flags().StringVar(&metricsAddr, "metrics-addr", defaultMetricsAddr, "listen address for the prometheus /metrics endpoint (disabled if empty)")
+	apiCmd.Flags().StringVar(&apiOTLPEndpoint, "otlp-endpoint", apiDefaultOTLPEndpoint, "OTLP/HTTP collector to export traces to, i.e. http://localhost:4318 (disabled if empty)")
+	apiCmd.Flags().Float64Var(&apiOTLPSampleRatio, "otlp-sample-ratio", 1, "ratio of block submissions to trace")
 }
 
 var apiCmd = &cobra.Command{
@@ -80,6 +95,20 @@ var apiCmd = &cobra.Command{
 		}
 		log.Infof("Using network: %s", networkInfo.Name)
 
+		// Set up tracing, spans are no-ops without an exporter
+		if apiOTLPEndpoint != "" {
+			shutdownTracing, err := tracing.Setup(context.Background(), "relay/api", Version, apiOTLPEndpoint, apiOTLPSampleRatio)
+			if err != nil {
+				log.WithError(err).Fatal("failed to set up tracing")
+			}
+			defer func() {
+				if err := shutdownTracing(context.Background()); err != nil {
+					log.WithError(err).Error("failed to flush traces")
+				}
+			}()
+			log.Infof("Exporting traces to %s", apiOTLPEndpoint)
+		}
+
 		// Connect to beacon clients and ensure it's synced
 		if len(beaconNodeURIs) == 0 {
 			log.Fatalf("no beacon endpoints specified")
@@ -116,14 +145,17 @@ var apiCmd = &cobra.Command{
 		}
 
 		opts := api.RelayAPIOpts{
-			Log:           log,
-			ListenAddr:    apiListenAddr,
-			BeaconClient:  beaconClient,
-			Datastore:     ds,
-			Redis:         redis,
-			DB:            db,
-			EthNetDetails: *networkInfo,
-			BlockSimURL:   apiBlockSimURL,
+			Log:                  log,
+			ListenAddr:           apiListenAddr,
+			BeaconClient:         beaconClient,
+			Datastore:            ds,
+			Redis:                redis,
+			DB:                   db,
+			EthNetDetails:        *networkInfo,
+			BlockSimURLs:         apiBlockSimURLs,
+			HighPrioBlockSimURLs: apiHighPrioBlockSimURLs,
+
+			RegistrationPolicyFile: apiRegPolicyFile,
 
 			ProposerAPI:     true,
 			BlockBuilderAPI: true,
@@ -167,6 +199,7 @@ var apiCmd = &cobra.Command{
 		}()
 
 		// Start the server
+		startMetricsServer(log)
 		log.Infof("Webserver starting on %s ...", apiListenAddr)
 		err = srv.StartServer()
 		if err != nil {
@@ -22,6 +22,7 @@ func init() {
 	housekeeperCmd.Flags().StringVar(&postgresDSN, "db", defaultPostgresDSN, "PostgreSQL DSN")
 
 	housekeeperCmd.Flags().StringVar(&network, "network", defaultNetwork, "Which network to use")
+	housekeeperCmd.Flags().StringVar(&metricsAddr, "metrics-addr", defaultMetricsAddr, "listen address for the prometheus /metrics endpoint (disabled if empty)")
 }
 
 var housekeeperCmd = &cobra.Command{
@@ -74,6 +75,7 @@ var housekeeperCmd = &cobra.Command{
 			BeaconClient: beaconClient,
 		}
 		service := housekeeper.NewHousekeeper(opts)
+		startMetricsServer(log)
 		log.Info("Starting housekeeper service...")
 		err = service.Start()
 		log.WithError(err).Fatalf("Failed to start housekeeper")
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"github.com/flashbots/mev-boost-relay/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// startMetricsServer serves the prometheus metrics in the background, if a metrics listen address is set
+func startMetricsServer(log *logrus.Entry) {
+	if metricsAddr == "" {
+		return
+	}
+
+	go func() {
+		log.Infof("Metrics server starting on %s ...", metricsAddr)
+		err := metrics.StartServer(metricsAddr)
+		if err != nil {
+			log.WithError(err).Error("metrics server error")
+		}
+	}()
+}
@@ -12,6 +12,7 @@ func init() {
 	toolCmd.AddCommand(tool.DataAPIExportBids)
 	toolCmd.AddCommand(tool.ArchiveExecutionPayloads)
 	toolCmd.AddCommand(tool.Migrate)
+	toolCmd.AddCommand(tool.DataAPIKeys)
 	rootCmd.AddCommand(toolCmd)
 }
 
@@ -0,0 +1,127 @@
+package tool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/flashbots/mev-boost-relay/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	apiKeyDescription string
+	apiKeyID          int64
+)
+
+func init() {
+	DataAPIKeys.PersistentFlags().StringVar(&postgresDSN, "db", defaultPostgresDSN, "PostgreSQL DSN")
+	dataAPIKeysCreate.Flags().StringVar(&apiKeyDescription, "description", "", "who the key is for")
+	_ = dataAPIKeysCreate.MarkFlagRequired("description")
+	dataAPIKeysRevoke.Flags().Int64Var(&apiKeyID, "id", 0, "id of the key to revoke")
+	_ = dataAPIKeysRevoke.MarkFlagRequired("id")
+
+	DataAPIKeys.AddCommand(dataAPIKeysCreate)
+	DataAPIKeys.AddCommand(dataAPIKeysList)
+	DataAPIKeys.AddCommand(dataAPIKeysRevoke)
+}
+
+var DataAPIKeys = &cobra.Command{
+	Use:   "data-api-keys",
+	Short: "manage the API keys of the data API",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Error: please use a valid subcommand")
+		_ = cmd.Help()
+	},
+}
+
+var dataAPIKeysCreate = &cobra.Command{
+	Use:   "create",
+	Short: "create an API key, which is only shown once",
+	Run: func(cmd *cobra.Command, args []string) {
+		// Connect to Postgres
+		dbURL, err := url.Parse(postgresDSN)
+		if err != nil {
+			log.WithError(err).Fatalf("couldn't read db URL")
+		}
+		log.Infof("Connecting to Postgres database at %s%s ...", dbURL.Host, dbURL.Path)
+		db, err := database.NewDatabaseService(postgresDSN)
+		if err != nil {
+			log.WithError(err).Fatalf("Failed to connect to Postgres database at %s%s", dbURL.Host, dbURL.Path)
+		}
+
+		keyBytes := make([]byte, 32)
+		if _, err = rand.Read(keyBytes); err != nil {
+			log.WithError(err).Fatal("failed to generate key")
+		}
+		key := hex.EncodeToString(keyBytes)
+
+		id, err := db.InsertDataAPIKey(database.HashDataAPIKey(key), apiKeyDescription)
+		if err != nil {
+			log.WithError(err).Fatal("failed to save key")
+		}
+		log.WithField("id", id).Info("created data API key, the API instances accept it after reloading the keys")
+		fmt.Println(key)
+	},
+}
+
+var dataAPIKeysList = &cobra.Command{
+	Use:   "list",
+	Short: "list the API keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		// Connect to Postgres
+		dbURL, err := url.Parse(postgresDSN)
+		if err != nil {
+			log.WithError(err).Fatalf("couldn't read db URL")
+		}
+		log.Infof("Connecting to Postgres database at %s%s ...", dbURL.Host, dbURL.Path)
+		db, err := database.NewDatabaseService(postgresDSN)
+		if err != nil {
+			log.WithError(err).Fatalf("Failed to connect to Postgres database at %s%s", dbURL.Host, dbURL.Path)
+		}
+
+		entries, err := db.GetDataAPIKeys()
+		if err != nil {
+			log.WithError(err).Fatal("failed to get keys")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED\tREVOKED\tDESCRIPTION")
+		for _, entry := range entries {
+			revokedAt := "-"
+			if entry.RevokedAt.Valid {
+				revokedAt = entry.RevokedAt.Time.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", entry.ID, entry.InsertedAt.Format("2006-01-02 15:04:05"), revokedAt, entry.Description)
+		}
+		_ = w.Flush()
+	},
+}
+
+var dataAPIKeysRevoke = &cobra.Command{
+	Use:   "revoke",
+	Short: "revoke an API key",
+	Run: func(cmd *cobra.Command, args []string) {
+		// Connect to Postgres
+		dbURL, err := url.Parse(postgresDSN)
+		if err != nil {
+			log.WithError(err).Fatalf("couldn't read db URL")
+		}
+		log.Infof("Connecting to Postgres database at %s%s ...", dbURL.Host, dbURL.Path)
+		db, err := database.NewDatabaseService(postgresDSN)
+		if err != nil {
+			log.WithError(err).Fatalf("Failed to connect to Postgres database at %s%s", dbURL.Host, dbURL.Path)
+		}
+
+		revoked, err := db.RevokeDataAPIKey(apiKeyID)
+		if err != nil {
+			log.WithError(err).Fatal("failed to revoke key")
+		} else if !revoked {
+			log.WithField("id", apiKeyID).Fatal("no unrevoked key with this id")
+		}
+		log.WithField("id", apiKeyID).Info("revoked data API key, the API instances reject it after reloading the keys")
+	},
+}
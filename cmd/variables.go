@@ -13,10 +13,12 @@ var (
 	defaultPostgresDSN = common.GetEnv("POSTGRES_DSN", "")
 	defaultLogJSON     = os.Getenv("LOG_JSON") != ""
 	defaultLogLevel    = common.GetEnv("LOG_LEVEL", "info")
+	defaultMetricsAddr = common.GetEnv("METRICS_ADDR", "")
 
 	beaconNodeURIs []string
 	redisURI       string
 	postgresDSN    string
+	metricsAddr    string
 
 	logJSON  bool
 	logLevel string
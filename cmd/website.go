@@ -42,6 +42,7 @@ func init() {
 	websiteCmd.Flags().StringVar(&websiteLinkBeaconchain, "link-beaconchain", websiteDefaultLinkBeaconchain, "url for beaconcha.in")
 	websiteCmd.Flags().StringVar(&websiteLinkEtherscan, "link-etherscan", websiteDefaultLinkEtherscan, "url for etherscan")
 	websiteCmd.Flags().StringVar(&websiteRelayURL, "relay-url", websiteDefaultRelayURL, "full url for the relay (https://pubkey@host)")
+	websiteCmd.Flags().StringVar(&metricsAddr, "metrics-addr", defaultMetricsAddr, "listen address for the prometheus /metrics endpoint (disabled if empty)")
 }
 
 var websiteCmd = &cobra.Command{
@@ -108,6 +109,7 @@ var websiteCmd = &cobra.Command{
 		}
 
 		// Start the server
+		startMetricsServer(log)
 		log.Infof("Webserver starting on %s ...", websiteListenAddr)
 		log.Fatal(srv.StartServer())
 	},
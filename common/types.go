@@ -3,6 +3,7 @@ package common
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
 	"strings"
 
@@ -50,9 +51,17 @@ type EthNetworkDetails struct {
 	GenesisForkVersionHex    string
 	GenesisValidatorsRootHex string
 	BellatrixForkVersionHex  string
+	CapellaForkVersionHex    string
+	CapellaForkEpoch         uint64
 
-	DomainBuilder        types.Domain
-	DomainBeaconProposer types.Domain
+	DomainBuilder                 types.Domain
+	DomainBeaconProposerBellatrix types.Domain
+	DomainBeaconProposerCapella   types.Domain
+}
+
+// IsCapella returns whether the given slot is at or after the Capella fork
+func (n *EthNetworkDetails) IsCapella(slot uint64) bool {
+	return slot/uint64(SlotsPerEpoch) >= n.CapellaForkEpoch
 }
 
 var (
@@ -63,12 +72,28 @@ var (
 	EthNetworkMainnet = "mainnet"
 )
 
+const (
+	CapellaForkVersionSepolia = "0x90000072"
+	CapellaForkVersionGoerli  = "0x03001020"
+	CapellaForkVersionMainnet = "0x03000000"
+
+	CapellaForkEpochSepolia = 56832
+	CapellaForkEpochGoerli  = 162304
+	CapellaForkEpochMainnet = 194048
+
+	// CapellaForkEpochNever is used for networks without a scheduled Capella fork
+	CapellaForkEpochNever = math.MaxUint64
+)
+
 func NewEthNetworkDetails(networkName string) (ret *EthNetworkDetails, err error) {
 	var genesisForkVersion string
 	var genesisValidatorsRoot string
 	var bellatrixForkVersion string
+	var capellaForkVersion string
+	var capellaForkEpoch uint64 = CapellaForkEpochNever
 	var domainBuilder types.Domain
-	var domainBeaconProposer types.Domain
+	var domainBeaconProposerBellatrix types.Domain
+	var domainBeaconProposerCapella types.Domain
 
 	switch networkName {
 	case EthNetworkKiln:
@@ -83,14 +108,20 @@ func NewEthNetworkDetails(networkName string) (ret *EthNetworkDetails, err error
 		genesisForkVersion = types.GenesisForkVersionSepolia
 		genesisValidatorsRoot = types.GenesisValidatorsRootSepolia
 		bellatrixForkVersion = types.BellatrixForkVersionSepolia
+		capellaForkVersion = CapellaForkVersionSepolia
+		capellaForkEpoch = CapellaForkEpochSepolia
 	case EthNetworkGoerli:
 		genesisForkVersion = types.GenesisForkVersionGoerli
 		genesisValidatorsRoot = types.GenesisValidatorsRootGoerli
 		bellatrixForkVersion = types.BellatrixForkVersionGoerli
+		capellaForkVersion = CapellaForkVersionGoerli
+		capellaForkEpoch = CapellaForkEpochGoerli
 	case EthNetworkMainnet:
 		genesisForkVersion = types.GenesisForkVersionMainnet
 		genesisValidatorsRoot = types.GenesisValidatorsRootMainnet
 		bellatrixForkVersion = types.BellatrixForkVersionMainnet
+		capellaForkVersion = CapellaForkVersionMainnet
+		capellaForkEpoch = CapellaForkEpochMainnet
 	default:
 		return nil, fmt.Errorf("%w: %s", ErrUnknownNetwork, networkName)
 	}
@@ -100,18 +131,28 @@ func NewEthNetworkDetails(networkName string) (ret *EthNetworkDetails, err error
 		return nil, err
 	}
 
-	domainBeaconProposer, err = ComputeDomain(types.DomainTypeBeaconProposer, bellatrixForkVersion, genesisValidatorsRoot)
+	domainBeaconProposerBellatrix, err = ComputeDomain(types.DomainTypeBeaconProposer, bellatrixForkVersion, genesisValidatorsRoot)
 	if err != nil {
 		return nil, err
 	}
 
+	if capellaForkVersion != "" {
+		domainBeaconProposerCapella, err = ComputeDomain(types.DomainTypeBeaconProposer, capellaForkVersion, genesisValidatorsRoot)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &EthNetworkDetails{
-		Name:                     networkName,
-		GenesisForkVersionHex:    genesisForkVersion,
-		GenesisValidatorsRootHex: genesisValidatorsRoot,
-		BellatrixForkVersionHex:  bellatrixForkVersion,
-		DomainBuilder:            domainBuilder,
-		DomainBeaconProposer:     domainBeaconProposer,
+		Name:                          networkName,
+		GenesisForkVersionHex:         genesisForkVersion,
+		GenesisValidatorsRootHex:      genesisValidatorsRoot,
+		BellatrixForkVersionHex:       bellatrixForkVersion,
+		CapellaForkVersionHex:         capellaForkVersion,
+		CapellaForkEpoch:              capellaForkEpoch,
+		DomainBuilder:                 domainBuilder,
+		DomainBeaconProposerBellatrix: domainBeaconProposerBellatrix,
+		DomainBeaconProposerCapella:   domainBeaconProposerCapella,
 	}, nil
 }
 
@@ -208,3 +249,46 @@ func (b *BidTraceV2WithTimestampJSON) ToCSVRecord() []string {
 		fmt.Sprint(b.TimestampMs),
 	}
 }
+
+// TopBidUpdate is published whenever the top bid for a slot, parent hash and proposer changes
+type TopBidUpdate struct {
+	Slot           uint64 `json:"slot,string"`
+	ParentHash     string `json:"parent_hash"`
+	ProposerPubkey string `json:"proposer_pubkey"`
+	BuilderPubkey  string `json:"builder_pubkey"`
+	Value          string `json:"value"`
+	Timestamp      int64  `json:"timestamp,string"`
+	TimestampMs    int64  `json:"timestamp_ms,string"`
+}
+
+// DailyStatsJSON are the aggregates of the delivered payloads and builder submissions of a day (UTC)
+type DailyStatsJSON struct {
+	Day                  string  `json:"day"`
+	NumPayloadsDelivered uint64  `json:"num_payloads_delivered,string"`
+	TotalValue           string  `json:"total_value"`
+	MedianValue          string  `json:"median_value"`
+	AvgNumTx             float64 `json:"avg_num_tx"`
+	NumSubmissions       uint64  `json:"num_submissions,string"`
+	UpdatedAt            int64   `json:"updated_at,string"`
+}
+
+// BuilderDailyStatsJSON are the aggregates of a builder's delivered payloads and submissions of a day (UTC). The sim
+// error rate is over all submissions of the builder.
+type BuilderDailyStatsJSON struct {
+	DailyStatsJSON
+	BuilderPubkey string  `json:"builder_pubkey"`
+	SimErrorRate  float64 `json:"sim_error_rate"`
+}
+
+// GetHeaderServedJSON is a bid that was returned to a proposer in response to getHeader
+type GetHeaderServedJSON struct {
+	Slot            uint64 `json:"slot,string"`
+	ParentHash      string `json:"parent_hash"`
+	ProposerPubkey  string `json:"proposer_pubkey"`
+	BlockHash       string `json:"block_hash"`
+	Value           string `json:"value"`
+	UserAgent       string `json:"user_agent"`
+	MevBoostVersion string `json:"mev_boost_version"`
+	Timestamp       int64  `json:"timestamp,string"`
+	TimestampMs     int64  `json:"timestamp_ms,string"`
+}
@@ -0,0 +1,504 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	apiv1capella "github.com/attestantio/go-eth2-client/api/v1/capella"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/buger/jsonparser"
+	ssz "github.com/ferranbt/fastssz"
+	"github.com/flashbots/go-boost-utils/types"
+)
+
+var (
+	ErrEmptyPayload       = errors.New("nil payload")
+	ErrUnsupportedVersion = errors.New("unsupported fork version")
+)
+
+var (
+	VersionBellatrix types.VersionString = "bellatrix"
+	VersionCapella   types.VersionString = "capella"
+)
+
+// BuilderSubmitBlockRequestCapella is the Capella builder block submission - https://flashbots.github.io/relay-specs/#/Builder/submitBlock
+type BuilderSubmitBlockRequestCapella struct {
+	Message          *types.BidTrace           `json:"message"`
+	ExecutionPayload *capella.ExecutionPayload `json:"execution_payload"`
+	Signature        types.Signature           `json:"signature" ssz-size:"96"`
+}
+
+// BuilderBidCapella is the Capella builder bid, which commits to the payload header including the withdrawals root
+type BuilderBidCapella struct {
+	Header *capella.ExecutionPayloadHeader `json:"header"`
+	Value  types.U256Str                   `json:"value" ssz-size:"32"`
+	Pubkey types.PublicKey                 `json:"pubkey" ssz-size:"48"`
+}
+
+// HashTreeRoot ssz hashes the BuilderBidCapella object
+func (b *BuilderBidCapella) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(b)
+}
+
+// HashTreeRootWith ssz hashes the BuilderBidCapella object with a hasher
+func (b *BuilderBidCapella) HashTreeRootWith(hh ssz.HashWalker) (err error) {
+	indx := hh.Index()
+
+	// Field (0) 'Header'
+	if b.Header == nil {
+		b.Header = new(capella.ExecutionPayloadHeader)
+	}
+	if err = b.Header.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (1) 'Value'
+	hh.PutBytes(b.Value[:])
+
+	// Field (2) 'Pubkey'
+	hh.PutBytes(b.Pubkey[:])
+
+	hh.Merkleize(indx)
+	return
+}
+
+// GetTree ssz hashes the BuilderBidCapella object
+func (b *BuilderBidCapella) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(b)
+}
+
+type SignedBuilderBidCapella struct {
+	Message   *BuilderBidCapella `json:"message"`
+	Signature types.Signature    `json:"signature" ssz-size:"96"`
+}
+
+type GetHeaderResponseCapella struct {
+	Version types.VersionString      `json:"version"`
+	Data    *SignedBuilderBidCapella `json:"data"`
+}
+
+type GetPayloadResponseCapella struct {
+	Version types.VersionString       `json:"version"`
+	Data    *capella.ExecutionPayload `json:"data"`
+}
+
+// withdrawals is a helper to compute the hash tree root of a withdrawals list
+type withdrawals []*capella.Withdrawal
+
+func (w withdrawals) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(w)
+}
+
+func (w withdrawals) HashTreeRootWith(hh ssz.HashWalker) error {
+	indx := hh.Index()
+	num := uint64(len(w))
+	if num > 16 {
+		return ssz.ErrIncorrectListSize
+	}
+	for _, elem := range w {
+		if err := elem.HashTreeRootWith(hh); err != nil {
+			return err
+		}
+	}
+	hh.MerkleizeWithMixin(indx, num, 16)
+	return nil
+}
+
+func (w withdrawals) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(w)
+}
+
+// ComputeWithdrawalsRoot returns the SSZ hash tree root of a list of withdrawals
+func ComputeWithdrawalsRoot(w []*capella.Withdrawal) (phase0.Root, error) {
+	return withdrawals(w).HashTreeRoot()
+}
+
+// CapellaPayloadToPayloadHeader converts a Capella execution payload to its header
+func CapellaPayloadToPayloadHeader(p *capella.ExecutionPayload) (*capella.ExecutionPayloadHeader, error) {
+	if p == nil {
+		return nil, ErrEmptyPayload
+	}
+
+	txs := make([][]byte, len(p.Transactions))
+	for i, tx := range p.Transactions {
+		txs[i] = tx
+	}
+	transactions := types.Transactions{Transactions: txs}
+	transactionsRoot, err := transactions.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawalsRoot, err := ComputeWithdrawalsRoot(p.Withdrawals)
+	if err != nil {
+		return nil, err
+	}
+
+	return &capella.ExecutionPayloadHeader{
+		ParentHash:       p.ParentHash,
+		FeeRecipient:     p.FeeRecipient,
+		StateRoot:        p.StateRoot,
+		ReceiptsRoot:     p.ReceiptsRoot,
+		LogsBloom:        p.LogsBloom,
+		PrevRandao:       p.PrevRandao,
+		BlockNumber:      p.BlockNumber,
+		GasLimit:         p.GasLimit,
+		GasUsed:          p.GasUsed,
+		Timestamp:        p.Timestamp,
+		ExtraData:        p.ExtraData,
+		BaseFeePerGas:    p.BaseFeePerGas,
+		BlockHash:        p.BlockHash,
+		TransactionsRoot: transactionsRoot,
+		WithdrawalsRoot:  withdrawalsRoot,
+	}, nil
+}
+
+// BuilderSubmitBlockRequest is a fork-aware builder block submission, only one of the fields is set
+type BuilderSubmitBlockRequest struct {
+	Bellatrix *types.BuilderSubmitBlockRequest
+	Capella   *BuilderSubmitBlockRequestCapella
+}
+
+// DecodeBuilderSubmitBlockRequest decodes a JSON submission into the fork selected by isCapella
+func DecodeBuilderSubmitBlockRequest(data []byte, isCapella bool) (*BuilderSubmitBlockRequest, error) {
+	req := new(BuilderSubmitBlockRequest)
+	if isCapella {
+		req.Capella = new(BuilderSubmitBlockRequestCapella)
+		if err := json.Unmarshal(data, req.Capella); err != nil {
+			return nil, err
+		}
+		if req.Capella.Message == nil || req.Capella.ExecutionPayload == nil {
+			return nil, ErrEmptyPayload
+		}
+		return req, nil
+	}
+
+	req.Bellatrix = new(types.BuilderSubmitBlockRequest)
+	if err := json.Unmarshal(data, req.Bellatrix); err != nil {
+		return nil, err
+	}
+	if req.Bellatrix.Message == nil || req.Bellatrix.ExecutionPayload == nil {
+		return nil, ErrEmptyPayload
+	}
+	return req, nil
+}
+
+func (r *BuilderSubmitBlockRequest) MarshalJSON() ([]byte, error) {
+	if r.Capella != nil {
+		return json.Marshal(r.Capella)
+	}
+	return json.Marshal(r.Bellatrix)
+}
+
+func (r *BuilderSubmitBlockRequest) Version() types.VersionString {
+	if r.Capella != nil {
+		return VersionCapella
+	}
+	return VersionBellatrix
+}
+
+func (r *BuilderSubmitBlockRequest) Message() *types.BidTrace {
+	if r.Capella != nil {
+		return r.Capella.Message
+	}
+	return r.Bellatrix.Message
+}
+
+func (r *BuilderSubmitBlockRequest) Signature() types.Signature {
+	if r.Capella != nil {
+		return r.Capella.Signature
+	}
+	return r.Bellatrix.Signature
+}
+
+func (r *BuilderSubmitBlockRequest) Slot() uint64 {
+	return r.Message().Slot
+}
+
+func (r *BuilderSubmitBlockRequest) BlockHash() types.Hash {
+	if r.Capella != nil {
+		return types.Hash(r.Capella.ExecutionPayload.BlockHash)
+	}
+	return r.Bellatrix.ExecutionPayload.BlockHash
+}
+
+func (r *BuilderSubmitBlockRequest) ParentHash() types.Hash {
+	if r.Capella != nil {
+		return types.Hash(r.Capella.ExecutionPayload.ParentHash)
+	}
+	return r.Bellatrix.ExecutionPayload.ParentHash
+}
+
+func (r *BuilderSubmitBlockRequest) PrevRandao() types.Hash {
+	if r.Capella != nil {
+		return types.Hash(r.Capella.ExecutionPayload.PrevRandao)
+	}
+	return r.Bellatrix.ExecutionPayload.Random
+}
+
+func (r *BuilderSubmitBlockRequest) Timestamp() uint64 {
+	if r.Capella != nil {
+		return r.Capella.ExecutionPayload.Timestamp
+	}
+	return r.Bellatrix.ExecutionPayload.Timestamp
+}
+
+func (r *BuilderSubmitBlockRequest) BlockNumber() uint64 {
+	if r.Capella != nil {
+		return r.Capella.ExecutionPayload.BlockNumber
+	}
+	return r.Bellatrix.ExecutionPayload.BlockNumber
+}
+
+func (r *BuilderSubmitBlockRequest) NumTx() int {
+	if r.Capella != nil {
+		return len(r.Capella.ExecutionPayload.Transactions)
+	}
+	return len(r.Bellatrix.ExecutionPayload.Transactions)
+}
+
+// Withdrawals returns the payload withdrawals, which are always nil before Capella
+func (r *BuilderSubmitBlockRequest) Withdrawals() []*capella.Withdrawal {
+	if r.Capella != nil {
+		return r.Capella.ExecutionPayload.Withdrawals
+	}
+	return nil
+}
+
+// ExecutionPayloadJSON returns the JSON encoding of just the execution payload
+func (r *BuilderSubmitBlockRequest) ExecutionPayloadJSON() ([]byte, error) {
+	if r.Capella != nil {
+		return json.Marshal(r.Capella.ExecutionPayload)
+	}
+	return json.Marshal(r.Bellatrix.ExecutionPayload)
+}
+
+// GetHeaderResponse is a fork-aware getHeader response, only one of the fields is set
+type GetHeaderResponse struct {
+	Bellatrix *types.GetHeaderResponse
+	Capella   *GetHeaderResponseCapella
+}
+
+func (r *GetHeaderResponse) MarshalJSON() ([]byte, error) {
+	if r.Capella != nil {
+		return json.Marshal(r.Capella)
+	}
+	return json.Marshal(r.Bellatrix)
+}
+
+func (r *GetHeaderResponse) UnmarshalJSON(data []byte) error {
+	version, err := jsonparser.GetString(data, "version")
+	if err != nil {
+		return err
+	}
+
+	switch types.VersionString(version) {
+	case VersionBellatrix:
+		r.Bellatrix = new(types.GetHeaderResponse)
+		return json.Unmarshal(data, r.Bellatrix)
+	case VersionCapella:
+		r.Capella = new(GetHeaderResponseCapella)
+		return json.Unmarshal(data, r.Capella)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+}
+
+func (r *GetHeaderResponse) Version() types.VersionString {
+	if r.Capella != nil {
+		return r.Capella.Version
+	}
+	return r.Bellatrix.Version
+}
+
+func (r *GetHeaderResponse) Value() types.U256Str {
+	if r.Capella != nil {
+		return r.Capella.Data.Message.Value
+	}
+	return r.Bellatrix.Data.Message.Value
+}
+
+func (r *GetHeaderResponse) BlockHash() types.Hash {
+	if r.Capella != nil {
+		return types.Hash(r.Capella.Data.Message.Header.BlockHash)
+	}
+	return r.Bellatrix.Data.Message.Header.BlockHash
+}
+
+// GetPayloadResponse is a fork-aware getPayload response, only one of the fields is set
+type GetPayloadResponse struct {
+	Bellatrix *types.GetPayloadResponse
+	Capella   *GetPayloadResponseCapella
+}
+
+// NewGetPayloadResponse decodes a stored execution payload of the given fork version
+func NewGetPayloadResponse(version types.VersionString, payload []byte) (*GetPayloadResponse, error) {
+	switch version {
+	case VersionBellatrix:
+		executionPayload := new(types.ExecutionPayload)
+		if err := json.Unmarshal(payload, executionPayload); err != nil {
+			return nil, err
+		}
+		return &GetPayloadResponse{Bellatrix: &types.GetPayloadResponse{Version: version, Data: executionPayload}}, nil
+	case VersionCapella:
+		executionPayload := new(capella.ExecutionPayload)
+		if err := json.Unmarshal(payload, executionPayload); err != nil {
+			return nil, err
+		}
+		return &GetPayloadResponse{Capella: &GetPayloadResponseCapella{Version: version, Data: executionPayload}}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+}
+
+func (r *GetPayloadResponse) MarshalJSON() ([]byte, error) {
+	if r.Capella != nil {
+		return json.Marshal(r.Capella)
+	}
+	return json.Marshal(r.Bellatrix)
+}
+
+func (r *GetPayloadResponse) UnmarshalJSON(data []byte) error {
+	version, err := jsonparser.GetString(data, "version")
+	if err != nil {
+		return err
+	}
+
+	switch types.VersionString(version) {
+	case VersionBellatrix:
+		r.Bellatrix = new(types.GetPayloadResponse)
+		return json.Unmarshal(data, r.Bellatrix)
+	case VersionCapella:
+		r.Capella = new(GetPayloadResponseCapella)
+		return json.Unmarshal(data, r.Capella)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+}
+
+func (r *GetPayloadResponse) Version() types.VersionString {
+	if r.Capella != nil {
+		return r.Capella.Version
+	}
+	return r.Bellatrix.Version
+}
+
+func (r *GetPayloadResponse) BlockHash() types.Hash {
+	if r.Capella != nil {
+		return types.Hash(r.Capella.Data.BlockHash)
+	}
+	return r.Bellatrix.Data.BlockHash
+}
+
+func (r *GetPayloadResponse) NumTx() int {
+	if r.Capella != nil {
+		return len(r.Capella.Data.Transactions)
+	}
+	return len(r.Bellatrix.Data.Transactions)
+}
+
+// SignedBlindedBeaconBlock is a fork-aware signed blinded beacon block, only one of the fields is set
+type SignedBlindedBeaconBlock struct {
+	Bellatrix *types.SignedBlindedBeaconBlock
+	Capella   *apiv1capella.SignedBlindedBeaconBlock
+}
+
+// DecodeSignedBlindedBeaconBlock decodes a JSON signed blinded beacon block into the fork selected by isCapella
+func DecodeSignedBlindedBeaconBlock(data []byte, isCapella bool) (*SignedBlindedBeaconBlock, error) {
+	block := new(SignedBlindedBeaconBlock)
+	if isCapella {
+		block.Capella = new(apiv1capella.SignedBlindedBeaconBlock)
+		if err := json.Unmarshal(data, block.Capella); err != nil {
+			return nil, err
+		}
+		return block, nil
+	}
+
+	block.Bellatrix = new(types.SignedBlindedBeaconBlock)
+	if err := json.Unmarshal(data, block.Bellatrix); err != nil {
+		return nil, err
+	}
+	if block.Bellatrix.Message == nil || block.Bellatrix.Message.Body == nil || block.Bellatrix.Message.Body.ExecutionPayloadHeader == nil {
+		return nil, ErrEmptyPayload
+	}
+	return block, nil
+}
+
+func (b *SignedBlindedBeaconBlock) MarshalJSON() ([]byte, error) {
+	if b.Capella != nil {
+		return json.Marshal(b.Capella)
+	}
+	return json.Marshal(b.Bellatrix)
+}
+
+func (b *SignedBlindedBeaconBlock) Slot() uint64 {
+	if b.Capella != nil {
+		return uint64(b.Capella.Message.Slot)
+	}
+	return b.Bellatrix.Message.Slot
+}
+
+func (b *SignedBlindedBeaconBlock) ProposerIndex() uint64 {
+	if b.Capella != nil {
+		return uint64(b.Capella.Message.ProposerIndex)
+	}
+	return b.Bellatrix.Message.ProposerIndex
+}
+
+func (b *SignedBlindedBeaconBlock) BlockHash() types.Hash {
+	if b.Capella != nil {
+		return types.Hash(b.Capella.Message.Body.ExecutionPayloadHeader.BlockHash)
+	}
+	return b.Bellatrix.Message.Body.ExecutionPayloadHeader.BlockHash
+}
+
+func (b *SignedBlindedBeaconBlock) ParentHash() types.Hash {
+	if b.Capella != nil {
+		return types.Hash(b.Capella.Message.Body.ExecutionPayloadHeader.ParentHash)
+	}
+	return b.Bellatrix.Message.Body.ExecutionPayloadHeader.ParentHash
+}
+
+func (b *SignedBlindedBeaconBlock) Signature() []byte {
+	if b.Capella != nil {
+		return b.Capella.Signature[:]
+	}
+	return b.Bellatrix.Signature[:]
+}
+
+// VerifySignature checks the proposer signature with the domain of the block's fork
+func (b *SignedBlindedBeaconBlock) VerifySignature(pubkey []byte, domainBellatrix, domainCapella types.Domain) (bool, error) {
+	if b.Capella != nil {
+		return types.VerifySignature(b.Capella.Message, domainCapella, pubkey, b.Capella.Signature[:])
+	}
+	return types.VerifySignature(b.Bellatrix.Message, domainBellatrix, pubkey, b.Bellatrix.Signature[:])
+}
+
+// SignedBeaconBlock is a fork-aware signed beacon block, only one of the fields is set
+type SignedBeaconBlock struct {
+	Bellatrix *types.SignedBeaconBlock
+	Capella   *capella.SignedBeaconBlock
+}
+
+func (b *SignedBeaconBlock) MarshalJSON() ([]byte, error) {
+	if b.Capella != nil {
+		return json.Marshal(b.Capella)
+	}
+	return json.Marshal(b.Bellatrix)
+}
+
+func (b *SignedBeaconBlock) Slot() uint64 {
+	if b.Capella != nil {
+		return uint64(b.Capella.Message.Slot)
+	}
+	return b.Bellatrix.Message.Slot
+}
+
+func (b *SignedBeaconBlock) BlockHash() string {
+	if b.Capella != nil {
+		return b.Capella.Message.Body.ExecutionPayload.BlockHash.String()
+	}
+	return b.Bellatrix.Message.Body.ExecutionPayload.BlockHash.String()
+}
@@ -0,0 +1,322 @@
+package common
+
+import (
+	"encoding/binary"
+
+	apiv1capella "github.com/attestantio/go-eth2-client/api/v1/capella"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ssz "github.com/ferranbt/fastssz"
+	"github.com/flashbots/go-boost-utils/types"
+)
+
+const (
+	// bidTraceSSZSize is the fixed size of an ssz encoded BidTrace
+	bidTraceSSZSize = 236
+
+	// submitBlockRequestSSZFixedSize is message, the execution payload offset and the signature
+	submitBlockRequestSSZFixedSize = bidTraceSSZSize + 4 + 96
+
+	// signedContainerSSZFixedSize is the message offset and the signature
+	signedContainerSSZFixedSize = 4 + 96
+)
+
+type sszObject interface {
+	MarshalSSZTo(buf []byte) ([]byte, error)
+	UnmarshalSSZ(buf []byte) error
+	SizeSSZ() int
+}
+
+// marshalSubmitBlockRequestSSZ encodes the (message, execution_payload, signature) submission container
+func marshalSubmitBlockRequestSSZ(message *types.BidTrace, payload sszObject, signature types.Signature) ([]byte, error) {
+	if message == nil {
+		message = new(types.BidTrace)
+	}
+
+	dst := make([]byte, 0, submitBlockRequestSSZFixedSize+payload.SizeSSZ())
+	dst, err := message.MarshalSSZTo(dst)
+	if err != nil {
+		return nil, err
+	}
+	dst = ssz.WriteOffset(dst, submitBlockRequestSSZFixedSize)
+	dst = append(dst, signature[:]...)
+	return payload.MarshalSSZTo(dst)
+}
+
+// unmarshalSubmitBlockRequestSSZ decodes the (message, execution_payload, signature) submission container
+func unmarshalSubmitBlockRequestSSZ(buf []byte, message *types.BidTrace, payload sszObject, signature *types.Signature) error {
+	size := uint64(len(buf))
+	if size < submitBlockRequestSSZFixedSize {
+		return ssz.ErrSize
+	}
+
+	if err := message.UnmarshalSSZ(buf[0:bidTraceSSZSize]); err != nil {
+		return err
+	}
+
+	o1 := ssz.ReadOffset(buf[bidTraceSSZSize : bidTraceSSZSize+4])
+	if o1 > size {
+		return ssz.ErrOffset
+	}
+	if o1 != submitBlockRequestSSZFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+
+	copy(signature[:], buf[bidTraceSSZSize+4:submitBlockRequestSSZFixedSize])
+	return payload.UnmarshalSSZ(buf[o1:])
+}
+
+// marshalSignedContainerSSZ encodes a (message, signature) container with a variable-size message
+func marshalSignedContainerSSZ(message sszObject, signature []byte) ([]byte, error) {
+	dst := make([]byte, 0, signedContainerSSZFixedSize+message.SizeSSZ())
+	dst = ssz.WriteOffset(dst, signedContainerSSZFixedSize)
+	dst = append(dst, signature...)
+	return message.MarshalSSZTo(dst)
+}
+
+// unmarshalSignedContainerSSZ decodes a (message, signature) container with a variable-size message
+func unmarshalSignedContainerSSZ(buf []byte, message sszObject, signature []byte) error {
+	size := uint64(len(buf))
+	if size < signedContainerSSZFixedSize {
+		return ssz.ErrSize
+	}
+
+	o0 := ssz.ReadOffset(buf[0:4])
+	if o0 > size {
+		return ssz.ErrOffset
+	}
+	if o0 != signedContainerSSZFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+
+	copy(signature, buf[4:signedContainerSSZFixedSize])
+	return message.UnmarshalSSZ(buf[o0:])
+}
+
+// MarshalSSZ ssz marshals the BuilderSubmitBlockRequestCapella object
+func (r *BuilderSubmitBlockRequestCapella) MarshalSSZ() ([]byte, error) {
+	if r.ExecutionPayload == nil {
+		r.ExecutionPayload = new(capella.ExecutionPayload)
+	}
+	return marshalSubmitBlockRequestSSZ(r.Message, r.ExecutionPayload, r.Signature)
+}
+
+// UnmarshalSSZ ssz unmarshals the BuilderSubmitBlockRequestCapella object
+func (r *BuilderSubmitBlockRequestCapella) UnmarshalSSZ(buf []byte) error {
+	r.Message = new(types.BidTrace)
+	r.ExecutionPayload = new(capella.ExecutionPayload)
+	return unmarshalSubmitBlockRequestSSZ(buf, r.Message, r.ExecutionPayload, &r.Signature)
+}
+
+// MarshalSSZTo ssz marshals the BuilderBidCapella object to a target array
+func (b *BuilderBidCapella) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+	if b.Header == nil {
+		b.Header = new(capella.ExecutionPayloadHeader)
+	}
+
+	// Offset (0) 'Header'
+	dst = ssz.WriteOffset(dst, 84)
+
+	// Field (1) 'Value'
+	dst = append(dst, b.Value[:]...)
+
+	// Field (2) 'Pubkey'
+	dst = append(dst, b.Pubkey[:]...)
+
+	// Field (0) 'Header'
+	return b.Header.MarshalSSZTo(dst)
+}
+
+// MarshalSSZ ssz marshals the BuilderBidCapella object
+func (b *BuilderBidCapella) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(b)
+}
+
+// UnmarshalSSZ ssz unmarshals the BuilderBidCapella object
+func (b *BuilderBidCapella) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < 84 {
+		return ssz.ErrSize
+	}
+
+	// Offset (0) 'Header'
+	o0 := ssz.ReadOffset(buf[0:4])
+	if o0 > size {
+		return ssz.ErrOffset
+	}
+	if o0 != 84 {
+		return ssz.ErrInvalidVariableOffset
+	}
+
+	// Field (1) 'Value'
+	copy(b.Value[:], buf[4:36])
+
+	// Field (2) 'Pubkey'
+	copy(b.Pubkey[:], buf[36:84])
+
+	// Field (0) 'Header'
+	b.Header = new(capella.ExecutionPayloadHeader)
+	return b.Header.UnmarshalSSZ(buf[o0:])
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the BuilderBidCapella object
+func (b *BuilderBidCapella) SizeSSZ() int {
+	if b.Header == nil {
+		b.Header = new(capella.ExecutionPayloadHeader)
+	}
+	return 84 + b.Header.SizeSSZ()
+}
+
+// MarshalSSZ ssz marshals the SignedBuilderBidCapella object
+func (s *SignedBuilderBidCapella) MarshalSSZ() ([]byte, error) {
+	if s.Message == nil {
+		s.Message = new(BuilderBidCapella)
+	}
+	return marshalSignedContainerSSZ(s.Message, s.Signature[:])
+}
+
+// UnmarshalSSZ ssz unmarshals the SignedBuilderBidCapella object
+func (s *SignedBuilderBidCapella) UnmarshalSSZ(buf []byte) error {
+	s.Message = new(BuilderBidCapella)
+	return unmarshalSignedContainerSSZ(buf, s.Message, s.Signature[:])
+}
+
+// bellatrixPayloadToSpec converts the payload to the go-eth2-client type, which implements ssz encoding
+func bellatrixPayloadToSpec(p *types.ExecutionPayload) *bellatrix.ExecutionPayload {
+	transactions := make([]bellatrix.Transaction, len(p.Transactions))
+	for i, tx := range p.Transactions {
+		transactions[i] = bellatrix.Transaction(tx)
+	}
+
+	return &bellatrix.ExecutionPayload{
+		ParentHash:    phase0.Hash32(p.ParentHash),
+		FeeRecipient:  bellatrix.ExecutionAddress(p.FeeRecipient),
+		StateRoot:     p.StateRoot,
+		ReceiptsRoot:  p.ReceiptsRoot,
+		LogsBloom:     p.LogsBloom,
+		PrevRandao:    p.Random,
+		BlockNumber:   p.BlockNumber,
+		GasLimit:      p.GasLimit,
+		GasUsed:       p.GasUsed,
+		Timestamp:     p.Timestamp,
+		ExtraData:     p.ExtraData,
+		BaseFeePerGas: p.BaseFeePerGas,
+		BlockHash:     phase0.Hash32(p.BlockHash),
+		Transactions:  transactions,
+	}
+}
+
+// bellatrixPayloadFromSpec converts a go-eth2-client payload back to the go-boost-utils type
+func bellatrixPayloadFromSpec(p *bellatrix.ExecutionPayload) *types.ExecutionPayload {
+	transactions := make([]hexutil.Bytes, len(p.Transactions))
+	for i, tx := range p.Transactions {
+		transactions[i] = hexutil.Bytes(tx)
+	}
+
+	return &types.ExecutionPayload{
+		ParentHash:    types.Hash(p.ParentHash),
+		FeeRecipient:  types.Address(p.FeeRecipient),
+		StateRoot:     p.StateRoot,
+		ReceiptsRoot:  p.ReceiptsRoot,
+		LogsBloom:     p.LogsBloom,
+		Random:        p.PrevRandao,
+		BlockNumber:   p.BlockNumber,
+		GasLimit:      p.GasLimit,
+		GasUsed:       p.GasUsed,
+		Timestamp:     p.Timestamp,
+		ExtraData:     p.ExtraData,
+		BaseFeePerGas: p.BaseFeePerGas,
+		BlockHash:     types.Hash(p.BlockHash),
+		Transactions:  transactions,
+	}
+}
+
+// SubmitBlockRequestSlotSSZ returns the slot of an ssz encoded submission without decoding it
+func SubmitBlockRequestSlotSSZ(buf []byte) (uint64, error) {
+	if len(buf) < submitBlockRequestSSZFixedSize {
+		return 0, ssz.ErrSize
+	}
+	return binary.LittleEndian.Uint64(buf[0:8]), nil
+}
+
+// DecodeBuilderSubmitBlockRequestSSZ decodes an ssz submission into the fork selected by isCapella
+func DecodeBuilderSubmitBlockRequestSSZ(buf []byte, isCapella bool) (*BuilderSubmitBlockRequest, error) {
+	if isCapella {
+		req := new(BuilderSubmitBlockRequestCapella)
+		if err := req.UnmarshalSSZ(buf); err != nil {
+			return nil, err
+		}
+		return &BuilderSubmitBlockRequest{Capella: req}, nil
+	}
+
+	req := &types.BuilderSubmitBlockRequest{Message: new(types.BidTrace)}
+	payload := new(bellatrix.ExecutionPayload)
+	if err := unmarshalSubmitBlockRequestSSZ(buf, req.Message, payload, &req.Signature); err != nil {
+		return nil, err
+	}
+	req.ExecutionPayload = bellatrixPayloadFromSpec(payload)
+	return &BuilderSubmitBlockRequest{Bellatrix: req}, nil
+}
+
+func (r *BuilderSubmitBlockRequest) MarshalSSZ() ([]byte, error) {
+	if r.Capella != nil {
+		return r.Capella.MarshalSSZ()
+	}
+	return marshalSubmitBlockRequestSSZ(r.Bellatrix.Message, bellatrixPayloadToSpec(r.Bellatrix.ExecutionPayload), r.Bellatrix.Signature)
+}
+
+// SignedBlindedBeaconBlockSlotSSZ returns the slot of an ssz encoded signed blinded beacon block without decoding it
+func SignedBlindedBeaconBlockSlotSSZ(buf []byte) (uint64, error) {
+	if len(buf) < signedContainerSSZFixedSize {
+		return 0, ssz.ErrSize
+	}
+
+	o0 := ssz.ReadOffset(buf[0:4])
+	if o0 > uint64(len(buf))-8 {
+		return 0, ssz.ErrOffset
+	}
+	return binary.LittleEndian.Uint64(buf[o0 : o0+8]), nil
+}
+
+// DecodeSignedBlindedBeaconBlockSSZ decodes an ssz signed blinded beacon block into the fork selected by isCapella
+func DecodeSignedBlindedBeaconBlockSSZ(buf []byte, isCapella bool) (*SignedBlindedBeaconBlock, error) {
+	if isCapella {
+		block := &apiv1capella.SignedBlindedBeaconBlock{Message: new(apiv1capella.BlindedBeaconBlock)}
+		if err := unmarshalSignedContainerSSZ(buf, block.Message, block.Signature[:]); err != nil {
+			return nil, err
+		}
+		return &SignedBlindedBeaconBlock{Capella: block}, nil
+	}
+
+	block := new(types.SignedBlindedBeaconBlock)
+	if err := block.UnmarshalSSZ(buf); err != nil {
+		return nil, err
+	}
+	return &SignedBlindedBeaconBlock{Bellatrix: block}, nil
+}
+
+func (b *SignedBlindedBeaconBlock) MarshalSSZ() ([]byte, error) {
+	if b.Capella != nil {
+		return marshalSignedContainerSSZ(b.Capella.Message, b.Capella.Signature[:])
+	}
+	return b.Bellatrix.MarshalSSZ()
+}
+
+// MarshalSSZ returns the ssz encoded signed builder bid, the version is conveyed out of band
+func (r *GetHeaderResponse) MarshalSSZ() ([]byte, error) {
+	if r.Capella != nil {
+		return r.Capella.Data.MarshalSSZ()
+	}
+	return r.Bellatrix.Data.MarshalSSZ()
+}
+
+// MarshalSSZ returns the ssz encoded execution payload, the version is conveyed out of band
+func (r *GetPayloadResponse) MarshalSSZ() ([]byte, error) {
+	if r.Capella != nil {
+		return r.Capella.Data.MarshalSSZ()
+	}
+	return bellatrixPayloadToSpec(r.Bellatrix.Data).MarshalSSZ()
+}
@@ -0,0 +1,179 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	apiv1capella "github.com/attestantio/go-eth2-client/api/v1/capella"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapellaPayloadToPayloadHeader(t *testing.T) {
+	payload := &capella.ExecutionPayload{
+		ParentHash:   phase0.Hash32{0x01},
+		BlockNumber:  5001,
+		Timestamp:    5004,
+		ExtraData:    []byte{0x07},
+		BlockHash:    phase0.Hash32{0x09},
+		Transactions: []bellatrix.Transaction{{0x01, 0x02}, {0x03}},
+		Withdrawals: []*capella.Withdrawal{
+			{Index: 1, ValidatorIndex: 2, Address: bellatrix.ExecutionAddress{0x03}, Amount: 4},
+			{Index: 2, ValidatorIndex: 3, Address: bellatrix.ExecutionAddress{0x04}, Amount: 5},
+		},
+	}
+
+	header, err := CapellaPayloadToPayloadHeader(payload)
+	require.NoError(t, err)
+
+	// the header commits to the same hash tree root as the full payload
+	payloadRoot, err := payload.HashTreeRoot()
+	require.NoError(t, err)
+	headerRoot, err := header.HashTreeRoot()
+	require.NoError(t, err)
+	require.Equal(t, payloadRoot, headerRoot)
+}
+
+func TestGetPayloadResponseJSON(t *testing.T) {
+	payload, err := json.Marshal(&capella.ExecutionPayload{
+		BlockNumber:  1,
+		BlockHash:    phase0.Hash32{0x09},
+		Transactions: []bellatrix.Transaction{},
+		Withdrawals:  []*capella.Withdrawal{},
+	})
+	require.NoError(t, err)
+
+	resp, err := NewGetPayloadResponse(VersionCapella, payload)
+	require.NoError(t, err)
+	require.NotNil(t, resp.Capella)
+	require.Equal(t, VersionCapella, resp.Version())
+
+	encoded, err := json.Marshal(resp)
+	require.NoError(t, err)
+
+	decoded := new(GetPayloadResponse)
+	require.NoError(t, json.Unmarshal(encoded, decoded))
+	require.Nil(t, decoded.Bellatrix)
+	require.Equal(t, resp.BlockHash(), decoded.BlockHash())
+
+	_, err = NewGetPayloadResponse("unknown", nil)
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestIsCapella(t *testing.T) {
+	network := EthNetworkDetails{CapellaForkEpoch: 10}
+	require.False(t, network.IsCapella(10*uint64(SlotsPerEpoch)-1))
+	require.True(t, network.IsCapella(10*uint64(SlotsPerEpoch)))
+
+	network.CapellaForkEpoch = CapellaForkEpochNever
+	require.False(t, network.IsCapella(1<<40))
+}
+
+func TestBuilderSubmitBlockRequestSSZ(t *testing.T) {
+	jsonBytes, err := os.ReadFile("../testdata/submitBlockPayloadCapella.json")
+	require.NoError(t, err)
+	sszBytes, err := os.ReadFile("../testdata/submitBlockPayloadCapella.ssz")
+	require.NoError(t, err)
+
+	fromJSON, err := DecodeBuilderSubmitBlockRequest(jsonBytes, true)
+	require.NoError(t, err)
+
+	slot, err := SubmitBlockRequestSlotSSZ(sszBytes)
+	require.NoError(t, err)
+	require.Equal(t, fromJSON.Slot(), slot)
+
+	fromSSZ, err := DecodeBuilderSubmitBlockRequestSSZ(sszBytes, true)
+	require.NoError(t, err)
+	require.Equal(t, fromJSON, fromSSZ)
+	require.Len(t, fromSSZ.Withdrawals(), 2)
+
+	encoded, err := fromJSON.MarshalSSZ()
+	require.NoError(t, err)
+	require.Equal(t, sszBytes, encoded)
+
+	// truncated input
+	_, err = DecodeBuilderSubmitBlockRequestSSZ(sszBytes[:100], true)
+	require.Error(t, err)
+}
+
+func TestBuilderSubmitBlockRequestSSZBellatrix(t *testing.T) {
+	jsonBytes, err := os.ReadFile("../testdata/submitBlockPayload.json")
+	require.NoError(t, err)
+
+	fromJSON, err := DecodeBuilderSubmitBlockRequest(jsonBytes, false)
+	require.NoError(t, err)
+
+	sszBytes, err := fromJSON.MarshalSSZ()
+	require.NoError(t, err)
+
+	fromSSZ, err := DecodeBuilderSubmitBlockRequestSSZ(sszBytes, false)
+	require.NoError(t, err)
+	require.Equal(t, fromJSON.Message(), fromSSZ.Message())
+	require.Equal(t, fromJSON.Signature(), fromSSZ.Signature())
+	require.Equal(t, fromJSON.ParentHash(), fromSSZ.ParentHash())
+}
+
+func TestSignedBuilderBidCapellaSSZ(t *testing.T) {
+	bid := &SignedBuilderBidCapella{
+		Message: &BuilderBidCapella{
+			Header: &capella.ExecutionPayloadHeader{
+				BlockNumber: 10,
+				ExtraData:   []byte{0x01, 0x02},
+				BlockHash:   phase0.Hash32{0x09},
+			},
+			Value:  types.IntToU256(123),
+			Pubkey: types.PublicKey{0x01},
+		},
+		Signature: types.Signature{0x02},
+	}
+
+	encoded, err := bid.MarshalSSZ()
+	require.NoError(t, err)
+
+	decoded := new(SignedBuilderBidCapella)
+	require.NoError(t, decoded.UnmarshalSSZ(encoded))
+	require.Equal(t, bid, decoded)
+
+	// the hash tree root commits to the same data as the encoding
+	root, err := bid.Message.HashTreeRoot()
+	require.NoError(t, err)
+	decodedRoot, err := decoded.Message.HashTreeRoot()
+	require.NoError(t, err)
+	require.Equal(t, root, decodedRoot)
+}
+
+func TestSignedBlindedBeaconBlockSSZ(t *testing.T) {
+	block := &SignedBlindedBeaconBlock{
+		Capella: &apiv1capella.SignedBlindedBeaconBlock{
+			Message: &apiv1capella.BlindedBeaconBlock{
+				Slot:          5552306,
+				ProposerIndex: 12,
+				Body: &apiv1capella.BlindedBeaconBlockBody{
+					ETH1Data:               &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+					SyncAggregate:          &altair.SyncAggregate{SyncCommitteeBits: bitfield.NewBitvector512()},
+					ExecutionPayloadHeader: &capella.ExecutionPayloadHeader{BlockHash: phase0.Hash32{0x09}},
+				},
+			},
+			Signature: phase0.BLSSignature{0x03},
+		},
+	}
+
+	encoded, err := block.MarshalSSZ()
+	require.NoError(t, err)
+
+	slot, err := SignedBlindedBeaconBlockSlotSSZ(encoded)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5552306), slot)
+
+	decoded, err := DecodeSignedBlindedBeaconBlockSSZ(encoded, true)
+	require.NoError(t, err)
+	require.Equal(t, block.BlockHash(), decoded.BlockHash())
+	require.Equal(t, block.ProposerIndex(), decoded.ProposerIndex())
+	require.Equal(t, block.Signature(), decoded.Signature())
+}
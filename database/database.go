@@ -9,10 +9,10 @@ import (
 	"strings"
 	"time"
 
-	"github.com/flashbots/go-boost-utils/types"
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database/migrations"
 	"github.com/flashbots/mev-boost-relay/database/vars"
+	"github.com/flashbots/mev-boost-relay/metrics"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	migrate "github.com/rubenv/sql-migrate"
@@ -24,8 +24,10 @@ type IDatabaseService interface {
 	GetLatestValidatorRegistrations(timestampOnly bool) ([]*ValidatorRegistrationEntry, error)
 	GetValidatorRegistration(pubkey string) (*ValidatorRegistrationEntry, error)
 	GetValidatorRegistrationsForPubkeys(pubkeys []string) ([]*ValidatorRegistrationEntry, error)
+	GetValidatorRegistrationHistory(pubkey string, cursor, limit uint64) ([]*ValidatorRegistrationEntry, error)
+	GetValidatorRegistrationsByFeeRecipient(feeRecipient, cursor string, limit uint64) ([]*ValidatorRegistrationEntry, error)
 
-	SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError error, receivedAt time.Time) (entry *BuilderBlockSubmissionEntry, err error)
+	SaveBuilderBlockSubmission(payload *common.BuilderSubmitBlockRequest, simError error, receivedAt time.Time) (entry *BuilderBlockSubmissionEntry, err error)
 	GetBlockSubmissionEntry(slot uint64, proposerPubkey, blockHash string) (entry *BuilderBlockSubmissionEntry, err error)
 	GetBuilderSubmissions(filters GetBuilderSubmissionsFilters) ([]*BuilderBlockSubmissionEntry, error)
 	GetBuilderSubmissionsBySlots(slotFrom, slotTo uint64) (entries []*BuilderBlockSubmissionEntry, err error)
@@ -34,16 +36,32 @@ type IDatabaseService interface {
 	GetExecutionPayloads(idFirst, idLast uint64) (entries []*ExecutionPayloadEntry, err error)
 	DeleteExecutionPayloads(idFirst, idLast uint64) error
 
-	SaveDeliveredPayload(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock) error
+	SaveDeliveredPayload(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *common.SignedBlindedBeaconBlock) error
 	GetNumDeliveredPayloads() (uint64, error)
 	GetRecentDeliveredPayloads(filters GetPayloadsFilters) ([]*DeliveredPayloadEntry, error)
 	GetDeliveredPayloads(idFirst, idLast uint64) (entries []*DeliveredPayloadEntry, err error)
 
+	SaveGetHeaderServed(entry GetHeaderServedEntry) error
+	GetGetHeaderServed(filters GetHeaderServedFilters) ([]*GetHeaderServedEntry, error)
+
 	GetBlockBuilders() ([]*BlockBuilderEntry, error)
 	GetBlockBuilderByPubkey(pubkey string) (*BlockBuilderEntry, error)
 	SetBlockBuilderStatus(pubkey string, isHighPrio, isBlacklisted bool) error
+	DemoteBlockBuilder(pubkey string) error
 	UpsertBlockBuilderEntryAfterSubmission(lastSubmission *BuilderBlockSubmissionEntry, isError bool) error
 	IncBlockBuilderStatsAfterGetPayload(builderPubkey string) error
+	SetBlockBuilderCollateral(pubkey, collateral string) error
+
+	InsertBuilderDemotion(submitBlockRequest *common.BuilderSubmitBlockRequest, simError error) error
+	UpdateBuilderDemotion(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *common.SignedBlindedBeaconBlock) (refundPending bool, err error)
+
+	UpdateStatsRollups(day time.Time) error
+	GetDailyStats(fromDay, toDay time.Time) ([]*DailyStatsEntry, error)
+	GetBuilderDailyStats(day time.Time) ([]*BuilderDailyStatsEntry, error)
+
+	InsertDataAPIKey(keyHash, description string) (id int64, err error)
+	GetDataAPIKeys() ([]*DataAPIKeyEntry, error)
+	RevokeDataAPIKey(id int64) (revoked bool, err error)
 }
 
 type DatabaseService struct {
@@ -97,12 +115,19 @@ func (s *DatabaseService) prepareNamedQueries() (err error) {
 	return err
 }
 
+// observeCallDuration records the latency of a database call, to be deferred at the start of the call
+func observeCallDuration(call string, start time.Time) {
+	metrics.DatabaseCallDuration.WithLabelValues(call).Observe(time.Since(start).Seconds())
+}
+
 func (s *DatabaseService) Close() error {
 	return s.DB.Close()
 }
 
 // NumRegisteredValidators returns the number of unique pubkeys that have registered
 func (s *DatabaseService) NumRegisteredValidators() (count uint64, err error) {
+	defer observeCallDuration("NumRegisteredValidators", time.Now())
+
 	query := `SELECT COUNT(*) FROM (SELECT DISTINCT pubkey FROM ` + vars.TableValidatorRegistration + `) AS temp;`
 	row := s.DB.QueryRow(query)
 	err = row.Scan(&count)
@@ -110,6 +135,8 @@ func (s *DatabaseService) NumRegisteredValidators() (count uint64, err error) {
 }
 
 func (s *DatabaseService) NumValidatorRegistrationRows() (count uint64, err error) {
+	defer observeCallDuration("NumValidatorRegistrationRows", time.Now())
+
 	query := `SELECT COUNT(*) FROM ` + vars.TableValidatorRegistration + `;`
 	row := s.DB.QueryRow(query)
 	err = row.Scan(&count)
@@ -117,6 +144,8 @@ func (s *DatabaseService) NumValidatorRegistrationRows() (count uint64, err erro
 }
 
 func (s *DatabaseService) SaveValidatorRegistration(entry ValidatorRegistrationEntry) error {
+	defer observeCallDuration("SaveValidatorRegistration", time.Now())
+
 	query := `WITH latest_registration AS (
 		SELECT DISTINCT ON (pubkey) pubkey, fee_recipient, timestamp, gas_limit, signature FROM ` + vars.TableValidatorRegistration + ` WHERE pubkey=:pubkey ORDER BY pubkey, timestamp DESC limit 1
 	)
@@ -130,6 +159,8 @@ func (s *DatabaseService) SaveValidatorRegistration(entry ValidatorRegistrationE
 }
 
 func (s *DatabaseService) GetValidatorRegistration(pubkey string) (*ValidatorRegistrationEntry, error) {
+	defer observeCallDuration("GetValidatorRegistration", time.Now())
+
 	query := `SELECT DISTINCT ON (pubkey) pubkey, fee_recipient, timestamp, gas_limit, signature
 		FROM ` + vars.TableValidatorRegistration + `
 		WHERE pubkey=$1
@@ -140,6 +171,8 @@ func (s *DatabaseService) GetValidatorRegistration(pubkey string) (*ValidatorReg
 }
 
 func (s *DatabaseService) GetValidatorRegistrationsForPubkeys(pubkeys []string) (entries []*ValidatorRegistrationEntry, err error) {
+	defer observeCallDuration("GetValidatorRegistrationsForPubkeys", time.Now())
+
 	query := `SELECT DISTINCT ON (pubkey) pubkey, fee_recipient, timestamp, gas_limit, signature
 		FROM ` + vars.TableValidatorRegistration + `
 		WHERE pubkey IN (?)
@@ -153,7 +186,65 @@ func (s *DatabaseService) GetValidatorRegistrationsForPubkeys(pubkeys []string)
 	return entries, err
 }
 
+// GetValidatorRegistrationHistory returns the registrations of a validator, newest first. Only registrations which
+// changed the fee recipient or gas limit are saved. If cursor is set, only registrations older than the cursor
+// timestamp are returned.
+func (s *DatabaseService) GetValidatorRegistrationHistory(pubkey string, cursor, limit uint64) (entries []*ValidatorRegistrationEntry, err error) {
+	defer observeCallDuration("GetValidatorRegistrationHistory", time.Now())
+
+	arg := map[string]interface{}{
+		"pubkey": pubkey,
+		"cursor": cursor,
+		"limit":  limit,
+	}
+
+	query := `SELECT id, inserted_at, pubkey, fee_recipient, timestamp, gas_limit, signature
+		FROM ` + vars.TableValidatorRegistration + `
+		WHERE pubkey = :pubkey`
+	if cursor > 0 {
+		query += ` AND timestamp < :cursor`
+	}
+	query += ` ORDER BY timestamp DESC LIMIT :limit;`
+
+	nstmt, err := s.DB.PrepareNamed(query)
+	if err != nil {
+		return nil, err
+	}
+	defer nstmt.Close()
+	err = nstmt.Select(&entries, arg)
+	return entries, err
+}
+
+// GetValidatorRegistrationsByFeeRecipient returns the latest registrations of the validators currently using the fee
+// recipient, ordered by pubkey. If cursor is set, only validators with a pubkey after the cursor are returned.
+func (s *DatabaseService) GetValidatorRegistrationsByFeeRecipient(feeRecipient, cursor string, limit uint64) (entries []*ValidatorRegistrationEntry, err error) {
+	defer observeCallDuration("GetValidatorRegistrationsByFeeRecipient", time.Now())
+
+	// the validators which ever used the fee recipient are found in order by index, and each one's latest registration
+	// is looked up until there are enough which still use it
+	query := `SELECT latest.id, latest.inserted_at, latest.pubkey, latest.fee_recipient, latest.timestamp, latest.gas_limit, latest.signature
+		FROM (
+			SELECT DISTINCT pubkey FROM ` + vars.TableValidatorRegistration + `
+			WHERE fee_recipient = $1 AND pubkey > $2
+			ORDER BY pubkey
+		) AS candidates
+		CROSS JOIN LATERAL (
+			SELECT id, inserted_at, pubkey, fee_recipient, timestamp, gas_limit, signature
+			FROM ` + vars.TableValidatorRegistration + `
+			WHERE pubkey = candidates.pubkey
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) AS latest
+		WHERE latest.fee_recipient = $1
+		ORDER BY latest.pubkey
+		LIMIT $3;`
+	err = s.DB.Select(&entries, query, feeRecipient, cursor, limit)
+	return entries, err
+}
+
 func (s *DatabaseService) GetLatestValidatorRegistrations(timestampOnly bool) ([]*ValidatorRegistrationEntry, error) {
+	defer observeCallDuration("GetLatestValidatorRegistrations", time.Now())
+
 	// query details: https://stackoverflow.com/questions/3800551/select-first-row-in-each-group-by-group/7630564#7630564
 	query := `SELECT DISTINCT ON (pubkey) pubkey, fee_recipient, timestamp, gas_limit, signature`
 	if timestampOnly {
@@ -166,7 +257,9 @@ func (s *DatabaseService) GetLatestValidatorRegistrations(timestampOnly bool) ([
 	return registrations, err
 }
 
-func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError error, receivedAt time.Time) (entry *BuilderBlockSubmissionEntry, err error) {
+func (s *DatabaseService) SaveBuilderBlockSubmission(payload *common.BuilderSubmitBlockRequest, simError error, receivedAt time.Time) (entry *BuilderBlockSubmissionEntry, err error) {
+	defer observeCallDuration("SaveBuilderBlockSubmission", time.Now())
+
 	// Save execution_payload: insert, or if already exists update to be able to return the id ('on conflict do nothing' doesn't return an id)
 	execPayloadEntry, err := PayloadToExecPayloadEntry(payload)
 	if err != nil {
@@ -191,30 +284,32 @@ func (s *DatabaseService) SaveBuilderBlockSubmission(payload *types.BuilderSubmi
 		SimSuccess: simError == nil,
 		SimError:   simErrStr,
 
-		Signature: payload.Signature.String(),
+		Signature: payload.Signature().String(),
 
-		Slot:       payload.Message.Slot,
-		BlockHash:  payload.ExecutionPayload.BlockHash.String(),
-		ParentHash: payload.ExecutionPayload.ParentHash.String(),
+		Slot:       payload.Slot(),
+		BlockHash:  payload.BlockHash().String(),
+		ParentHash: payload.ParentHash().String(),
 
-		BuilderPubkey:        payload.Message.BuilderPubkey.String(),
-		ProposerPubkey:       payload.Message.ProposerPubkey.String(),
-		ProposerFeeRecipient: payload.Message.ProposerFeeRecipient.String(),
+		BuilderPubkey:        payload.Message().BuilderPubkey.String(),
+		ProposerPubkey:       payload.Message().ProposerPubkey.String(),
+		ProposerFeeRecipient: payload.Message().ProposerFeeRecipient.String(),
 
-		GasUsed:  payload.Message.GasUsed,
-		GasLimit: payload.Message.GasLimit,
+		GasUsed:  payload.Message().GasUsed,
+		GasLimit: payload.Message().GasLimit,
 
-		NumTx: uint64(len(payload.ExecutionPayload.Transactions)),
-		Value: payload.Message.Value.String(),
+		NumTx: uint64(payload.NumTx()),
+		Value: payload.Message().Value.String(),
 
-		Epoch:       payload.Message.Slot / uint64(common.SlotsPerEpoch),
-		BlockNumber: payload.ExecutionPayload.BlockNumber,
+		Epoch:       payload.Slot() / uint64(common.SlotsPerEpoch),
+		BlockNumber: payload.BlockNumber(),
 	}
 	err = s.nstmtInsertBlockBuilderSubmission.QueryRow(blockSubmissionEntry).Scan(&blockSubmissionEntry.ID)
 	return blockSubmissionEntry, err
 }
 
 func (s *DatabaseService) GetBlockSubmissionEntry(slot uint64, proposerPubkey, blockHash string) (entry *BuilderBlockSubmissionEntry, err error) {
+	defer observeCallDuration("GetBlockSubmissionEntry", time.Now())
+
 	query := `SELECT id, inserted_at, received_at, execution_payload_id, sim_success, sim_error, signature, slot, parent_hash, block_hash, builder_pubkey, proposer_pubkey, proposer_fee_recipient, gas_used, gas_limit, num_tx, value, epoch, block_number
 	FROM ` + vars.TableBuilderBlockSubmission + `
 	WHERE slot=$1 AND proposer_pubkey=$2 AND block_hash=$3
@@ -226,6 +321,8 @@ func (s *DatabaseService) GetBlockSubmissionEntry(slot uint64, proposerPubkey, b
 }
 
 func (s *DatabaseService) GetExecutionPayloadEntryByID(executionPayloadID int64) (entry *ExecutionPayloadEntry, err error) {
+	defer observeCallDuration("GetExecutionPayloadEntryByID", time.Now())
+
 	query := `SELECT id, inserted_at, slot, proposer_pubkey, block_hash, version, payload FROM ` + vars.TableExecutionPayload + ` WHERE id=$1`
 	entry = &ExecutionPayloadEntry{}
 	err = s.DB.Get(entry, query, executionPayloadID)
@@ -233,6 +330,8 @@ func (s *DatabaseService) GetExecutionPayloadEntryByID(executionPayloadID int64)
 }
 
 func (s *DatabaseService) GetExecutionPayloadEntryBySlotPkHash(slot uint64, proposerPubkey, blockHash string) (entry *ExecutionPayloadEntry, err error) {
+	defer observeCallDuration("GetExecutionPayloadEntryBySlotPkHash", time.Now())
+
 	query := `SELECT id, inserted_at, slot, proposer_pubkey, block_hash, version, payload
 	FROM ` + vars.TableExecutionPayload + `
 	WHERE slot=$1 AND proposer_pubkey=$2 AND block_hash=$3`
@@ -241,7 +340,9 @@ func (s *DatabaseService) GetExecutionPayloadEntryBySlotPkHash(slot uint64, prop
 	return entry, err
 }
 
-func (s *DatabaseService) SaveDeliveredPayload(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock) error {
+func (s *DatabaseService) SaveDeliveredPayload(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *common.SignedBlindedBeaconBlock) error {
+	defer observeCallDuration("SaveDeliveredPayload", time.Now())
+
 	_signedBlindedBeaconBlock, err := json.Marshal(signedBlindedBeaconBlock)
 	if err != nil {
 		return err
@@ -277,6 +378,8 @@ func (s *DatabaseService) SaveDeliveredPayload(bidTrace *common.BidTraceV2, sign
 }
 
 func (s *DatabaseService) GetRecentDeliveredPayloads(queryArgs GetPayloadsFilters) ([]*DeliveredPayloadEntry, error) {
+	defer observeCallDuration("GetRecentDeliveredPayloads", time.Now())
+
 	arg := map[string]interface{}{
 		"limit":           queryArgs.Limit,
 		"slot":            queryArgs.Slot,
@@ -307,6 +410,7 @@ func (s *DatabaseService) GetRecentDeliveredPayloads(queryArgs GetPayloadsFilter
 	if queryArgs.BuilderPubkey != "" {
 		whereConds = append(whereConds, "builder_pubkey = :builder_pubkey")
 	}
+	whereConds = append(whereConds, queryArgs.BidFilters.whereConds("inserted_at", arg)...)
 
 	where := ""
 	if len(whereConds) > 0 {
@@ -341,6 +445,8 @@ func (s *DatabaseService) GetRecentDeliveredPayloads(queryArgs GetPayloadsFilter
 }
 
 func (s *DatabaseService) GetDeliveredPayloads(idFirst, idLast uint64) (entries []*DeliveredPayloadEntry, err error) {
+	defer observeCallDuration("GetDeliveredPayloads", time.Now())
+
 	query := `SELECT id, inserted_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit
 	FROM ` + vars.TableDeliveredPayload + `
 	WHERE id >= $1 AND id <= $2
@@ -351,12 +457,16 @@ func (s *DatabaseService) GetDeliveredPayloads(idFirst, idLast uint64) (entries
 }
 
 func (s *DatabaseService) GetNumDeliveredPayloads() (uint64, error) {
+	defer observeCallDuration("GetNumDeliveredPayloads", time.Now())
+
 	var count uint64
 	err := s.DB.QueryRow("SELECT COUNT(*) FROM " + vars.TableDeliveredPayload).Scan(&count)
 	return count, err
 }
 
 func (s *DatabaseService) GetBuilderSubmissions(filters GetBuilderSubmissionsFilters) ([]*BuilderBlockSubmissionEntry, error) {
+	defer observeCallDuration("GetBuilderSubmissions", time.Now())
+
 	arg := map[string]interface{}{
 		"limit":          filters.Limit,
 		"slot":           filters.Slot,
@@ -366,6 +476,11 @@ func (s *DatabaseService) GetBuilderSubmissions(filters GetBuilderSubmissionsFil
 	}
 
 	fields := "id, inserted_at, received_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit"
+	column := filters.OrderBy.column()
+	direction, cmp := "ASC", ">"
+	if filters.OrderBy.isDesc() {
+		direction, cmp = "DESC", "<"
+	}
 	limit := "LIMIT :limit"
 
 	whereConds := []string{
@@ -385,13 +500,26 @@ func (s *DatabaseService) GetBuilderSubmissions(filters GetBuilderSubmissionsFil
 	if filters.BuilderPubkey != "" {
 		whereConds = append(whereConds, "builder_pubkey = :builder_pubkey")
 	}
+	if column == "received_at" {
+		whereConds = append(whereConds, "received_at IS NOT NULL")
+	}
+	whereConds = append(whereConds, filters.BidFilters.whereConds("received_at", arg)...)
+	if filters.Cursor != "" {
+		cursorKey, cursorID, err := parseBuilderSubmissionsCursor(filters.Cursor, filters.OrderBy)
+		if err != nil {
+			return nil, err
+		}
+		arg["cursor_key"] = cursorKey
+		arg["cursor_id"] = cursorID
+		whereConds = append(whereConds, fmt.Sprintf("(%s, id) %s (:cursor_key, :cursor_id)", column, cmp))
+	}
 
 	where := ""
 	if len(whereConds) > 0 {
 		where = "WHERE " + strings.Join(whereConds, " AND ")
 	}
 
-	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY slot DESC, inserted_at DESC %s", fields, vars.TableBuilderBlockSubmission, where, limit)
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY %s %s, id %s %s", fields, vars.TableBuilderBlockSubmission, where, column, direction, direction, limit)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -412,6 +540,8 @@ func (s *DatabaseService) GetBuilderSubmissions(filters GetBuilderSubmissionsFil
 }
 
 func (s *DatabaseService) GetBuilderSubmissionsBySlots(slotFrom, slotTo uint64) (entries []*BuilderBlockSubmissionEntry, err error) {
+	defer observeCallDuration("GetBuilderSubmissionsBySlots", time.Now())
+
 	query := `SELECT id, inserted_at, received_at, slot, epoch, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, num_tx, value, gas_used, gas_limit
 	FROM ` + vars.TableBuilderBlockSubmission + `
 	WHERE sim_success = true AND slot >= $1 AND slot <= $2
@@ -421,7 +551,70 @@ func (s *DatabaseService) GetBuilderSubmissionsBySlots(slotFrom, slotTo uint64)
 	return entries, err
 }
 
+func (s *DatabaseService) SaveGetHeaderServed(entry GetHeaderServedEntry) error {
+	defer observeCallDuration("SaveGetHeaderServed", time.Now())
+
+	query := `INSERT INTO ` + vars.TableGetHeaderServed + `
+		(served_at, slot, epoch, parent_hash, proposer_pubkey, block_hash, value, user_agent, mev_boost_version, ip) VALUES
+		(:served_at, :slot, :epoch, :parent_hash, :proposer_pubkey, :block_hash, :value, :user_agent, :mev_boost_version, :ip)`
+	_, err := s.DB.NamedExec(query, entry)
+	return err
+}
+
+func (s *DatabaseService) GetGetHeaderServed(filters GetHeaderServedFilters) ([]*GetHeaderServedEntry, error) {
+	defer observeCallDuration("GetGetHeaderServed", time.Now())
+
+	arg := map[string]interface{}{
+		"limit":           filters.Limit,
+		"slot":            filters.Slot,
+		"cursor":          filters.Cursor,
+		"block_hash":      filters.BlockHash,
+		"proposer_pubkey": filters.ProposerPubkey,
+	}
+
+	fields := "id, inserted_at, served_at, slot, epoch, parent_hash, proposer_pubkey, block_hash, value, user_agent, mev_boost_version, ip"
+
+	whereConds := []string{}
+	if filters.Slot > 0 {
+		whereConds = append(whereConds, "slot = :slot")
+	} else if filters.Cursor > 0 {
+		whereConds = append(whereConds, "slot <= :cursor")
+	}
+	if filters.BlockHash != "" {
+		whereConds = append(whereConds, "block_hash = :block_hash")
+	}
+	if filters.ProposerPubkey != "" {
+		whereConds = append(whereConds, "proposer_pubkey = :proposer_pubkey")
+	}
+
+	where := ""
+	if len(whereConds) > 0 {
+		where = "WHERE " + strings.Join(whereConds, " AND ")
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s %s ORDER BY slot DESC, served_at DESC LIMIT :limit", fields, vars.TableGetHeaderServed, where)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries := []*GetHeaderServedEntry{}
+	rows, err := s.DB.NamedQueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		entry := new(GetHeaderServedEntry)
+		err = rows.StructScan(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
 func (s *DatabaseService) UpsertBlockBuilderEntryAfterSubmission(lastSubmission *BuilderBlockSubmissionEntry, isError bool) error {
+	defer observeCallDuration("UpsertBlockBuilderEntryAfterSubmission", time.Now())
+
 	entry := BlockBuilderEntry{
 		BuilderPubkey:          lastSubmission.BuilderPubkey,
 		LastSubmissionID:       NewNullInt64(lastSubmission.ID),
@@ -447,26 +640,43 @@ func (s *DatabaseService) UpsertBlockBuilderEntryAfterSubmission(lastSubmission
 }
 
 func (s *DatabaseService) GetBlockBuilders() ([]*BlockBuilderEntry, error) {
-	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload FROM ` + vars.TableBlockBuilder + ` ORDER BY id ASC;`
+	defer observeCallDuration("GetBlockBuilders", time.Now())
+
+	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload, collateral FROM ` + vars.TableBlockBuilder + ` ORDER BY id ASC;`
 	entries := []*BlockBuilderEntry{}
 	err := s.DB.Select(&entries, query)
 	return entries, err
 }
 
 func (s *DatabaseService) GetBlockBuilderByPubkey(pubkey string) (*BlockBuilderEntry, error) {
-	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload FROM ` + vars.TableBlockBuilder + ` WHERE builder_pubkey=$1;`
+	defer observeCallDuration("GetBlockBuilderByPubkey", time.Now())
+
+	query := `SELECT id, inserted_at, builder_pubkey, description, is_high_prio, is_blacklisted, last_submission_id, last_submission_slot, num_submissions_total, num_submissions_simerror, num_sent_getpayload, collateral FROM ` + vars.TableBlockBuilder + ` WHERE builder_pubkey=$1;`
 	entry := &BlockBuilderEntry{}
 	err := s.DB.Get(entry, query, pubkey)
 	return entry, err
 }
 
 func (s *DatabaseService) SetBlockBuilderStatus(pubkey string, isHighPrio, isBlacklisted bool) error {
+	defer observeCallDuration("SetBlockBuilderStatus", time.Now())
+
 	query := `UPDATE ` + vars.TableBlockBuilder + ` SET is_high_prio=$1, is_blacklisted=$2 WHERE builder_pubkey=$3;`
 	_, err := s.DB.Exec(query, isHighPrio, isBlacklisted, pubkey)
 	return err
 }
 
+// DemoteBlockBuilder removes the high-prio status of a builder, without changing whether it is blacklisted
+func (s *DatabaseService) DemoteBlockBuilder(pubkey string) error {
+	defer observeCallDuration("DemoteBlockBuilder", time.Now())
+
+	query := `UPDATE ` + vars.TableBlockBuilder + ` SET is_high_prio=false WHERE builder_pubkey=$1;`
+	_, err := s.DB.Exec(query, pubkey)
+	return err
+}
+
 func (s *DatabaseService) IncBlockBuilderStatsAfterGetPayload(builderPubkey string) error {
+	defer observeCallDuration("IncBlockBuilderStatsAfterGetPayload", time.Now())
+
 	query := `UPDATE ` + vars.TableBlockBuilder + `
 		SET num_sent_getpayload=num_sent_getpayload+1
 		WHERE builder_pubkey=$1;`
@@ -475,13 +685,201 @@ func (s *DatabaseService) IncBlockBuilderStatsAfterGetPayload(builderPubkey stri
 }
 
 func (s *DatabaseService) GetExecutionPayloads(idFirst, idLast uint64) (entries []*ExecutionPayloadEntry, err error) {
+	defer observeCallDuration("GetExecutionPayloads", time.Now())
+
 	query := `SELECT id, inserted_at, slot, proposer_pubkey, block_hash, version, payload FROM ` + vars.TableExecutionPayload + ` WHERE id >= $1 AND id <= $2 ORDER BY id ASC`
 	err = s.DB.Select(&entries, query, idFirst, idLast)
 	return entries, err
 }
 
 func (s *DatabaseService) DeleteExecutionPayloads(idFirst, idLast uint64) error {
+	defer observeCallDuration("DeleteExecutionPayloads", time.Now())
+
 	query := `DELETE FROM ` + vars.TableExecutionPayload + ` WHERE id >= $1 AND id <= $2`
 	_, err := s.DB.Exec(query, idFirst, idLast)
 	return err
 }
+
+func (s *DatabaseService) SetBlockBuilderCollateral(pubkey, collateral string) error {
+	defer observeCallDuration("SetBlockBuilderCollateral", time.Now())
+
+	query := `UPDATE ` + vars.TableBlockBuilder + ` SET collateral=$1 WHERE builder_pubkey=$2;`
+	_, err := s.DB.Exec(query, collateral, pubkey)
+	return err
+}
+
+// InsertBuilderDemotion records a failed simulation of an optimistically accepted block submission
+func (s *DatabaseService) InsertBuilderDemotion(submitBlockRequest *common.BuilderSubmitBlockRequest, simError error) error {
+	defer observeCallDuration("InsertBuilderDemotion", time.Now())
+
+	_submitBlockRequest, err := json.Marshal(submitBlockRequest)
+	if err != nil {
+		return err
+	}
+
+	simErrStr := ""
+	if simError != nil {
+		simErrStr = simError.Error()
+	}
+
+	entry := BuilderDemotionEntry{
+		Slot:  submitBlockRequest.Slot(),
+		Epoch: submitBlockRequest.Slot() / uint64(common.SlotsPerEpoch),
+
+		BuilderPubkey:  submitBlockRequest.Message().BuilderPubkey.String(),
+		ProposerPubkey: submitBlockRequest.Message().ProposerPubkey.String(),
+		BlockHash:      submitBlockRequest.BlockHash().String(),
+		Value:          submitBlockRequest.Message().Value.String(),
+
+		SimError:           simErrStr,
+		SubmitBlockRequest: NewNullString(string(_submitBlockRequest)),
+	}
+
+	// if the payload was already delivered, the collateral is flagged for refund right away (otherwise UpdateBuilderDemotion does it on delivery)
+	query := `INSERT INTO ` + vars.TableBuilderDemotions + `
+		(slot, epoch, builder_pubkey, proposer_pubkey, block_hash, value, sim_error, submit_block_request, signed_blinded_beacon_block, refund_pending)
+		SELECT :slot, :epoch, :builder_pubkey, :proposer_pubkey, :block_hash, :value, :sim_error, :submit_block_request, delivered.signed_blinded_beacon_block, delivered.id IS NOT NULL
+		FROM (SELECT 1) AS demotion
+		LEFT JOIN (
+			SELECT id, signed_blinded_beacon_block FROM ` + vars.TableDeliveredPayload + ` WHERE slot=:slot AND block_hash=:block_hash LIMIT 1
+		) AS delivered ON true`
+	_, err = s.DB.NamedExec(query, entry)
+	return err
+}
+
+// UpdateBuilderDemotion flags the collateral of a demotion for refund processing if the payload of the
+// demoted block was delivered. refundPending is false if no demotion exists for the delivered block.
+func (s *DatabaseService) UpdateBuilderDemotion(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *common.SignedBlindedBeaconBlock) (refundPending bool, err error) {
+	defer observeCallDuration("UpdateBuilderDemotion", time.Now())
+
+	_signedBlindedBeaconBlock, err := json.Marshal(signedBlindedBeaconBlock)
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE ` + vars.TableBuilderDemotions + `
+		SET signed_blinded_beacon_block=$1, refund_pending=true
+		WHERE slot=$2 AND builder_pubkey=$3 AND block_hash=$4;`
+	res, err := s.DB.Exec(query, string(_signedBlindedBeaconBlock), bidTrace.Slot, bidTrace.BuilderPubkey.String(), bidTrace.BlockHash.String())
+	if err != nil {
+		return false, err
+	}
+	numRows, err := res.RowsAffected()
+	return numRows > 0, err
+}
+
+// UpdateStatsRollups recomputes the daily and per-builder stats of the day (UTC) from the delivered payloads and
+// builder submissions
+func (s *DatabaseService) UpdateStatsRollups(day time.Time) error {
+	defer observeCallDuration("UpdateStatsRollups", time.Now())
+
+	queryDaily := `INSERT INTO ` + vars.TableStatsDaily + `
+		(day, updated_at, num_payloads_delivered, total_value, median_value, avg_num_tx, num_submissions)
+		SELECT $1::date, current_timestamp, delivered.num, delivered.total_value, delivered.median_value, delivered.avg_num_tx, submissions.num
+		FROM (
+			SELECT COUNT(*) AS num, COALESCE(SUM(value), 0) AS total_value,
+				COALESCE(percentile_disc(0.5) WITHIN GROUP (ORDER BY value), 0) AS median_value, COALESCE(AVG(num_tx), 0) AS avg_num_tx
+			FROM ` + vars.TableDeliveredPayload + ` WHERE inserted_at >= $1::date AND inserted_at < $1::date + 1
+		) AS delivered, (
+			SELECT COUNT(*) AS num FROM ` + vars.TableBuilderBlockSubmission + ` WHERE received_at >= $1::date AND received_at < $1::date + 1
+		) AS submissions
+		ON CONFLICT (day) DO UPDATE SET
+			updated_at = EXCLUDED.updated_at,
+			num_payloads_delivered = EXCLUDED.num_payloads_delivered,
+			total_value = EXCLUDED.total_value,
+			median_value = EXCLUDED.median_value,
+			avg_num_tx = EXCLUDED.avg_num_tx,
+			num_submissions = EXCLUDED.num_submissions;`
+
+	queryBuilders := `INSERT INTO ` + vars.TableStatsBuilderDaily + `
+		(day, builder_pubkey, updated_at, num_payloads_delivered, total_value, median_value, avg_num_tx, num_submissions)
+		SELECT $1::date, COALESCE(delivered.builder_pubkey, submissions.builder_pubkey), current_timestamp, COALESCE(delivered.num, 0),
+			COALESCE(delivered.total_value, 0), COALESCE(delivered.median_value, 0), COALESCE(delivered.avg_num_tx, 0), COALESCE(submissions.num, 0)
+		FROM (
+			SELECT builder_pubkey, COUNT(*) AS num, SUM(value) AS total_value,
+				percentile_disc(0.5) WITHIN GROUP (ORDER BY value) AS median_value, AVG(num_tx) AS avg_num_tx
+			FROM ` + vars.TableDeliveredPayload + ` WHERE inserted_at >= $1::date AND inserted_at < $1::date + 1
+			GROUP BY builder_pubkey
+		) AS delivered FULL OUTER JOIN (
+			SELECT builder_pubkey, COUNT(*) AS num
+			FROM ` + vars.TableBuilderBlockSubmission + ` WHERE received_at >= $1::date AND received_at < $1::date + 1
+			GROUP BY builder_pubkey
+		) AS submissions ON delivered.builder_pubkey = submissions.builder_pubkey
+		ON CONFLICT (day, builder_pubkey) DO UPDATE SET
+			updated_at = EXCLUDED.updated_at,
+			num_payloads_delivered = EXCLUDED.num_payloads_delivered,
+			total_value = EXCLUDED.total_value,
+			median_value = EXCLUDED.median_value,
+			avg_num_tx = EXCLUDED.avg_num_tx,
+			num_submissions = EXCLUDED.num_submissions;`
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, query := range []string{queryDaily, queryBuilders} {
+		if _, err = tx.Exec(query, dayArg(day)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDailyStats returns the daily stats from fromDay to toDay (inclusive), newest first
+func (s *DatabaseService) GetDailyStats(fromDay, toDay time.Time) (entries []*DailyStatsEntry, err error) {
+	defer observeCallDuration("GetDailyStats", time.Now())
+
+	query := `SELECT day, updated_at, num_payloads_delivered, total_value, median_value, avg_num_tx, num_submissions
+		FROM ` + vars.TableStatsDaily + `
+		WHERE day >= $1::date AND day <= $2::date
+		ORDER BY day DESC;`
+	err = s.DB.Select(&entries, query, dayArg(fromDay), dayArg(toDay))
+	return entries, err
+}
+
+// GetBuilderDailyStats returns the stats of the builders on the day, with the most delivered payloads first
+func (s *DatabaseService) GetBuilderDailyStats(day time.Time) (entries []*BuilderDailyStatsEntry, err error) {
+	defer observeCallDuration("GetBuilderDailyStats", time.Now())
+
+	query := `SELECT stats.day, stats.builder_pubkey, stats.updated_at, stats.num_payloads_delivered, stats.total_value, stats.median_value,
+			stats.avg_num_tx, stats.num_submissions, COALESCE(builder.num_submissions_total, 0) AS num_submissions_total,
+			COALESCE(builder.num_submissions_simerror, 0) AS num_submissions_simerror
+		FROM ` + vars.TableStatsBuilderDaily + ` AS stats
+		LEFT JOIN ` + vars.TableBlockBuilder + ` AS builder ON builder.builder_pubkey = stats.builder_pubkey
+		WHERE stats.day = $1::date
+		ORDER BY stats.num_payloads_delivered DESC, stats.num_submissions DESC, stats.builder_pubkey ASC;`
+	err = s.DB.Select(&entries, query, dayArg(day))
+	return entries, err
+}
+
+func (s *DatabaseService) InsertDataAPIKey(keyHash, description string) (id int64, err error) {
+	defer observeCallDuration("InsertDataAPIKey", time.Now())
+
+	query := `INSERT INTO ` + vars.TableDataAPIKeys + ` (key_hash, description) VALUES ($1, $2) RETURNING id;`
+	err = s.DB.QueryRow(query, keyHash, description).Scan(&id)
+	return id, err
+}
+
+// GetDataAPIKeys returns all data API keys, including the revoked ones
+func (s *DatabaseService) GetDataAPIKeys() (entries []*DataAPIKeyEntry, err error) {
+	defer observeCallDuration("GetDataAPIKeys", time.Now())
+
+	query := `SELECT id, inserted_at, key_hash, description, revoked_at FROM ` + vars.TableDataAPIKeys + ` ORDER BY id ASC;`
+	err = s.DB.Select(&entries, query)
+	return entries, err
+}
+
+// RevokeDataAPIKey revokes the key. revoked is false if no unrevoked key with the id exists.
+func (s *DatabaseService) RevokeDataAPIKey(id int64) (revoked bool, err error) {
+	defer observeCallDuration("RevokeDataAPIKey", time.Now())
+
+	query := `UPDATE ` + vars.TableDataAPIKeys + ` SET revoked_at=current_timestamp WHERE id=$1 AND revoked_at IS NULL;`
+	res, err := s.DB.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	numRows, err := res.RowsAffected()
+	return numRows > 0, err
+}
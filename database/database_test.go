@@ -1,8 +1,10 @@
 package database
 
 import (
+	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database/migrations"
@@ -129,6 +131,342 @@ func TestSaveValidatorRegistration(t *testing.T) {
 	require.Equal(t, uint64(3), cnt)
 }
 
+func TestValidatorRegistrationHistory(t *testing.T) {
+	db := resetDatabase(t)
+	pubkey1 := "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+	pubkey2 := "0x9996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+	pubkey3 := "0xa996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+	feeRecipient2 := "0xafbb8996515293fcd87ca09b5c6ffe5c17f043c6"
+
+	// pubkey1 changes the gas limit, and then the fee recipient
+	reg1 := createValidatorRegistration(pubkey1)
+	reg2 := createValidatorRegistration(pubkey1)
+	reg2.Timestamp = reg1.Timestamp + 1
+	reg2.GasLimit = reg1.GasLimit + 1
+	reg3 := createValidatorRegistration(pubkey1)
+	reg3.Timestamp = reg1.Timestamp + 2
+	reg3.FeeRecipient = feeRecipient2
+
+	// pubkey2 and pubkey3 keep the initial fee recipient
+	for _, reg := range []ValidatorRegistrationEntry{reg1, reg2, reg3, createValidatorRegistration(pubkey2), createValidatorRegistration(pubkey3)} {
+		require.NoError(t, db.SaveValidatorRegistration(reg))
+	}
+
+	t.Run("history", func(t *testing.T) {
+		entries, err := db.GetValidatorRegistrationHistory(pubkey1, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		for i, reg := range []ValidatorRegistrationEntry{reg3, reg2, reg1} {
+			require.Equal(t, reg.Timestamp, entries[i].Timestamp)
+			require.Equal(t, reg.FeeRecipient, entries[i].FeeRecipient)
+			require.Equal(t, reg.GasLimit, entries[i].GasLimit)
+		}
+
+		// two pages, the cursor is the timestamp of the last entry
+		entries, err = db.GetValidatorRegistrationHistory(pubkey1, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, reg3.Timestamp, entries[0].Timestamp)
+		require.Equal(t, reg2.Timestamp, entries[1].Timestamp)
+
+		entries, err = db.GetValidatorRegistrationHistory(pubkey1, entries[1].Timestamp, 2)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, reg1.Timestamp, entries[0].Timestamp)
+	})
+
+	t.Run("by fee recipient", func(t *testing.T) {
+		// pubkey1 doesn't use the initial fee recipient anymore
+		entries, err := db.GetValidatorRegistrationsByFeeRecipient(reg1.FeeRecipient, "", 10)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, pubkey2, entries[0].Pubkey)
+		require.Equal(t, pubkey3, entries[1].Pubkey)
+
+		// next page
+		entries, err = db.GetValidatorRegistrationsByFeeRecipient(reg1.FeeRecipient, pubkey2, 10)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, pubkey3, entries[0].Pubkey)
+
+		entries, err = db.GetValidatorRegistrationsByFeeRecipient(feeRecipient2, "", 10)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, reg3.Timestamp, entries[0].Timestamp)
+	})
+}
+
+func TestBuilderSubmissionsCursor(t *testing.T) {
+	entry := &BuilderBlockSubmissionEntry{
+		ID:         42,
+		ReceivedAt: NewNullTime(time.Date(2023, 3, 1, 12, 0, 0, 123456000, time.UTC)),
+		Slot:       5000000,
+		Value:      "123456789012345678901234567890",
+	}
+
+	testCases := []struct {
+		order  BuilderSubmissionsOrder
+		cursor string
+		key    interface{}
+	}{
+		{order: "", cursor: "5000000_42", key: uint64(5000000)},
+		{order: BuilderSubmissionsOrderValueDesc, cursor: "123456789012345678901234567890_42", key: "123456789012345678901234567890"},
+		{order: BuilderSubmissionsOrderReceivedAtAsc, cursor: "1677672000123456_42", key: "2023-03-01 12:00:00.123456"},
+	}
+	for _, tc := range testCases {
+		cursor := BuilderSubmissionsCursor(entry, tc.order)
+		require.Equal(t, tc.cursor, cursor)
+		key, id, err := parseBuilderSubmissionsCursor(cursor, tc.order)
+		require.NoError(t, err)
+		require.Equal(t, tc.key, key)
+		require.Equal(t, int64(42), id)
+	}
+
+	for _, cursor := range []string{"", "5000000", "5000000_x", "x_42", "-1_42"} {
+		require.ErrorIs(t, ValidateBuilderSubmissionsCursor(cursor, BuilderSubmissionsOrderSlotDesc), ErrInvalidCursor, cursor)
+	}
+	require.ErrorIs(t, ValidateBuilderSubmissionsCursor("1.5_42", BuilderSubmissionsOrderValueAsc), ErrInvalidCursor)
+}
+
+func TestGetBuilderSubmissionsPagination(t *testing.T) {
+	db := resetDatabase(t)
+
+	// two submissions per slot, with the values in reverse order
+	receivedAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		entry := BuilderBlockSubmissionEntry{
+			ReceivedAt:           NewNullTime(receivedAt.Add(time.Duration(i) * time.Millisecond)),
+			SimSuccess:           true,
+			Slot:                 uint64(100 + i/2),
+			ParentHash:           "0xbd3291854dc822b7ec585925cda0e18f06af28fa2886e15f52d52dd4b6f94ed6",
+			BlockHash:            fmt.Sprintf("0x%064x", i),
+			BuilderPubkey:        "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908",
+			ProposerPubkey:       "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908",
+			ProposerFeeRecipient: "0xffbb8996515293fcd87ca09b5c6ffe5c17f043c6",
+			Value:                fmt.Sprint(1000 - i),
+		}
+		_, err := db.nstmtInsertBlockBuilderSubmission.Exec(entry)
+		require.NoError(t, err)
+	}
+
+	// paginate in pages of 4, returns the block hash indexes
+	paginate := func(order BuilderSubmissionsOrder) (indexes []int) {
+		t.Helper()
+		filters := GetBuilderSubmissionsFilters{Limit: 4, OrderBy: order}
+		for {
+			entries, err := db.GetBuilderSubmissions(filters)
+			require.NoError(t, err)
+			for _, entry := range entries {
+				var i int
+				_, err = fmt.Sscanf(entry.BlockHash, "0x%x", &i)
+				require.NoError(t, err)
+				indexes = append(indexes, i)
+			}
+			if len(entries) < int(filters.Limit) {
+				return indexes
+			}
+			filters.Cursor = BuilderSubmissionsCursor(entries[len(entries)-1], order)
+		}
+	}
+
+	require.Equal(t, []int{5, 4, 3, 2, 1, 0}, paginate(BuilderSubmissionsOrderSlotDesc))
+	require.Equal(t, []int{5, 4, 3, 2, 1, 0}, paginate(BuilderSubmissionsOrderValueAsc))
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5}, paginate(BuilderSubmissionsOrderValueDesc))
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5}, paginate(BuilderSubmissionsOrderReceivedAtAsc))
+	require.Equal(t, []int{5, 4, 3, 2, 1, 0}, paginate(BuilderSubmissionsOrderReceivedAtDesc))
+}
+
+func TestBidFilters(t *testing.T) {
+	db := resetDatabase(t)
+	feeRecipient := "0xffbb8996515293fcd87ca09b5c6ffe5c17f043c6"
+	otherFeeRecipient := "0xafbb8996515293fcd87ca09b5c6ffe5c17f043c6"
+	start := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// one submission and delivered payload per slot, every 12 seconds, with increasing values
+	for i := 0; i < 4; i++ {
+		entry := BuilderBlockSubmissionEntry{
+			InsertedAt:           start.Add(time.Duration(i) * 12 * time.Second),
+			ReceivedAt:           NewNullTime(start.Add(time.Duration(i) * 12 * time.Second)),
+			SimSuccess:           true,
+			Slot:                 uint64(100 + i),
+			ParentHash:           "0xbd3291854dc822b7ec585925cda0e18f06af28fa2886e15f52d52dd4b6f94ed6",
+			BlockHash:            fmt.Sprintf("0x%064x", i),
+			BuilderPubkey:        "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908",
+			ProposerPubkey:       "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908",
+			ProposerFeeRecipient: feeRecipient,
+			Value:                fmt.Sprint(1000 * (i + 1)),
+		}
+		if i == 3 {
+			entry.ProposerFeeRecipient = otherFeeRecipient
+		}
+		_, err := db.nstmtInsertBlockBuilderSubmission.Exec(entry)
+		require.NoError(t, err)
+
+		_, err = db.DB.NamedExec(`INSERT INTO `+vars.TableDeliveredPayload+`
+			(inserted_at, epoch, slot, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, gas_used, gas_limit, num_tx, value) VALUES
+			(:inserted_at, :epoch, :slot, :builder_pubkey, :proposer_pubkey, :proposer_fee_recipient, :parent_hash, :block_hash, :block_number, :gas_used, :gas_limit, :num_tx, :value)`, entry)
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name    string
+		filters BidFilters
+		slots   []uint64
+	}{
+		{name: "no filters", filters: BidFilters{}, slots: []uint64{103, 102, 101, 100}},
+		{name: "slot range", filters: BidFilters{SlotFrom: 101, SlotTo: 102}, slots: []uint64{102, 101}},
+		{name: "time range", filters: BidFilters{FromTime: start.Add(12 * time.Second), ToTime: start.Add(24 * time.Second)}, slots: []uint64{102, 101}},
+		{name: "fee recipient", filters: BidFilters{FeeRecipient: otherFeeRecipient}, slots: []uint64{103}},
+		{name: "min value", filters: BidFilters{MinValue: "3000"}, slots: []uint64{103, 102}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			submissions, err := db.GetBuilderSubmissions(GetBuilderSubmissionsFilters{BidFilters: tc.filters, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, submissions, len(tc.slots))
+			for i, slot := range tc.slots {
+				require.Equal(t, slot, submissions[i].Slot)
+			}
+
+			payloads, err := db.GetRecentDeliveredPayloads(GetPayloadsFilters{BidFilters: tc.filters, Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, payloads, len(tc.slots))
+			for i, slot := range tc.slots {
+				require.Equal(t, slot, payloads[i].Slot)
+			}
+		})
+	}
+}
+
+func TestStatsRollups(t *testing.T) {
+	db := resetDatabase(t)
+	builder1 := "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+	builder2 := "0xa996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908"
+	day := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// builder1 delivers three payloads and builder2 submits once on the day, builder2 delivers one payload the next day
+	insert := func(i int, builderPubkey string, at time.Time, value, numTx uint64, delivered bool) {
+		t.Helper()
+		entry := BuilderBlockSubmissionEntry{
+			InsertedAt:           at,
+			ReceivedAt:           NewNullTime(at),
+			SimSuccess:           true,
+			Slot:                 uint64(100 + i),
+			ParentHash:           "0xbd3291854dc822b7ec585925cda0e18f06af28fa2886e15f52d52dd4b6f94ed6",
+			BlockHash:            fmt.Sprintf("0x%064x", i),
+			BuilderPubkey:        builderPubkey,
+			ProposerPubkey:       builder1,
+			ProposerFeeRecipient: "0xffbb8996515293fcd87ca09b5c6ffe5c17f043c6",
+			NumTx:                numTx,
+			Value:                fmt.Sprint(value),
+		}
+		_, err := db.nstmtInsertBlockBuilderSubmission.Exec(entry)
+		require.NoError(t, err)
+		if delivered {
+			_, err = db.DB.NamedExec(`INSERT INTO `+vars.TableDeliveredPayload+`
+				(inserted_at, epoch, slot, builder_pubkey, proposer_pubkey, proposer_fee_recipient, parent_hash, block_hash, block_number, gas_used, gas_limit, num_tx, value) VALUES
+				(:inserted_at, :epoch, :slot, :builder_pubkey, :proposer_pubkey, :proposer_fee_recipient, :parent_hash, :block_hash, :block_number, :gas_used, :gas_limit, :num_tx, :value)`, entry)
+			require.NoError(t, err)
+		}
+	}
+	insert(0, builder1, day.Add(time.Hour), 100, 10, true)
+	insert(1, builder1, day.Add(2*time.Hour), 300, 20, true)
+	insert(2, builder1, day.Add(3*time.Hour), 200, 60, true)
+	insert(3, builder2, day.Add(4*time.Hour), 50, 5, false)
+	insert(4, builder2, day.Add(25*time.Hour), 400, 40, true)
+
+	_, err := db.DB.Exec(`INSERT INTO `+vars.TableBlockBuilder+`
+		(builder_pubkey, description, is_high_prio, is_blacklisted, last_submission_slot, num_submissions_total, num_submissions_simerror) VALUES
+		($1, '', false, false, 102, 4, 1)`, builder1)
+	require.NoError(t, err)
+
+	// rollups can be recomputed
+	for i := 0; i < 2; i++ {
+		require.NoError(t, db.UpdateStatsRollups(day))
+		require.NoError(t, db.UpdateStatsRollups(day.AddDate(0, 0, 1)))
+	}
+
+	daily, err := db.GetDailyStats(day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, daily, 2)
+	require.Equal(t, day.AddDate(0, 0, 1), daily[0].Day.UTC())
+	require.Equal(t, uint64(1), daily[0].NumPayloadsDelivered)
+	require.Equal(t, day, daily[1].Day.UTC())
+	require.Equal(t, uint64(3), daily[1].NumPayloadsDelivered)
+	require.Equal(t, "600", daily[1].TotalValue)
+	require.Equal(t, "200", daily[1].MedianValue)
+	require.InDelta(t, 30, daily[1].AvgNumTx, 1e-9)
+	require.Equal(t, uint64(4), daily[1].NumSubmissions)
+
+	builders, err := db.GetBuilderDailyStats(day)
+	require.NoError(t, err)
+	require.Len(t, builders, 2)
+	require.Equal(t, builder1, builders[0].BuilderPubkey)
+	require.Equal(t, uint64(3), builders[0].NumPayloadsDelivered)
+	require.Equal(t, uint64(3), builders[0].NumSubmissions)
+	require.Equal(t, uint64(4), builders[0].NumSubmissionsTotal)
+	require.Equal(t, uint64(1), builders[0].NumSubmissionsSimError)
+	require.Equal(t, builder2, builders[1].BuilderPubkey)
+	require.Equal(t, uint64(0), builders[1].NumPayloadsDelivered)
+	require.Equal(t, "0", builders[1].TotalValue)
+	require.Equal(t, uint64(1), builders[1].NumSubmissions)
+	require.Equal(t, uint64(0), builders[1].NumSubmissionsTotal)
+}
+
+func TestDemoteBlockBuilder(t *testing.T) {
+	db := resetDatabase(t)
+
+	_, err := db.DB.Exec(`INSERT INTO ` + vars.TableBlockBuilder + `
+		(builder_pubkey, description, is_high_prio, is_blacklisted, last_submission_slot, num_submissions_total, num_submissions_simerror) VALUES
+		('0xb1', '', true, false, 0, 0, 0), ('0xb2', '', true, true, 0, 0, 0)`)
+	require.NoError(t, err)
+
+	for _, pubkey := range []string{"0xb1", "0xb2"} {
+		require.NoError(t, db.DemoteBlockBuilder(pubkey))
+	}
+
+	builder, err := db.GetBlockBuilderByPubkey("0xb1")
+	require.NoError(t, err)
+	require.False(t, builder.IsHighPrio)
+	require.False(t, builder.IsBlacklisted)
+
+	// a blacklisted builder stays blacklisted
+	builder, err = db.GetBlockBuilderByPubkey("0xb2")
+	require.NoError(t, err)
+	require.False(t, builder.IsHighPrio)
+	require.True(t, builder.IsBlacklisted)
+}
+
+func TestDataAPIKeys(t *testing.T) {
+	db := resetDatabase(t)
+
+	id1, err := db.InsertDataAPIKey(HashDataAPIKey("key1"), "first")
+	require.NoError(t, err)
+	id2, err := db.InsertDataAPIKey(HashDataAPIKey("key2"), "second")
+	require.NoError(t, err)
+
+	// keys are unique
+	_, err = db.InsertDataAPIKey(HashDataAPIKey("key1"), "duplicate")
+	require.Error(t, err)
+
+	revoked, err := db.RevokeDataAPIKey(id1)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	revoked, err = db.RevokeDataAPIKey(id1)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	entries, err := db.GetDataAPIKeys()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, id1, entries[0].ID)
+	require.True(t, entries[0].RevokedAt.Valid)
+	require.Equal(t, id2, entries[1].ID)
+	require.Equal(t, HashDataAPIKey("key2"), entries[1].KeyHash)
+	require.Equal(t, "second", entries[1].Description)
+	require.False(t, entries[1].RevokedAt.Valid)
+}
+
 func TestMigrations(t *testing.T) {
 	db := resetDatabase(t)
 	query := `SELECT COUNT(*) FROM ` + vars.TableMigrations + `;`
@@ -137,3 +475,48 @@ func TestMigrations(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, len(migrations.Migrations.Migrations), rowCount)
 }
+
+func TestGetHeaderServed(t *testing.T) {
+	db := resetDatabase(t)
+
+	entry := GetHeaderServedEntry{
+		ServedAt:        time.Now().UTC(),
+		Slot:            100,
+		Epoch:           3,
+		ParentHash:      "0xbd3291854dc822b7ec585925cda0e18f06af28fa2886e15f52d52dd4b6f94ed6",
+		ProposerPubkey:  "0x8996515293fcd87ca09b5c6ffe5c17f043c6a1a3639cc9494a82ec8eb50a9b55c34b47675e573be40d9be308b1ca2908",
+		BlockHash:       "0xa645370cc112c2e8e3cce121416c7dc849e773506d4b6fb9b752ada711355369",
+		Value:           "123",
+		UserAgent:       "mev-boost/v1.5.0 Go-http-client/1.1",
+		MevBoostVersion: "v1.5.0",
+		IP:              "127.0.0.1",
+	}
+	err := db.SaveGetHeaderServed(entry)
+	require.NoError(t, err)
+
+	entry2 := entry
+	entry2.Slot = 101
+	entry2.BlockHash = "0xb645370cc112c2e8e3cce121416c7dc849e773506d4b6fb9b752ada711355369"
+	err = db.SaveGetHeaderServed(entry2)
+	require.NoError(t, err)
+
+	// newest first
+	entries, err := db.GetGetHeaderServed(GetHeaderServedFilters{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, uint64(101), entries[0].Slot)
+	require.Equal(t, entry.IP, entries[0].IP)
+
+	// by slot
+	entries, err = db.GetGetHeaderServed(GetHeaderServedFilters{Slot: 100, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, entry.BlockHash, entries[0].BlockHash)
+	require.Equal(t, entry.Value, entries[0].Value)
+
+	// by block hash
+	entries, err = db.GetGetHeaderServed(GetHeaderServedFilters{BlockHash: entry2.BlockHash, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, uint64(101), entries[0].Slot)
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration003GetHeaderServed = &migrate.Migration{
+	Id: "003-getheader-served",
+	Up: []string{`
+		CREATE TABLE IF NOT EXISTS ` + vars.TableGetHeaderServed + ` (
+			id          bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			inserted_at timestamp NOT NULL default current_timestamp,
+			served_at   timestamp NOT NULL,
+
+			slot  bigint NOT NULL,
+			epoch bigint NOT NULL,
+
+			parent_hash     varchar(66) NOT NULL,
+			proposer_pubkey varchar(98) NOT NULL,
+			block_hash      varchar(66) NOT NULL,
+			value           NUMERIC(48, 0),
+
+			user_agent        text NOT NULL,
+			mev_boost_version text NOT NULL,
+			ip                text NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS ` + vars.TableGetHeaderServed + `_slot_idx ON ` + vars.TableGetHeaderServed + `("slot");
+		CREATE INDEX IF NOT EXISTS ` + vars.TableGetHeaderServed + `_proposerpubkey_idx ON ` + vars.TableGetHeaderServed + `("proposer_pubkey");
+		CREATE INDEX IF NOT EXISTS ` + vars.TableGetHeaderServed + `_blockhash_idx ON ` + vars.TableGetHeaderServed + `("block_hash");
+	`},
+	Down: []string{`
+		DROP TABLE IF EXISTS ` + vars.TableGetHeaderServed + `;
+	`},
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration004OptimisticRelaying = &migrate.Migration{
+	Id: "004-optimistic-relaying",
+	Up: []string{`
+		ALTER TABLE ` + vars.TableBlockBuilder + ` ADD collateral NUMERIC(48, 0) NOT NULL DEFAULT 0;
+
+		CREATE TABLE IF NOT EXISTS ` + vars.TableBuilderDemotions + ` (
+			id          bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			inserted_at timestamp NOT NULL default current_timestamp,
+
+			slot  bigint NOT NULL,
+			epoch bigint NOT NULL,
+
+			builder_pubkey  varchar(98) NOT NULL,
+			proposer_pubkey varchar(98) NOT NULL,
+			block_hash      varchar(66) NOT NULL,
+			value           NUMERIC(48, 0),
+
+			sim_error text NOT NULL,
+
+			submit_block_request        json,
+			signed_blinded_beacon_block json, -- only set if the payload was delivered
+
+			refund_pending boolean NOT NULL default false
+		);
+
+		CREATE INDEX IF NOT EXISTS ` + vars.TableBuilderDemotions + `_slot_idx ON ` + vars.TableBuilderDemotions + `("slot");
+		CREATE INDEX IF NOT EXISTS ` + vars.TableBuilderDemotions + `_blockhash_idx ON ` + vars.TableBuilderDemotions + `("block_hash");
+		CREATE INDEX IF NOT EXISTS ` + vars.TableBuilderDemotions + `_builderpubkey_idx ON ` + vars.TableBuilderDemotions + `("builder_pubkey");
+	`},
+	Down: []string{},
+}
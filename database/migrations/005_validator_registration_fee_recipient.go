@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration005ValidatorRegistrationFeeRecipient = &migrate.Migration{
+	Id: "005-validator-registration-fee-recipient",
+	Up: []string{`
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS ` + vars.TableValidatorRegistration + `_feerecipient_idx ON ` + vars.TableValidatorRegistration + `(fee_recipient, pubkey);
+	`},
+	Down: []string{},
+
+	DisableTransactionUp:   true, // cannot create index concurrently inside a transaction
+	DisableTransactionDown: true,
+}
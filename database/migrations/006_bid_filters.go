@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration006BidFilters = &migrate.Migration{
+	Id: "006-bid-filters",
+	Up: []string{`
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS ` + vars.TableDeliveredPayload + `_insertedat_idx ON ` + vars.TableDeliveredPayload + `(inserted_at DESC);
+	`, `
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS ` + vars.TableDeliveredPayload + `_feerecipient_idx ON ` + vars.TableDeliveredPayload + `("proposer_fee_recipient");
+	`, `
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS ` + vars.TableBuilderBlockSubmission + `_feerecipient_idx ON ` + vars.TableBuilderBlockSubmission + `("proposer_fee_recipient");
+	`, `
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS ` + vars.TableBuilderBlockSubmission + `_value_idx ON ` + vars.TableBuilderBlockSubmission + `("value");
+	`},
+	Down: []string{},
+
+	DisableTransactionUp:   true, // cannot create index concurrently inside a transaction
+	DisableTransactionDown: true,
+}
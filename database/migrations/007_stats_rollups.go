@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration007StatsRollups = &migrate.Migration{
+	Id: "007-stats-rollups",
+	Up: []string{`
+		CREATE TABLE IF NOT EXISTS ` + vars.TableStatsDaily + ` (
+			day        date PRIMARY KEY,
+			updated_at timestamp NOT NULL default current_timestamp,
+
+			num_payloads_delivered bigint NOT NULL,
+			total_value            NUMERIC(48, 0) NOT NULL,
+			median_value           NUMERIC(48, 0) NOT NULL,
+			avg_num_tx             double precision NOT NULL,
+
+			num_submissions bigint NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS ` + vars.TableStatsBuilderDaily + ` (
+			day            date NOT NULL,
+			builder_pubkey varchar(98) NOT NULL,
+			updated_at     timestamp NOT NULL default current_timestamp,
+
+			num_payloads_delivered bigint NOT NULL,
+			total_value            NUMERIC(48, 0) NOT NULL,
+			median_value           NUMERIC(48, 0) NOT NULL,
+			avg_num_tx             double precision NOT NULL,
+
+			num_submissions bigint NOT NULL,
+
+			PRIMARY KEY (day, builder_pubkey)
+		);
+	`},
+	Down: []string{`
+		DROP TABLE IF EXISTS ` + vars.TableStatsBuilderDaily + `;
+		DROP TABLE IF EXISTS ` + vars.TableStatsDaily + `;
+	`},
+	DisableTransactionUp:   false,
+	DisableTransactionDown: false,
+}
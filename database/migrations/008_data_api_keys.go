@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/flashbots/mev-boost-relay/database/vars"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+var Migration008DataAPIKeys = &migrate.Migration{
+	Id: "008-data-api-keys",
+	Up: []string{`
+		CREATE TABLE IF NOT EXISTS ` + vars.TableDataAPIKeys + ` (
+			id          bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			inserted_at timestamp NOT NULL default current_timestamp,
+
+			key_hash    varchar(64) NOT NULL,
+			description text NOT NULL,
+			revoked_at  timestamp,
+
+			UNIQUE (key_hash)
+		);
+	`},
+	Down: []string{`
+		DROP TABLE IF EXISTS ` + vars.TableDataAPIKeys + `;
+	`},
+	DisableTransactionUp:   false,
+	DisableTransactionDown: false,
+}
@@ -9,5 +9,11 @@ var Migrations = migrate.MemoryMigrationSource{
 	Migrations: []*migrate.Migration{
 		Migration001InitDatabase,
 		Migration002RemoveIsBestAddReceivedAt,
+		Migration003GetHeaderServed,
+		Migration004OptimisticRelaying,
+		Migration005ValidatorRegistrationFeeRecipient,
+		Migration006BidFilters,
+		Migration007StatsRollups,
+		Migration008DataAPIKeys,
 	},
 }
@@ -3,7 +3,6 @@ package database
 import (
 	"time"
 
-	"github.com/flashbots/go-boost-utils/types"
 	"github.com/flashbots/mev-boost-relay/common"
 )
 
@@ -25,11 +24,19 @@ func (db MockDB) GetValidatorRegistrationsForPubkeys(pubkeys []string) (entries
 	return nil, nil
 }
 
+func (db MockDB) GetValidatorRegistrationHistory(pubkey string, cursor, limit uint64) ([]*ValidatorRegistrationEntry, error) {
+	return nil, nil
+}
+
+func (db MockDB) GetValidatorRegistrationsByFeeRecipient(feeRecipient, cursor string, limit uint64) ([]*ValidatorRegistrationEntry, error) {
+	return nil, nil
+}
+
 func (db MockDB) GetLatestValidatorRegistrations(timestampOnly bool) ([]*ValidatorRegistrationEntry, error) {
 	return nil, nil
 }
 
-func (db MockDB) SaveBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest, simError error, receivedAt time.Time) (entry *BuilderBlockSubmissionEntry, err error) {
+func (db MockDB) SaveBuilderBlockSubmission(payload *common.BuilderSubmitBlockRequest, simError error, receivedAt time.Time) (entry *BuilderBlockSubmissionEntry, err error) {
 	return nil, nil
 }
 
@@ -73,10 +80,18 @@ func (db MockDB) GetBuilderSubmissionsBySlots(slotFrom, slotTo uint64) (entries
 	return nil, nil
 }
 
-func (db MockDB) SaveDeliveredPayload(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock) error {
+func (db MockDB) SaveDeliveredPayload(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *common.SignedBlindedBeaconBlock) error {
 	return nil
 }
 
+func (db MockDB) SaveGetHeaderServed(entry GetHeaderServedEntry) error {
+	return nil
+}
+
+func (db MockDB) GetGetHeaderServed(filters GetHeaderServedFilters) ([]*GetHeaderServedEntry, error) {
+	return nil, nil
+}
+
 func (db MockDB) UpsertBlockBuilderEntryAfterSubmission(lastSubmission *BuilderBlockSubmissionEntry, isError bool) error {
 	return nil
 }
@@ -93,6 +108,10 @@ func (db MockDB) SetBlockBuilderStatus(pubkey string, isHighPrio, isBlacklisted
 	return nil
 }
 
+func (db MockDB) DemoteBlockBuilder(pubkey string) error {
+	return nil
+}
+
 func (db MockDB) IncBlockBuilderStatsAfterGetHeader(slot uint64, blockhash string) error {
 	return nil
 }
@@ -100,3 +119,39 @@ func (db MockDB) IncBlockBuilderStatsAfterGetHeader(slot uint64, blockhash strin
 func (db MockDB) IncBlockBuilderStatsAfterGetPayload(builderPubkey string) error {
 	return nil
 }
+
+func (db MockDB) SetBlockBuilderCollateral(pubkey, collateral string) error {
+	return nil
+}
+
+func (db MockDB) InsertBuilderDemotion(submitBlockRequest *common.BuilderSubmitBlockRequest, simError error) error {
+	return nil
+}
+
+func (db MockDB) UpdateBuilderDemotion(bidTrace *common.BidTraceV2, signedBlindedBeaconBlock *common.SignedBlindedBeaconBlock) (refundPending bool, err error) {
+	return false, nil
+}
+
+func (db MockDB) UpdateStatsRollups(day time.Time) error {
+	return nil
+}
+
+func (db MockDB) GetDailyStats(fromDay, toDay time.Time) ([]*DailyStatsEntry, error) {
+	return nil, nil
+}
+
+func (db MockDB) GetBuilderDailyStats(day time.Time) ([]*BuilderDailyStatsEntry, error) {
+	return nil, nil
+}
+
+func (db MockDB) InsertDataAPIKey(keyHash, description string) (id int64, err error) {
+	return 0, nil
+}
+
+func (db MockDB) GetDataAPIKeys() ([]*DataAPIKeyEntry, error) {
+	return nil, nil
+}
+
+func (db MockDB) RevokeDataAPIKey(id int64) (revoked bool, err error) {
+	return false, nil
+}
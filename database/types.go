@@ -1,8 +1,14 @@
 package database
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/flashbots/go-boost-utils/types"
@@ -29,7 +35,60 @@ func NewNullTime(t time.Time) sql.NullTime {
 	}
 }
 
+// BidFilters are the filters shared by the delivered payloads and the builder submissions. Zero values don't filter,
+// and all ranges are inclusive.
+type BidFilters struct {
+	SlotFrom     uint64
+	SlotTo       uint64
+	FromTime     time.Time
+	ToTime       time.Time
+	FeeRecipient string
+	MinValue     string // in wei
+}
+
+// whereConds returns the SQL conditions of the filters, and adds their arguments. timeColumn is compared to the time
+// range.
+func (f BidFilters) whereConds(timeColumn string, arg map[string]interface{}) (whereConds []string) {
+	if f.SlotFrom > 0 {
+		whereConds = append(whereConds, "slot >= :slot_from")
+		arg["slot_from"] = f.SlotFrom
+	}
+	if f.SlotTo > 0 {
+		whereConds = append(whereConds, "slot <= :slot_to")
+		arg["slot_to"] = f.SlotTo
+	}
+	if !f.FromTime.IsZero() {
+		whereConds = append(whereConds, timeColumn+" >= :from_time")
+		arg["from_time"] = timestampArg(f.FromTime)
+	}
+	if !f.ToTime.IsZero() {
+		whereConds = append(whereConds, timeColumn+" <= :to_time")
+		arg["to_time"] = timestampArg(f.ToTime)
+	}
+	if f.FeeRecipient != "" {
+		whereConds = append(whereConds, "proposer_fee_recipient = :fee_recipient")
+		arg["fee_recipient"] = f.FeeRecipient
+	}
+	if f.MinValue != "" {
+		whereConds = append(whereConds, "value >= :min_value")
+		arg["min_value"] = f.MinValue
+	}
+	return whereConds
+}
+
+// timestampArg formats the time as UTC without a timezone, so it's compared to the timestamp columns as is
+func timestampArg(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+// dayArg formats the UTC day of t as a date argument
+func dayArg(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
 type GetPayloadsFilters struct {
+	BidFilters
+
 	Slot           uint64
 	Cursor         uint64
 	Limit          uint64
@@ -40,13 +99,116 @@ type GetPayloadsFilters struct {
 	OrderByValue   int8
 }
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type GetBuilderSubmissionsFilters struct {
-	Slot        uint64
-	Limit       uint64
-	BlockHash   string
-	BlockNumber uint64
-	// Cursor      uint64
+	BidFilters
+
+	Slot          uint64
+	Limit         uint64
+	BlockHash     string
+	BlockNumber   uint64
 	BuilderPubkey string
+	OrderBy       BuilderSubmissionsOrder
+	Cursor        string // continues after the entry the cursor was created from, see BuilderSubmissionsCursor
+}
+
+// BuilderSubmissionsOrder is the order of the builder submissions. Ties are broken by the id, so the order is stable.
+type BuilderSubmissionsOrder string
+
+const (
+	BuilderSubmissionsOrderSlotDesc       BuilderSubmissionsOrder = "-slot" // default
+	BuilderSubmissionsOrderValueAsc       BuilderSubmissionsOrder = "value"
+	BuilderSubmissionsOrderValueDesc      BuilderSubmissionsOrder = "-value"
+	BuilderSubmissionsOrderReceivedAtAsc  BuilderSubmissionsOrder = "received_at" // only submissions with received_at
+	BuilderSubmissionsOrderReceivedAtDesc BuilderSubmissionsOrder = "-received_at"
+)
+
+// IsValid is true for the known orders, and the empty default order
+func (o BuilderSubmissionsOrder) IsValid() bool {
+	switch o {
+	case "", BuilderSubmissionsOrderSlotDesc, BuilderSubmissionsOrderValueAsc, BuilderSubmissionsOrderValueDesc, BuilderSubmissionsOrderReceivedAtAsc, BuilderSubmissionsOrderReceivedAtDesc:
+		return true
+	}
+	return false
+}
+
+func (o BuilderSubmissionsOrder) isDesc() bool {
+	return o == "" || strings.HasPrefix(string(o), "-")
+}
+
+// column is the sorted column, besides the id
+func (o BuilderSubmissionsOrder) column() string {
+	switch o {
+	case BuilderSubmissionsOrderValueAsc, BuilderSubmissionsOrderValueDesc:
+		return "value"
+	case BuilderSubmissionsOrderReceivedAtAsc, BuilderSubmissionsOrderReceivedAtDesc:
+		return "received_at"
+	default:
+		return "slot"
+	}
+}
+
+// BuilderSubmissionsCursor returns the cursor to continue after the entry, in the given order. The cursor is the sort
+// key (slot, value or received_at in microseconds) and the id, separated by an underscore.
+func BuilderSubmissionsCursor(entry *BuilderBlockSubmissionEntry, order BuilderSubmissionsOrder) string {
+	var key string
+	switch order.column() {
+	case "value":
+		key = entry.Value
+	case "received_at":
+		key = strconv.FormatInt(entry.ReceivedAt.Time.UnixMicro(), 10)
+	default:
+		key = strconv.FormatUint(entry.Slot, 10)
+	}
+	return fmt.Sprintf("%s_%d", key, entry.ID)
+}
+
+// parseBuilderSubmissionsCursor returns the sort key and id of the cursor, as query arguments
+func parseBuilderSubmissionsCursor(cursor string, order BuilderSubmissionsOrder) (key interface{}, id int64, err error) {
+	keyStr, idStr, found := strings.Cut(cursor, "_")
+	if !found {
+		return nil, 0, ErrInvalidCursor
+	}
+	id, err = strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	switch order.column() {
+	case "value":
+		value, ok := new(big.Int).SetString(keyStr, 10)
+		if !ok {
+			return nil, 0, ErrInvalidCursor
+		}
+		key = value.String()
+	case "received_at":
+		micros, err := strconv.ParseInt(keyStr, 10, 64)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		key = timestampArg(time.UnixMicro(micros))
+	default:
+		key, err = strconv.ParseUint(keyStr, 10, 64)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+	}
+	return key, id, nil
+}
+
+// ValidateBuilderSubmissionsCursor checks that the cursor can be used with the order
+func ValidateBuilderSubmissionsCursor(cursor string, order BuilderSubmissionsOrder) error {
+	_, _, err := parseBuilderSubmissionsCursor(cursor, order)
+	return err
+}
+
+type GetHeaderServedFilters struct {
+	Slot           uint64
+	Cursor         uint64
+	Limit          uint64
+	BlockHash      string
+	ProposerPubkey string
 }
 
 type ValidatorRegistrationEntry struct {
@@ -198,4 +360,86 @@ type BlockBuilderEntry struct {
 	NumSubmissionsSimError uint64 `db:"num_submissions_simerror" json:"num_submissions_simerror"`
 
 	NumSentGetPayload uint64 `db:"num_sent_getpayload" json:"num_sent_getpayload"`
+
+	Collateral string `db:"collateral" json:"collateral"`
+}
+
+type GetHeaderServedEntry struct {
+	ID         int64     `db:"id"`
+	InsertedAt time.Time `db:"inserted_at"`
+	ServedAt   time.Time `db:"served_at"`
+
+	Slot  uint64 `db:"slot"`
+	Epoch uint64 `db:"epoch"`
+
+	ParentHash     string `db:"parent_hash"`
+	ProposerPubkey string `db:"proposer_pubkey"`
+	BlockHash      string `db:"block_hash"`
+	Value          string `db:"value"`
+
+	UserAgent       string `db:"user_agent"`
+	MevBoostVersion string `db:"mev_boost_version"`
+	IP              string `db:"ip"`
+}
+
+type BuilderDemotionEntry struct {
+	ID         int64     `db:"id"`
+	InsertedAt time.Time `db:"inserted_at"`
+
+	Slot  uint64 `db:"slot"`
+	Epoch uint64 `db:"epoch"`
+
+	BuilderPubkey  string `db:"builder_pubkey"`
+	ProposerPubkey string `db:"proposer_pubkey"`
+	BlockHash      string `db:"block_hash"`
+	Value          string `db:"value"`
+
+	SimError string `db:"sim_error"`
+
+	SubmitBlockRequest       sql.NullString `db:"submit_block_request"`
+	SignedBlindedBeaconBlock sql.NullString `db:"signed_blinded_beacon_block"`
+
+	RefundPending bool `db:"refund_pending"`
+}
+
+// StatsMaxNumDays is how many days of stats rollups the data API returns at most, and the housekeeper backfills
+const StatsMaxNumDays = 366
+
+// DailyStatsEntry is the rollup of the delivered payloads and builder submissions of a day (UTC)
+type DailyStatsEntry struct {
+	Day       time.Time `db:"day"`
+	UpdatedAt time.Time `db:"updated_at"`
+
+	NumPayloadsDelivered uint64  `db:"num_payloads_delivered"`
+	TotalValue           string  `db:"total_value"`
+	MedianValue          string  `db:"median_value"`
+	AvgNumTx             float64 `db:"avg_num_tx"`
+
+	NumSubmissions uint64 `db:"num_submissions"`
+}
+
+// BuilderDailyStatsEntry is the rollup of a builder's delivered payloads and submissions of a day (UTC). The
+// submission counters of the block builder are lifetime totals.
+type BuilderDailyStatsEntry struct {
+	DailyStatsEntry
+	BuilderPubkey string `db:"builder_pubkey"`
+
+	NumSubmissionsTotal    uint64 `db:"num_submissions_total"`
+	NumSubmissionsSimError uint64 `db:"num_submissions_simerror"`
+}
+
+// DataAPIKeyEntry is an API key of the data API. Only the hash of the key is stored.
+type DataAPIKeyEntry struct {
+	ID         int64     `db:"id"`
+	InsertedAt time.Time `db:"inserted_at"`
+
+	KeyHash     string       `db:"key_hash"`
+	Description string       `db:"description"`
+	RevokedAt   sql.NullTime `db:"revoked_at"`
+}
+
+// HashDataAPIKey returns the hash under which a data API key is stored
+func HashDataAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
 }
@@ -1,24 +1,21 @@
 package database
 
 import (
-	"encoding/json"
-
-	"github.com/flashbots/go-boost-utils/types"
 	"github.com/flashbots/mev-boost-relay/common"
 )
 
-func PayloadToExecPayloadEntry(payload *types.BuilderSubmitBlockRequest) (*ExecutionPayloadEntry, error) {
-	_payload, err := json.Marshal(payload.ExecutionPayload)
+func PayloadToExecPayloadEntry(payload *common.BuilderSubmitBlockRequest) (*ExecutionPayloadEntry, error) {
+	_payload, err := payload.ExecutionPayloadJSON()
 	if err != nil {
 		return nil, err
 	}
 
 	return &ExecutionPayloadEntry{
-		Slot:           payload.Message.Slot,
-		ProposerPubkey: payload.Message.ProposerPubkey.String(),
-		BlockHash:      payload.ExecutionPayload.BlockHash.String(),
+		Slot:           payload.Slot(),
+		ProposerPubkey: payload.Message().ProposerPubkey.String(),
+		BlockHash:      payload.BlockHash().String(),
 
-		Version: "bellatrix",
+		Version: string(payload.Version()),
 		Payload: string(_payload),
 	}, nil
 }
@@ -39,6 +36,20 @@ func DeliveredPayloadEntryToBidTraceV2JSON(payload *DeliveredPayloadEntry) commo
 	}
 }
 
+func GetHeaderServedEntryToJSON(entry *GetHeaderServedEntry) common.GetHeaderServedJSON {
+	return common.GetHeaderServedJSON{
+		Slot:            entry.Slot,
+		ParentHash:      entry.ParentHash,
+		ProposerPubkey:  entry.ProposerPubkey,
+		BlockHash:       entry.BlockHash,
+		Value:           entry.Value,
+		UserAgent:       entry.UserAgent,
+		MevBoostVersion: entry.MevBoostVersion,
+		Timestamp:       entry.ServedAt.Unix(),
+		TimestampMs:     entry.ServedAt.UnixMilli(),
+	}
+}
+
 func BuilderSubmissionEntryToBidTraceV2WithTimestampJSON(payload *BuilderBlockSubmissionEntry) common.BidTraceV2WithTimestampJSON {
 	timestamp := payload.InsertedAt
 	if payload.ReceivedAt.Valid {
@@ -63,3 +74,28 @@ func BuilderSubmissionEntryToBidTraceV2WithTimestampJSON(payload *BuilderBlockSu
 		},
 	}
 }
+
+func DailyStatsEntryToJSON(entry *DailyStatsEntry) common.DailyStatsJSON {
+	return common.DailyStatsJSON{
+		Day:                  entry.Day.Format("2006-01-02"),
+		NumPayloadsDelivered: entry.NumPayloadsDelivered,
+		TotalValue:           entry.TotalValue,
+		MedianValue:          entry.MedianValue,
+		AvgNumTx:             entry.AvgNumTx,
+		NumSubmissions:       entry.NumSubmissions,
+		UpdatedAt:            entry.UpdatedAt.Unix(),
+	}
+}
+
+func BuilderDailyStatsEntryToJSON(entry *BuilderDailyStatsEntry) common.BuilderDailyStatsJSON {
+	simErrorRate := 0.0
+	if entry.NumSubmissionsTotal > 0 {
+		simErrorRate = float64(entry.NumSubmissionsSimError) / float64(entry.NumSubmissionsTotal)
+	}
+
+	return common.BuilderDailyStatsJSON{
+		DailyStatsJSON: DailyStatsEntryToJSON(&entry.DailyStatsEntry),
+		BuilderPubkey:  entry.BuilderPubkey,
+		SimErrorRate:   simErrorRate,
+	}
+}
@@ -12,4 +12,9 @@ var (
 	TableBuilderBlockSubmission = tableBase + "_builder_block_submission"
 	TableDeliveredPayload       = tableBase + "_payload_delivered"
 	TableBlockBuilder           = tableBase + "_blockbuilder"
+	TableGetHeaderServed        = tableBase + "_getheader_served"
+	TableBuilderDemotions       = tableBase + "_builder_demotions"
+	TableStatsDaily             = tableBase + "_stats_daily"
+	TableStatsBuilderDaily      = tableBase + "_stats_builder_daily"
+	TableDataAPIKeys            = tableBase + "_data_api_keys"
 )
@@ -2,11 +2,11 @@
 package datastore
 
 import (
-	"encoding/json"
 	"strings"
 	"sync"
 
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -33,6 +33,7 @@ type Datastore struct {
 
 	knownValidatorsByPubkey map[types.PubkeyHex]uint64
 	knownValidatorsByIndex  map[uint64]types.PubkeyHex
+	knownValidatorsVersion  uint64
 	knownValidatorsLock     sync.RWMutex
 }
 
@@ -48,8 +49,55 @@ func NewDatastore(log *logrus.Entry, redisCache *RedisCache, db database.IDataba
 	return ds, err
 }
 
-// RefreshKnownValidators loads known validators from Redis into memory
+// RefreshKnownValidators updates the known validators in memory. The changes published by the housekeeper since the
+// last refresh are applied incrementally. All known validators are only loaded from Redis initially, if the changes
+// aren't in the changelog anymore, or if the known validators aren't versioned.
 func (ds *Datastore) RefreshKnownValidators() (cnt int, err error) {
+	version, err := ds.redis.GetKnownValidatorsVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	ds.knownValidatorsLock.RLock()
+	prevVersion := ds.knownValidatorsVersion
+	cnt = len(ds.knownValidatorsByPubkey)
+	ds.knownValidatorsLock.RUnlock()
+
+	if prevVersion == 0 || version < prevVersion {
+		return ds.loadKnownValidators(version)
+	} else if version == prevVersion {
+		return cnt, nil
+	}
+
+	changes, err := ds.redis.GetKnownValidatorsChanges(prevVersion, version)
+	if errors.Is(err, ErrKnownValidatorsChangeMissing) {
+		ds.log.WithError(err).Info("reloading all known validators")
+		return ds.loadKnownValidators(version)
+	} else if err != nil {
+		return 0, err
+	}
+
+	ds.knownValidatorsLock.Lock()
+	defer ds.knownValidatorsLock.Unlock()
+	for _, change := range changes {
+		for pubkey, index := range change.Added {
+			ds.knownValidatorsByPubkey[pubkey] = index
+			ds.knownValidatorsByIndex[index] = pubkey
+		}
+		for _, pubkey := range change.Removed {
+			if index, found := ds.knownValidatorsByPubkey[pubkey]; found {
+				delete(ds.knownValidatorsByPubkey, pubkey)
+				delete(ds.knownValidatorsByIndex, index)
+			}
+		}
+	}
+	ds.knownValidatorsVersion = version
+	return len(ds.knownValidatorsByPubkey), nil
+}
+
+// loadKnownValidators loads all known validators from Redis into memory. version is read before, so changes made while
+// loading are applied again with the next refresh.
+func (ds *Datastore) loadKnownValidators(version uint64) (cnt int, err error) {
 	knownValidators, err := ds.redis.GetKnownValidators()
 	if err != nil {
 		return 0, err
@@ -64,6 +112,7 @@ func (ds *Datastore) RefreshKnownValidators() (cnt int, err error) {
 	defer ds.knownValidatorsLock.Unlock()
 	ds.knownValidatorsByPubkey = knownValidators
 	ds.knownValidatorsByIndex = knownValidatorsByIndex
+	ds.knownValidatorsVersion = version
 	return len(knownValidators), nil
 }
 
@@ -110,7 +159,7 @@ func (ds *Datastore) SaveValidatorRegistration(entry types.SignedValidatorRegist
 }
 
 // GetGetPayloadResponse returns the getPayload response from memory or Redis or Database
-func (ds *Datastore) GetGetPayloadResponse(slot uint64, proposerPubkey, blockHash string) (*types.GetPayloadResponse, error) {
+func (ds *Datastore) GetGetPayloadResponse(slot uint64, proposerPubkey, blockHash string) (*common.GetPayloadResponse, error) {
 	_proposerPubkey := strings.ToLower(proposerPubkey)
 	_blockHash := strings.ToLower(blockHash)
 
@@ -129,16 +178,12 @@ func (ds *Datastore) GetGetPayloadResponse(slot uint64, proposerPubkey, blockHas
 		return nil, err
 	}
 
-	// deserialize execution payload
-	executionPayload := new(types.ExecutionPayload)
-	err = json.Unmarshal([]byte(blockSubEntry.Payload), executionPayload)
+	// deserialize execution payload of the stored fork version
+	resp, err = common.NewGetPayloadResponse(types.VersionString(blockSubEntry.Version), []byte(blockSubEntry.Payload))
 	if err != nil {
 		return nil, err
 	}
 
 	ds.log.Debug("getPayload response from database")
-	return &types.GetPayloadResponse{
-		Version: types.VersionString(blockSubEntry.Version),
-		Data:    executionPayload,
-	}, nil
+	return resp, nil
 }
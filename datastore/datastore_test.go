@@ -51,3 +51,47 @@ func TestProdProposerValidatorRegistration(t *testing.T) {
 	err = copier.Copy(&reg2, &reg1)
 	require.NoError(t, err)
 }
+
+func TestRefreshKnownValidators(t *testing.T) {
+	ds := setupTestDatastore(t)
+	pubkeys := testValidatorPubkeys(4)
+
+	// unversioned known validators are always reloaded
+	require.NoError(t, ds.redis.SetKnownValidator(pubkeys[0], 0))
+	cnt, err := ds.RefreshKnownValidators()
+	require.NoError(t, err)
+	require.Equal(t, 1, cnt)
+
+	_, _, _, err = ds.redis.UpdateKnownValidators(&KnownValidatorsChange{Added: map[types.PubkeyHex]uint64{pubkeys[1]: 1}})
+	require.NoError(t, err)
+	cnt, err = ds.RefreshKnownValidators()
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+
+	// later changes are applied incrementally, so writes without a changelog entry are not picked up
+	require.NoError(t, ds.redis.SetKnownValidator(pubkeys[2], 2))
+	_, _, _, err = ds.redis.UpdateKnownValidators(&KnownValidatorsChange{
+		Added:   map[types.PubkeyHex]uint64{pubkeys[3]: 3},
+		Removed: []types.PubkeyHex{pubkeys[0]},
+	})
+	require.NoError(t, err)
+	cnt, err = ds.RefreshKnownValidators()
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+	require.False(t, ds.IsKnownValidator(pubkeys[0]))
+	require.False(t, ds.IsKnownValidator(pubkeys[2]))
+	require.True(t, ds.IsKnownValidator(pubkeys[3]))
+	_, found := ds.GetKnownValidatorPubkeyByIndex(0)
+	require.False(t, found)
+	pubkey, found := ds.GetKnownValidatorPubkeyByIndex(3)
+	require.True(t, found)
+	require.Equal(t, pubkeys[3], pubkey)
+
+	// a gap in the changelog makes it reload all known validators
+	_, err = ds.redis.BumpKnownValidatorsVersion()
+	require.NoError(t, err)
+	cnt, err = ds.RefreshKnownValidators()
+	require.NoError(t, err)
+	require.Equal(t, 3, cnt)
+	require.True(t, ds.IsKnownValidator(pubkeys[2]))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/flashbots/go-boost-utils/types"
 	"github.com/flashbots/go-utils/cli"
 	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/metrics"
 	"github.com/go-redis/redis/v9"
 )
 
@@ -21,6 +22,12 @@ var (
 
 	expiryBidCache = 45 * time.Second
 
+	// number of writes sent to redis at once by the bulk setters
+	redisBatchSize = cli.GetEnvInt("REDIS_BATCH_SIZE", 5000)
+
+	// number of known validators changes kept in the changelog, for instances to catch up with
+	knownValidatorsChangelogSize = 256
+
 	activeValidatorsHours  = cli.GetEnvInt("ACTIVE_VALIDATOR_HOURS", 3)
 	expiryActiveValidators = time.Duration(activeValidatorsHours) * time.Hour // careful with this setting - for each hour a hash set is created with each active proposer as field. for a lot of hours this can take a lot of space in redis.
 
@@ -29,7 +36,8 @@ var (
 	RedisStatsFieldValidatorsTotal          = "validators-total"
 	RedisStatsFieldSlotLastPayloadDelivered = "slot-last-payload-delivered"
 
-	ErrFailedUpdatingTopBidNoBids = errors.New("failed to update top bid because no bids were found")
+	ErrFailedUpdatingTopBidNoBids   = errors.New("failed to update top bid because no bids were found")
+	ErrKnownValidatorsChangeMissing = errors.New("known validators change missing from the changelog")
 )
 
 type BlockBuilderStatus string
@@ -52,6 +60,7 @@ func connectRedis(redisURI string) (*redis.Client, error) {
 		// unable to connect to redis
 		return nil, err
 	}
+	redisClient.AddHook(metrics.RedisHook{})
 	return redisClient, nil
 }
 
@@ -66,15 +75,24 @@ type RedisCache struct {
 	prefixBlockBuilderLatestBids      string // latest bid for a given slot
 	prefixBlockBuilderLatestBidsValue string // value of latest bid for a given slot
 	prefixBlockBuilderLatestBidsTime  string // when the request was received, to avoid older requests overwriting newer ones after a slot validation
+	prefixDataAPIResponse             string // cached data API responses, by normalized request
 
 	// keys
 	keyKnownValidators                string
+	keyKnownValidatorsVersion         string // incremented on every change of the known validators
+	keyKnownValidatorsChangelog       string // hashmap with the version as field, and the change as value
 	keyValidatorRegistrationTimestamp string
+	keyValidatorWithdrawalCredentials string
 
-	keyRelayConfig        string
-	keyStats              string
-	keyProposerDuties     string
-	keyBlockBuilderStatus string
+	keyRelayConfig             string
+	keyStats                   string
+	keyProposerDuties          string
+	keyBlockBuilderStatus      string
+	keyBlockBuilderCollateral  string
+	keyBlockBuilderStreamToken string
+
+	// pub/sub channels
+	channelTopBidUpdates string
 }
 
 func NewRedisCache(redisURI, prefix string) (*RedisCache, error) {
@@ -94,14 +112,23 @@ func NewRedisCache(redisURI, prefix string) (*RedisCache, error) {
 		prefixBlockBuilderLatestBids:      fmt.Sprintf("%s/%s:block-builder-latest-bid", redisPrefix, prefix),       // hashmap for slot+parentHash+proposerPubkey with builderPubkey as field
 		prefixBlockBuilderLatestBidsValue: fmt.Sprintf("%s/%s:block-builder-latest-bid-value", redisPrefix, prefix), // hashmap for slot+parentHash+proposerPubkey with builderPubkey as field
 		prefixBlockBuilderLatestBidsTime:  fmt.Sprintf("%s/%s:block-builder-latest-bid-time", redisPrefix, prefix),  // hashmap for slot+parentHash+proposerPubkey with builderPubkey as field
+		prefixDataAPIResponse:             fmt.Sprintf("%s/%s:cache-data-api-response", redisPrefix, prefix),
 
 		keyKnownValidators:                fmt.Sprintf("%s/%s:known-validators", redisPrefix, prefix),
+		keyKnownValidatorsVersion:         fmt.Sprintf("%s/%s:known-validators-version", redisPrefix, prefix),
+		keyKnownValidatorsChangelog:       fmt.Sprintf("%s/%s:known-validators-changelog", redisPrefix, prefix),
 		keyValidatorRegistrationTimestamp: fmt.Sprintf("%s/%s:validator-registration-timestamp", redisPrefix, prefix),
+		keyValidatorWithdrawalCredentials: fmt.Sprintf("%s/%s:validator-withdrawal-credentials", redisPrefix, prefix),
 		keyRelayConfig:                    fmt.Sprintf("%s/%s:relay-config", redisPrefix, prefix),
 
 		keyStats:              fmt.Sprintf("%s/%s:stats", redisPrefix, prefix),
 		keyProposerDuties:     fmt.Sprintf("%s/%s:proposer-duties", redisPrefix, prefix),
 		keyBlockBuilderStatus: fmt.Sprintf("%s/%s:block-builder-status", redisPrefix, prefix),
+
+		keyBlockBuilderCollateral:  fmt.Sprintf("%s/%s:block-builder-collateral", redisPrefix, prefix),
+		keyBlockBuilderStreamToken: fmt.Sprintf("%s/%s:block-builder-stream-token", redisPrefix, prefix),
+
+		channelTopBidUpdates: fmt.Sprintf("%s/%s:top-bid-updates", redisPrefix, prefix),
 	}, nil
 }
 
@@ -192,6 +219,229 @@ func (r *RedisCache) SetKnownValidatorNX(pubkeyHex types.PubkeyHex, proposerInde
 	return r.client.HSetNX(context.Background(), r.keyKnownValidators, PubkeyHexToLowerStr(pubkeyHex), proposerIndex).Err()
 }
 
+// SetKnownValidators sets the index of all validators which aren't known yet. The writes are pipelined in batches,
+// and progress (if not nil) is called with the number of validators processed after each batch.
+func (r *RedisCache) SetKnownValidators(validators map[types.PubkeyHex]uint64, progress func(numDone int)) (numNew int, err error) {
+	numDone := 0
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.BoolCmd, 0, redisBatchSize)
+	flush := func() error {
+		if _, err := pipe.Exec(context.Background()); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			if cmd.Val() {
+				numNew++
+			}
+		}
+		numDone += len(cmds)
+		cmds = cmds[:0]
+		if progress != nil {
+			progress(numDone)
+		}
+		return nil
+	}
+
+	for pubkeyHex, proposerIndex := range validators {
+		cmds = append(cmds, pipe.HSetNX(context.Background(), r.keyKnownValidators, PubkeyHexToLowerStr(pubkeyHex), proposerIndex))
+		if len(cmds) == redisBatchSize {
+			if err := flush(); err != nil {
+				return numNew, err
+			}
+		}
+	}
+	if len(cmds) > 0 {
+		return numNew, flush()
+	}
+	return numNew, nil
+}
+
+// KnownValidatorsChange are the validators added to and removed from the known validators in one update
+type KnownValidatorsChange struct {
+	Added   map[types.PubkeyHex]uint64 `json:"added"`
+	Removed []types.PubkeyHex          `json:"removed"`
+}
+
+// pruneKnownValidatorsChangelog drops the changelog entries of all versions which are changelogSize or more behind
+// version. Version bumps don't add entries, so a single old version can't be dropped at a time.
+const pruneKnownValidatorsChangelog = `
+for _, v in ipairs(redis.call("HKEYS", keyChangelog)) do
+	if tonumber(v) <= version - changelogSize then
+		redis.call("HDEL", keyChangelog, v)
+	end
+end
+`
+
+// updateKnownValidatorsScript applies a change to the known validators, increments their version and adds the change
+// to the changelog, dropping the old entries. Returns the new version, and the number of added and removed validators.
+//
+// KEYS: known validators, version, changelog
+// ARGV: changelog size, change, number of added validators, pubkey and index of each added validator, removed pubkeys
+var updateKnownValidatorsScript = redis.NewScript(`
+local keyKnownValidators, keyVersion, keyChangelog = KEYS[1], KEYS[2], KEYS[3]
+local changelogSize, change, numAdded = tonumber(ARGV[1]), ARGV[2], tonumber(ARGV[3])
+
+local added, removed = 0, 0
+for i = 4, 3 + 2 * numAdded, 2 do
+	added = added + redis.call("HSETNX", keyKnownValidators, ARGV[i], ARGV[i + 1])
+end
+for i = 4 + 2 * numAdded, #ARGV do
+	removed = removed + redis.call("HDEL", keyKnownValidators, ARGV[i])
+end
+
+local version = redis.call("INCR", keyVersion)
+redis.call("HSET", keyChangelog, version, change)
+` + pruneKnownValidatorsChangelog + `
+return {version, added, removed}
+`)
+
+// bumpKnownValidatorsVersionScript increments the version of the known validators without a changelog entry, dropping
+// the old entries. Returns the new version.
+//
+// KEYS: version, changelog
+// ARGV: changelog size
+var bumpKnownValidatorsVersionScript = redis.NewScript(`
+local keyVersion, keyChangelog = KEYS[1], KEYS[2]
+local changelogSize = tonumber(ARGV[1])
+
+local version = redis.call("INCR", keyVersion)
+` + pruneKnownValidatorsChangelog + `
+return version
+`)
+
+// UpdateKnownValidators atomically applies the change to the known validators, and adds it to the changelog
+func (r *RedisCache) UpdateKnownValidators(change *KnownValidatorsChange) (version uint64, numAdded, numRemoved int, err error) {
+	changeJSON, err := json.Marshal(change)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	args := make([]any, 0, 3+2*len(change.Added)+len(change.Removed))
+	args = append(args, knownValidatorsChangelogSize, changeJSON, len(change.Added))
+	for pubkeyHex, proposerIndex := range change.Added {
+		args = append(args, PubkeyHexToLowerStr(pubkeyHex), proposerIndex)
+	}
+	for _, pubkeyHex := range change.Removed {
+		args = append(args, PubkeyHexToLowerStr(pubkeyHex))
+	}
+
+	keys := []string{r.keyKnownValidators, r.keyKnownValidatorsVersion, r.keyKnownValidatorsChangelog}
+	res, err := updateKnownValidatorsScript.Run(context.Background(), r.client, keys, args...).Int64Slice()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return uint64(res[0]), int(res[1]), int(res[2]), nil
+}
+
+// BumpKnownValidatorsVersion increments the known validators version without a changelog entry, after changes too large
+// for the changelog. Instances then reload all known validators.
+func (r *RedisCache) BumpKnownValidatorsVersion() (version uint64, err error) {
+	keys := []string{r.keyKnownValidatorsVersion, r.keyKnownValidatorsChangelog}
+	return bumpKnownValidatorsVersionScript.Run(context.Background(), r.client, keys, knownValidatorsChangelogSize).Uint64()
+}
+
+// GetKnownValidatorsVersion returns the version of the known validators, which is 0 if they were never versioned
+func (r *RedisCache) GetKnownValidatorsVersion() (version uint64, err error) {
+	version, err = r.client.Get(context.Background(), r.keyKnownValidatorsVersion).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// GetKnownValidatorsChanges returns the changes after version fromVersion up to and including toVersion, in order.
+// Returns ErrKnownValidatorsChangeMissing if any of them isn't in the changelog (anymore).
+func (r *RedisCache) GetKnownValidatorsChanges(fromVersion, toVersion uint64) ([]*KnownValidatorsChange, error) {
+	if toVersion <= fromVersion {
+		return nil, nil
+	}
+
+	fields := make([]string, 0, toVersion-fromVersion)
+	for version := fromVersion + 1; version <= toVersion; version++ {
+		fields = append(fields, strconv.FormatUint(version, 10))
+	}
+	values, err := r.client.HMGet(context.Background(), r.keyKnownValidatorsChangelog, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]*KnownValidatorsChange, len(values))
+	for i, value := range values {
+		changeJSON, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: version %s", ErrKnownValidatorsChangeMissing, fields[i])
+		}
+		changes[i] = new(KnownValidatorsChange)
+		if err := json.Unmarshal([]byte(changeJSON), changes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// RemoveKnownValidators removes the validators from the known validators, in batches, and returns how many were known
+func (r *RedisCache) RemoveKnownValidators(pubkeys []types.PubkeyHex) (numRemoved int, err error) {
+	for start := 0; start < len(pubkeys); start += redisBatchSize {
+		end := start + redisBatchSize
+		if end > len(pubkeys) {
+			end = len(pubkeys)
+		}
+		fields := make([]string, 0, end-start)
+		for _, pubkeyHex := range pubkeys[start:end] {
+			fields = append(fields, PubkeyHexToLowerStr(pubkeyHex))
+		}
+		n, err := r.client.HDel(context.Background(), r.keyKnownValidators, fields...).Result()
+		numRemoved += int(n)
+		if err != nil {
+			return numRemoved, err
+		}
+	}
+	return numRemoved, nil
+}
+
+// UpdateValidatorWithdrawalCredentials sets the changed withdrawal credentials and removes those of the removed
+// validators, pipelined in batches
+func (r *RedisCache) UpdateValidatorWithdrawalCredentials(changed map[types.PubkeyHex]string, removed []types.PubkeyHex) error {
+	pipe := r.client.Pipeline()
+	numQueued := 0
+	queued := func() error {
+		numQueued++
+		if numQueued < redisBatchSize {
+			return nil
+		}
+		numQueued = 0
+		_, err := pipe.Exec(context.Background())
+		return err
+	}
+
+	for pubkeyHex, withdrawalCredentials := range changed {
+		pipe.HSet(context.Background(), r.keyValidatorWithdrawalCredentials, PubkeyHexToLowerStr(pubkeyHex), withdrawalCredentials)
+		if err := queued(); err != nil {
+			return err
+		}
+	}
+	for _, pubkeyHex := range removed {
+		pipe.HDel(context.Background(), r.keyValidatorWithdrawalCredentials, PubkeyHexToLowerStr(pubkeyHex))
+		if err := queued(); err != nil {
+			return err
+		}
+	}
+	if numQueued > 0 {
+		_, err := pipe.Exec(context.Background())
+		return err
+	}
+	return nil
+}
+
+// GetValidatorWithdrawalCredentials returns the withdrawal credentials of the validator, or an empty string if unknown
+func (r *RedisCache) GetValidatorWithdrawalCredentials(pubkeyHex types.PubkeyHex) (string, error) {
+	withdrawalCredentials, err := r.client.HGet(context.Background(), r.keyValidatorWithdrawalCredentials, PubkeyHexToLowerStr(pubkeyHex)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return withdrawalCredentials, err
+}
+
 func (r *RedisCache) GetValidatorRegistrationTimestamp(proposerPubkey types.PubkeyHex) (uint64, error) {
 	timestamp, err := r.client.HGet(context.Background(), r.keyValidatorRegistrationTimestamp, strings.ToLower(proposerPubkey.String())).Uint64()
 	if errors.Is(err, redis.Nil) {
@@ -215,6 +465,54 @@ func (r *RedisCache) SetValidatorRegistrationTimestamp(proposerPubkey types.Pubk
 	return r.client.HSet(context.Background(), r.keyValidatorRegistrationTimestamp, proposerPubkey.String(), timestamp).Err()
 }
 
+// setTimestampsIfNewerScript sets the timestamps in the hash KEYS[1] which are newer than the existing ones, and returns
+// how many were set. ARGV are pairs of field and timestamp.
+var setTimestampsIfNewerScript = redis.NewScript(`
+local numUpdated = 0
+for i = 1, #ARGV, 2 do
+	local known = redis.call("HGET", KEYS[1], ARGV[i])
+	if not known or tonumber(known) < tonumber(ARGV[i + 1]) then
+		redis.call("HSET", KEYS[1], ARGV[i], ARGV[i + 1])
+		numUpdated = numUpdated + 1
+	end
+end
+return numUpdated
+`)
+
+// SetValidatorRegistrationTimestampsIfNewer sets the registration timestamps which are newer than the known ones, in
+// batches which are each applied atomically. progress (if not nil) is called with the number of validators processed
+// after each batch.
+func (r *RedisCache) SetValidatorRegistrationTimestampsIfNewer(timestamps map[types.PubkeyHex]uint64, progress func(numDone int)) (numUpdated int, err error) {
+	numDone := 0
+	args := make([]any, 0, 2*redisBatchSize)
+	flush := func() error {
+		n, err := setTimestampsIfNewerScript.Run(context.Background(), r.client, []string{r.keyValidatorRegistrationTimestamp}, args...).Int()
+		if err != nil {
+			return err
+		}
+		numUpdated += n
+		numDone += len(args) / 2
+		args = args[:0]
+		if progress != nil {
+			progress(numDone)
+		}
+		return nil
+	}
+
+	for pubkeyHex, timestamp := range timestamps {
+		args = append(args, PubkeyHexToLowerStr(pubkeyHex), timestamp)
+		if len(args) == 2*redisBatchSize {
+			if err := flush(); err != nil {
+				return numUpdated, err
+			}
+		}
+	}
+	if len(args) > 0 {
+		return numUpdated, flush()
+	}
+	return numUpdated, nil
+}
+
 func (r *RedisCache) SetActiveValidator(pubkeyHex types.PubkeyHex) error {
 	key := r.keyActiveValidators(time.Now())
 	err := r.client.HSet(context.Background(), key, PubkeyHexToLowerStr(pubkeyHex), "1").Err()
@@ -277,9 +575,9 @@ func (r *RedisCache) GetRelayConfig(field string) (string, error) {
 	return res, err
 }
 
-func (r *RedisCache) GetBestBid(slot uint64, parentHash, proposerPubkey string) (*types.GetHeaderResponse, error) {
+func (r *RedisCache) GetBestBid(slot uint64, parentHash, proposerPubkey string) (*common.GetHeaderResponse, error) {
 	key := r.keyCacheGetHeaderResponse(slot, parentHash, proposerPubkey)
-	resp := new(types.GetHeaderResponse)
+	resp := new(common.GetHeaderResponse)
 	err := r.GetObj(key, resp)
 	if errors.Is(err, redis.Nil) {
 		return nil, nil
@@ -287,14 +585,14 @@ func (r *RedisCache) GetBestBid(slot uint64, parentHash, proposerPubkey string)
 	return resp, err
 }
 
-func (r *RedisCache) SaveExecutionPayload(slot uint64, proposerPubkey, blockHash string, resp *types.GetPayloadResponse) (err error) {
+func (r *RedisCache) SaveExecutionPayload(slot uint64, proposerPubkey, blockHash string, resp *common.GetPayloadResponse) (err error) {
 	key := r.keyCacheGetPayloadResponse(slot, proposerPubkey, blockHash)
 	return r.SetObj(key, resp, expiryBidCache)
 }
 
-func (r *RedisCache) GetExecutionPayload(slot uint64, proposerPubkey, blockHash string) (*types.GetPayloadResponse, error) {
+func (r *RedisCache) GetExecutionPayload(slot uint64, proposerPubkey, blockHash string) (*common.GetPayloadResponse, error) {
 	key := r.keyCacheGetPayloadResponse(slot, proposerPubkey, blockHash)
-	resp := new(types.GetPayloadResponse)
+	resp := new(common.GetPayloadResponse)
 	err := r.GetObj(key, resp)
 	if errors.Is(err, redis.Nil) {
 		return nil, nil
@@ -321,6 +619,23 @@ func (r *RedisCache) SetBlockBuilderStatus(builderPubkey string, status BlockBui
 	return r.client.HSet(context.Background(), r.keyBlockBuilderStatus, builderPubkey, string(status)).Err()
 }
 
+// demoteBlockBuilderScript sets a builder's status to low-prio, unless it is blacklisted
+//
+// KEYS: block builder status
+// ARGV: builder pubkey, low-prio status, blacklisted status
+var demoteBlockBuilderScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[3] then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+end
+return 0
+`)
+
+// DemoteBlockBuilder atomically removes the high-prio status of a builder, a blacklisted builder stays blacklisted
+func (r *RedisCache) DemoteBlockBuilder(builderPubkey string) error {
+	keys := []string{r.keyBlockBuilderStatus}
+	return demoteBlockBuilderScript.Run(context.Background(), r.client, keys, builderPubkey, string(RedisBlockBuilderStatusLowPrio), string(RedisBlockBuilderStatusBlacklisted)).Err()
+}
+
 func (r *RedisCache) GetBlockBuilderStatus(builderPubkey string) (isHighPrio, isBlacklisted bool, err error) {
 	res, err := r.client.HGet(context.Background(), r.keyBlockBuilderStatus, builderPubkey).Result()
 	if errors.Is(err, redis.Nil) {
@@ -331,6 +646,22 @@ func (r *RedisCache) GetBlockBuilderStatus(builderPubkey string) (isHighPrio, is
 	return isHighPrio, isBlacklisted, err
 }
 
+func (r *RedisCache) SetBlockBuilderCollateral(builderPubkey, collateral string) (err error) {
+	return r.client.HSet(context.Background(), r.keyBlockBuilderCollateral, builderPubkey, collateral).Err()
+}
+
+// GetBlockBuilderCollateral returns the collateral (in wei) a builder has posted, zero if none
+func (r *RedisCache) GetBlockBuilderCollateral(builderPubkey string) (collateral types.U256Str, err error) {
+	res, err := r.client.HGet(context.Background(), r.keyBlockBuilderCollateral, builderPubkey).Result()
+	if errors.Is(err, redis.Nil) {
+		return collateral, nil
+	} else if err != nil {
+		return collateral, err
+	}
+	err = collateral.UnmarshalText([]byte(res))
+	return collateral, err
+}
+
 func (r *RedisCache) GetBuilderLatestPayloadReceivedAt(slot uint64, builderPubkey, parentHash, proposerPubkey string) (int64, error) {
 	keyLatestBidsTime := r.keyBlockBuilderLatestBidsTime(slot, parentHash, proposerPubkey)
 	timestamp, err := r.client.HGet(context.Background(), keyLatestBidsTime, builderPubkey).Int64()
@@ -340,66 +671,194 @@ func (r *RedisCache) GetBuilderLatestPayloadReceivedAt(slot uint64, builderPubke
 	return timestamp, err
 }
 
-// SaveLatestBuilderBid saves the latest bid by a specific builder
-func (r *RedisCache) SaveLatestBuilderBid(slot uint64, builderPubkey, parentHash, proposerPubkey string, receivedAt time.Time, headerResp *types.GetHeaderResponse) (err error) {
-	keyLatestBids := r.keyBlockBuilderLatestBids(slot, parentHash, proposerPubkey)
-	err = r.HSetObj(keyLatestBids, builderPubkey, headerResp, expiryBidCache)
+// saveBidAndUpdateTopBidScript saves a builder's latest bid, and sets the highest of the builders' latest bids as top
+// bid, in a single atomic step. Top bid changes are published in the same step, so they are announced in order.
+// Returns {1, top bid builder, top bid value} if the bid was saved, {0} if it is lower than the builder's previous bid
+// without cancellations, and {2} if a newer payload of the builder was already saved.
+//
+// KEYS: latest bids, latest bids time, latest bids value, top bid
+// ARGV: builder pubkey, bid, received at (ms), value, cancellations enabled (1/0), expiry (ms), top bid updates
+// channel, slot, parent hash, proposer pubkey, now (ms)
+var saveBidAndUpdateTopBidScript = redis.NewScript(`
+local keyLatestBids, keyLatestBidsTime, keyLatestBidsValue, keyTopBid = KEYS[1], KEYS[2], KEYS[3], KEYS[4]
+local builderPubkey, bid, receivedAtMs, value, isCancellationEnabled, expiryMs = ARGV[1], ARGV[2], ARGV[3], ARGV[4], ARGV[5] == "1", ARGV[6]
+
+-- values are decimal wei amounts without leading zeros, which exceed the precision of lua numbers
+local function isLower(a, b)
+	if #a ~= #b then
+		return #a < #b
+	end
+	return a < b
+end
+
+-- a payload received before the builder's latest saved one is outdated
+local prevReceivedAtMs = redis.call("HGET", keyLatestBidsTime, builderPubkey)
+if prevReceivedAtMs and tonumber(prevReceivedAtMs) > tonumber(receivedAtMs) then
+	return {2}
+end
+
+-- without cancellations, a bid lower than the builder's previous one is not saved
+if not isCancellationEnabled then
+	local prevValue = redis.call("HGET", keyLatestBidsValue, builderPubkey)
+	if prevValue and isLower(value, prevValue) then
+		return {0}
+	end
+end
+
+redis.call("HSET", keyLatestBids, builderPubkey, bid)
+redis.call("HSET", keyLatestBidsTime, builderPubkey, receivedAtMs)
+redis.call("HSET", keyLatestBidsValue, builderPubkey, value)
+for _, key in ipairs({keyLatestBids, keyLatestBidsTime, keyLatestBidsValue}) do
+	redis.call("PEXPIRE", key, expiryMs)
+end
+
+local topBidBuilderPubkey, topBidValue = "", "0"
+local values = redis.call("HGETALL", keyLatestBidsValue)
+for i = 1, #values, 2 do
+	if isLower(topBidValue, values[i + 1]) then
+		topBidBuilderPubkey, topBidValue = values[i], values[i + 1]
+	end
+end
+if topBidBuilderPubkey == "" then
+	return {1, "", "0"}
+end
+
+local topBid = redis.call("HGET", keyLatestBids, topBidBuilderPubkey)
+local prevTopBid = redis.call("GET", keyTopBid)
+redis.call("SET", keyTopBid, topBid, "PX", expiryMs)
+if topBid ~= prevTopBid then
+	redis.call("PUBLISH", ARGV[7], cjson.encode({
+		slot = ARGV[8],
+		parent_hash = ARGV[9],
+		proposer_pubkey = ARGV[10],
+		builder_pubkey = topBidBuilderPubkey,
+		value = topBidValue,
+		timestamp = tostring(math.floor(tonumber(ARGV[11]) / 1000)),
+		timestamp_ms = ARGV[11],
+	}))
+end
+return {1, topBidBuilderPubkey, topBidValue}
+`)
+
+// SaveBidAndUpdateTopBidResponse is the outcome of saving a builder's bid
+type SaveBidAndUpdateTopBidResponse struct {
+	WasBidSaved         bool // false if the bid is lower than the builder's previous one, and cancellations are disabled
+	NewerPayloadExists  bool // true if a payload of the builder received later was already saved, the bid is not saved then
+	TopBidBuilderPubkey string
+	TopBidValue         *big.Int
+}
+
+// SaveBidAndUpdateTopBid atomically saves the latest bid by a specific builder, and sets the highest of the builders'
+// latest bids as top bid. A bid received before the builder's latest saved bid is never saved. Unless cancellations are
+// enabled, a bid lower than the builder's previous one is not saved, so builders can only lower their bid with
+// cancellations. Top bid changes are published to the top bid updates channel.
+func (r *RedisCache) SaveBidAndUpdateTopBid(slot uint64, builderPubkey, parentHash, proposerPubkey string, receivedAt time.Time, headerResp *common.GetHeaderResponse, isCancellationEnabled bool) (state SaveBidAndUpdateTopBidResponse, err error) {
+	bid, err := json.Marshal(headerResp)
 	if err != nil {
-		return err
+		return state, err
 	}
 
-	// set the time of the request
-	keyLatestBidsTime := r.keyBlockBuilderLatestBidsTime(slot, parentHash, proposerPubkey)
-	err = r.client.HSet(context.Background(), keyLatestBidsTime, builderPubkey, receivedAt.UnixMilli()).Err()
-	if err != nil {
-		return err
+	value := headerResp.Value()
+	isCancellationEnabledArg := "0"
+	if isCancellationEnabled {
+		isCancellationEnabledArg = "1"
+	}
+
+	keys := []string{
+		r.keyBlockBuilderLatestBids(slot, parentHash, proposerPubkey),
+		r.keyBlockBuilderLatestBidsTime(slot, parentHash, proposerPubkey),
+		r.keyBlockBuilderLatestBidsValue(slot, parentHash, proposerPubkey),
+		r.keyCacheGetHeaderResponse(slot, parentHash, proposerPubkey),
 	}
-	err = r.client.Expire(context.Background(), keyLatestBidsTime, expiryBidCache).Err()
+	args := []any{
+		builderPubkey,
+		bid,
+		receivedAt.UnixMilli(),
+		value.String(),
+		isCancellationEnabledArg,
+		expiryBidCache.Milliseconds(),
+		r.channelTopBidUpdates,
+		slot,
+		parentHash,
+		proposerPubkey,
+		time.Now().UTC().UnixMilli(),
+	}
+	res, err := saveBidAndUpdateTopBidScript.Run(context.Background(), r.client, keys, args...).Slice()
 	if err != nil {
-		return err
+		return state, err
 	}
 
-	// set the value last, because that's iterated over when updating the best bid, and the payload has to be available
-	keyLatestBidsValue := r.keyBlockBuilderLatestBidsValue(slot, parentHash, proposerPubkey)
-	err = r.client.HSet(context.Background(), keyLatestBidsValue, builderPubkey, headerResp.Data.Message.Value.String()).Err()
-	if err != nil {
-		return err
+	state.NewerPayloadExists = res[0].(int64) == 2
+	state.WasBidSaved = res[0].(int64) == 1
+	if !state.WasBidSaved {
+		return state, nil
 	}
-	return r.client.Expire(context.Background(), keyLatestBidsValue, expiryBidCache).Err()
+
+	state.TopBidBuilderPubkey = res[1].(string)
+	if state.TopBidBuilderPubkey == "" {
+		return state, ErrFailedUpdatingTopBidNoBids
+	}
+	state.TopBidValue = new(big.Int)
+	state.TopBidValue.SetString(res[2].(string), 10)
+	return state, nil
 }
 
-func (r *RedisCache) UpdateTopBid(slot uint64, parentHash, proposerPubkey string) (err error) {
-	// Get all builder's latest submission values
-	keyBidValues := r.keyBlockBuilderLatestBidsValue(slot, parentHash, proposerPubkey)
-	bidValueMap, err := r.client.HGetAll(context.Background(), keyBidValues).Result()
-	if err != nil {
+// SubscribeTopBidUpdates calls handler with the top bid updates published by all relay instances, until ctx is done
+// or the subscription fails
+func (r *RedisCache) SubscribeTopBidUpdates(ctx context.Context, handler func(*common.TopBidUpdate)) error {
+	pubsub := r.client.Subscribe(ctx, r.channelTopBidUpdates)
+	defer pubsub.Close()
+
+	// wait for the subscription to be confirmed, so no update published after this returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
 		return err
 	}
 
-	// Find bid with highest value among all the latest bids
-	topBidValue := big.NewInt(0)
-	topBidBuilderPubkey := ""
-	for builderPubkey, bidValue := range bidValueMap {
-		val := new(big.Int)
-		val.SetString(bidValue, 10)
-		if val.Cmp(topBidValue) > 0 {
-			topBidValue = val
-			topBidBuilderPubkey = builderPubkey
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return redis.ErrClosed
+			}
+			update := new(common.TopBidUpdate)
+			if err := json.Unmarshal([]byte(msg.Payload), update); err != nil {
+				return err
+			}
+			handler(update)
 		}
 	}
+}
+
+// SetBlockBuilderStreamToken sets the hash of the token with which the builder authenticates to the top bid stream
+func (r *RedisCache) SetBlockBuilderStreamToken(builderPubkey, tokenHash string) error {
+	return r.client.HSet(context.Background(), r.keyBlockBuilderStreamToken, strings.ToLower(builderPubkey), tokenHash).Err()
+}
 
-	if topBidBuilderPubkey == "" {
-		return ErrFailedUpdatingTopBidNoBids
+// GetBlockBuilderStreamToken returns the hash of the builder's top bid stream token, or an empty string if it has none
+func (r *RedisCache) GetBlockBuilderStreamToken(builderPubkey string) (tokenHash string, err error) {
+	tokenHash, err = r.client.HGet(context.Background(), r.keyBlockBuilderStreamToken, strings.ToLower(builderPubkey)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
 	}
+	return tokenHash, err
+}
 
-	// Get the actual bid
-	keyBid := r.keyBlockBuilderLatestBids(slot, parentHash, proposerPubkey)
-	bidStr, err := r.client.HGet(context.Background(), keyBid, topBidBuilderPubkey).Result()
-	if err != nil {
-		return err
+func (r *RedisCache) DeleteBlockBuilderStreamToken(builderPubkey string) error {
+	return r.client.HDel(context.Background(), r.keyBlockBuilderStreamToken, strings.ToLower(builderPubkey)).Err()
+}
+
+// GetDataAPIResponse returns the cached data API response for the key, or nil if there is none
+func (r *RedisCache) GetDataAPIResponse(key string) ([]byte, error) {
+	resp, err := r.client.Get(context.Background(), r.prefixDataAPIResponse+":"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
 	}
+	return resp, err
+}
 
-	// Save the top bid
-	keyTopBid := r.keyCacheGetHeaderResponse(slot, parentHash, proposerPubkey)
-	return r.client.Set(context.Background(), keyTopBid, bidStr, expiryBidCache).Err()
+func (r *RedisCache) SetDataAPIResponse(key string, resp []byte, expiration time.Duration) error {
+	return r.client.Set(context.Background(), r.prefixDataAPIResponse+":"+key, resp, expiration).Err()
 }
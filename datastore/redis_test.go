@@ -1,6 +1,11 @@
 package datastore
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -93,6 +98,217 @@ func TestRedisKnownValidators(t *testing.T) {
 	})
 }
 
+// testValidatorPubkeys returns n distinct validator pubkeys
+func testValidatorPubkeys(n int) []types.PubkeyHex {
+	pubkeys := make([]types.PubkeyHex, n)
+	for i := range pubkeys {
+		pubkeys[i] = types.NewPubkeyHex(fmt.Sprintf("0x%096x", i))
+	}
+	return pubkeys
+}
+
+func TestRedisSetKnownValidators(t *testing.T) {
+	cache := setupTestRedis(t)
+	redisBatchSize = 3
+	defer func() { redisBatchSize = 5000 }()
+
+	pubkeys := testValidatorPubkeys(7)
+	require.NoError(t, cache.SetKnownValidator(pubkeys[0], 100))
+
+	validators := make(map[types.PubkeyHex]uint64)
+	for i, pubkey := range pubkeys {
+		validators[pubkey] = uint64(i)
+	}
+	progress := []int{}
+	numNew, err := cache.SetKnownValidators(validators, func(numDone int) { progress = append(progress, numDone) })
+	require.NoError(t, err)
+	require.Equal(t, 6, numNew)
+	require.Equal(t, []int{3, 6, 7}, progress)
+
+	// existing validators are not overwritten
+	knownVals, err := cache.GetKnownValidators()
+	require.NoError(t, err)
+	require.Len(t, knownVals, 7)
+	require.Equal(t, uint64(100), knownVals[pubkeys[0]])
+	require.Equal(t, uint64(6), knownVals[pubkeys[6]])
+
+	numNew, err = cache.SetKnownValidators(map[types.PubkeyHex]uint64{}, nil)
+	require.NoError(t, err)
+	require.Zero(t, numNew)
+}
+
+func TestRedisRemoveKnownValidators(t *testing.T) {
+	cache := setupTestRedis(t)
+	redisBatchSize = 2
+	defer func() { redisBatchSize = 5000 }()
+
+	pubkeys := testValidatorPubkeys(5)
+	for i, pubkey := range pubkeys[:4] {
+		require.NoError(t, cache.SetKnownValidator(pubkey, uint64(i)))
+	}
+
+	// unknown validators are ignored
+	numRemoved, err := cache.RemoveKnownValidators([]types.PubkeyHex{pubkeys[0], pubkeys[2], pubkeys[3], pubkeys[4]})
+	require.NoError(t, err)
+	require.Equal(t, 3, numRemoved)
+
+	knownVals, err := cache.GetKnownValidators()
+	require.NoError(t, err)
+	require.Equal(t, map[types.PubkeyHex]uint64{pubkeys[1]: 1}, knownVals)
+}
+
+func TestRedisKnownValidatorsChangelog(t *testing.T) {
+	cache := setupTestRedis(t)
+	knownValidatorsChangelogSize = 2
+	defer func() { knownValidatorsChangelogSize = 256 }()
+
+	pubkeys := testValidatorPubkeys(3)
+	version, err := cache.GetKnownValidatorsVersion()
+	require.NoError(t, err)
+	require.Zero(t, version)
+
+	change1 := &KnownValidatorsChange{Added: map[types.PubkeyHex]uint64{pubkeys[0]: 0, pubkeys[1]: 1}}
+	version, numAdded, numRemoved, err := cache.UpdateKnownValidators(change1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), version)
+	require.Equal(t, 2, numAdded)
+	require.Zero(t, numRemoved)
+
+	change2 := &KnownValidatorsChange{Added: map[types.PubkeyHex]uint64{pubkeys[2]: 2}, Removed: []types.PubkeyHex{pubkeys[0]}}
+	version, numAdded, numRemoved, err = cache.UpdateKnownValidators(change2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), version)
+	require.Equal(t, 1, numAdded)
+	require.Equal(t, 1, numRemoved)
+
+	knownVals, err := cache.GetKnownValidators()
+	require.NoError(t, err)
+	require.Equal(t, map[types.PubkeyHex]uint64{pubkeys[1]: 1, pubkeys[2]: 2}, knownVals)
+
+	changes, err := cache.GetKnownValidatorsChanges(0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []*KnownValidatorsChange{change1, change2}, changes)
+	changes, err = cache.GetKnownValidatorsChanges(2, 2)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	// the oldest change is dropped from the changelog
+	_, _, _, err = cache.UpdateKnownValidators(&KnownValidatorsChange{Removed: []types.PubkeyHex{pubkeys[1]}})
+	require.NoError(t, err)
+	_, err = cache.GetKnownValidatorsChanges(0, 3)
+	require.ErrorIs(t, err, ErrKnownValidatorsChangeMissing)
+	changes, err = cache.GetKnownValidatorsChanges(1, 3)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	// a version bump without a change leaves a gap
+	version, err = cache.BumpKnownValidatorsVersion()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), version)
+	_, err = cache.GetKnownValidatorsChanges(3, 4)
+	require.ErrorIs(t, err, ErrKnownValidatorsChangeMissing)
+
+	// the changelog stays bounded with bumps and updates in between
+	for i := 0; i < 10; i++ {
+		_, err = cache.BumpKnownValidatorsVersion()
+		require.NoError(t, err)
+		_, _, _, err = cache.UpdateKnownValidators(&KnownValidatorsChange{Removed: []types.PubkeyHex{pubkeys[1]}})
+		require.NoError(t, err)
+
+		numEntries, err := cache.client.HLen(context.Background(), cache.keyKnownValidatorsChangelog).Result()
+		require.NoError(t, err)
+		require.LessOrEqual(t, numEntries, int64(knownValidatorsChangelogSize))
+	}
+	numEntries, err := cache.client.HLen(context.Background(), cache.keyKnownValidatorsChangelog).Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), numEntries)
+}
+
+func TestRedisValidatorWithdrawalCredentials(t *testing.T) {
+	cache := setupTestRedis(t)
+	redisBatchSize = 2
+	defer func() { redisBatchSize = 5000 }()
+
+	pubkeys := testValidatorPubkeys(3)
+	changed := map[types.PubkeyHex]string{
+		pubkeys[0]: "0x00a1",
+		pubkeys[1]: "0x00a2",
+		pubkeys[2]: "0x00a3",
+	}
+	require.NoError(t, cache.UpdateValidatorWithdrawalCredentials(changed, nil))
+
+	// credentials change, and exited validators are removed
+	require.NoError(t, cache.UpdateValidatorWithdrawalCredentials(map[types.PubkeyHex]string{pubkeys[1]: "0x01b2"}, []types.PubkeyHex{pubkeys[2]}))
+	for pubkey, expected := range map[types.PubkeyHex]string{pubkeys[0]: "0x00a1", pubkeys[1]: "0x01b2", pubkeys[2]: ""} {
+		withdrawalCredentials, err := cache.GetValidatorWithdrawalCredentials(pubkey)
+		require.NoError(t, err)
+		require.Equal(t, expected, withdrawalCredentials)
+	}
+}
+
+func TestRedisSetValidatorRegistrationTimestampsIfNewer(t *testing.T) {
+	cache := setupTestRedis(t)
+	redisBatchSize = 2
+	defer func() { redisBatchSize = 5000 }()
+
+	pubkeys := testValidatorPubkeys(3)
+	require.NoError(t, cache.SetValidatorRegistrationTimestamp(pubkeys[0], 200))
+	require.NoError(t, cache.SetValidatorRegistrationTimestamp(pubkeys[1], 50))
+
+	progress := []int{}
+	numUpdated, err := cache.SetValidatorRegistrationTimestampsIfNewer(map[types.PubkeyHex]uint64{
+		pubkeys[0]: 100,
+		pubkeys[1]: 100,
+		pubkeys[2]: 100,
+	}, func(numDone int) { progress = append(progress, numDone) })
+	require.NoError(t, err)
+	require.Equal(t, 2, numUpdated)
+	require.Equal(t, []int{2, 3}, progress)
+
+	for pubkey, expected := range map[types.PubkeyHex]uint64{pubkeys[0]: 200, pubkeys[1]: 100, pubkeys[2]: 100} {
+		timestamp, err := cache.GetValidatorRegistrationTimestamp(pubkey)
+		require.NoError(t, err)
+		require.Equal(t, expected, timestamp)
+	}
+}
+
+func BenchmarkRedisSetKnownValidators(b *testing.B) {
+	pubkeys := testValidatorPubkeys(10000)
+	validators := make(map[types.PubkeyHex]uint64)
+	for i, pubkey := range pubkeys {
+		validators[pubkey] = uint64(i)
+	}
+
+	setup := func(b *testing.B) *RedisCache {
+		b.Helper()
+		b.StopTimer()
+		redisTestServer, err := miniredis.Run()
+		require.NoError(b, err)
+		b.Cleanup(redisTestServer.Close)
+		cache, err := NewRedisCache(redisTestServer.Addr(), "")
+		require.NoError(b, err)
+		b.StartTimer()
+		return cache
+	}
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cache := setup(b)
+			for pubkey, index := range validators {
+				require.NoError(b, cache.SetKnownValidatorNX(pubkey, index))
+			}
+		}
+	})
+
+	b.Run("bulk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cache := setup(b)
+			_, err := cache.SetKnownValidators(validators, nil)
+			require.NoError(b, err)
+		}
+	})
+}
+
 func TestRedisValidatorRegistrations(t *testing.T) {
 	cache := setupTestRedis(t)
 
@@ -166,16 +382,18 @@ func TestActiveValidators(t *testing.T) {
 	require.True(t, vals[pk1])
 }
 
-func _buildGetHeaderResponse(value uint64) *types.GetHeaderResponse {
-	return &types.GetHeaderResponse{
-		Version: "bellatrix",
-		Data: &types.SignedBuilderBid{
-			Message: &types.BuilderBid{
-				Header: &types.ExecutionPayloadHeader{},
-				Value:  types.IntToU256(value),
-				Pubkey: types.PublicKey{0x01},
+func _buildGetHeaderResponse(value uint64) *common.GetHeaderResponse {
+	return &common.GetHeaderResponse{
+		Bellatrix: &types.GetHeaderResponse{
+			Version: "bellatrix",
+			Data: &types.SignedBuilderBid{
+				Message: &types.BuilderBid{
+					Header: &types.ExecutionPayloadHeader{},
+					Value:  types.IntToU256(value),
+					Pubkey: types.PublicKey{0x01},
+				},
+				Signature: types.Signature{0x01},
 			},
-			Signature: types.Signature{0x01},
 		},
 	}
 }
@@ -193,36 +411,326 @@ func TestBuilderBids(t *testing.T) {
 	receivedAt := time.Now()
 
 	// 2 initial bids: 99 and 100 value
-	err := cache.SaveLatestBuilderBid(slot, builder1pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(100))
-	require.NoError(t, err)
-	err = cache.SaveLatestBuilderBid(slot, builder2pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(99))
+	state, err := cache.SaveBidAndUpdateTopBid(slot, builder1pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(100), false)
 	require.NoError(t, err)
-	err = cache.UpdateTopBid(slot, parentHash, proposerPk)
+	require.True(t, state.WasBidSaved)
+	state, err = cache.SaveBidAndUpdateTopBid(slot, builder2pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(99), false)
 	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+	require.Equal(t, builder1pk, state.TopBidBuilderPubkey)
+	require.Equal(t, "100", state.TopBidValue.String())
 	topBid, err := cache.GetBestBid(slot, parentHash, proposerPk)
 	require.NoError(t, err)
-	require.Equal(t, "100", topBid.Data.Message.Value.String())
+	require.Equal(t, "100", topBid.Bellatrix.Data.Message.Value.String())
 
 	// new top bid by builder3: 101
-	err = cache.SaveLatestBuilderBid(slot, builder3pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(101))
-	require.NoError(t, err)
-	err = cache.UpdateTopBid(slot, parentHash, proposerPk)
+	state, err = cache.SaveBidAndUpdateTopBid(slot, builder3pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(101), false)
 	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+	require.Equal(t, builder3pk, state.TopBidBuilderPubkey)
 	topBid, err = cache.GetBestBid(slot, parentHash, proposerPk)
 	require.NoError(t, err)
-	require.Equal(t, "101", topBid.Data.Message.Value.String())
+	require.Equal(t, "101", topBid.Bellatrix.Data.Message.Value.String())
 
-	// builder3 cancels 101 bid, by sending 100 value
-	err = cache.SaveLatestBuilderBid(slot, builder3pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(99))
-	require.NoError(t, err)
-	err = cache.UpdateTopBid(slot, parentHash, proposerPk)
+	// builder3 cancels 101 bid, by sending 99 value
+	state, err = cache.SaveBidAndUpdateTopBid(slot, builder3pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(99), true)
 	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+	require.Equal(t, builder1pk, state.TopBidBuilderPubkey)
 	topBid, err = cache.GetBestBid(slot, parentHash, proposerPk)
 	require.NoError(t, err)
-	require.Equal(t, "100", topBid.Data.Message.Value.String())
+	require.Equal(t, "100", topBid.Bellatrix.Data.Message.Value.String())
 
 	// double-check the receivedAt timestamp
 	ts, err := cache.GetBuilderLatestPayloadReceivedAt(slot, builder3pk, parentHash, proposerPk)
 	require.NoError(t, err)
 	require.Equal(t, receivedAt.UnixMilli(), ts)
 }
+
+func TestBuilderBidsCancellations(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	slot := uint64(123)
+	parentHash := "0xa1"
+	proposerPk := "0xa2"
+	builder1pk := "0xb1"
+	builder2pk := "0xb2"
+
+	receivedAt := time.Now()
+
+	// builder1 bids 100, builder2 bids 90
+	_, err := cache.SaveBidAndUpdateTopBid(slot, builder1pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(100), false)
+	require.NoError(t, err)
+	_, err = cache.SaveBidAndUpdateTopBid(slot, builder2pk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(90), false)
+	require.NoError(t, err)
+	topBid, err := cache.GetBestBid(slot, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, "100", topBid.Bellatrix.Data.Message.Value.String())
+
+	// without cancellations, a lower bid by builder1 doesn't replace its previous bid
+	laterReceivedAt := receivedAt.Add(time.Second)
+	state, err := cache.SaveBidAndUpdateTopBid(slot, builder1pk, parentHash, proposerPk, laterReceivedAt, _buildGetHeaderResponse(80), false)
+	require.NoError(t, err)
+	require.False(t, state.WasBidSaved)
+	topBid, err = cache.GetBestBid(slot, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, "100", topBid.Bellatrix.Data.Message.Value.String())
+	ts, err := cache.GetBuilderLatestPayloadReceivedAt(slot, builder1pk, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, receivedAt.UnixMilli(), ts)
+
+	// an equal or higher bid without cancellations is saved
+	state, err = cache.SaveBidAndUpdateTopBid(slot, builder1pk, parentHash, proposerPk, laterReceivedAt, _buildGetHeaderResponse(100), false)
+	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+
+	// with cancellations, builder1 lowers its bid to 80, and builder2's 90 becomes the top bid
+	state, err = cache.SaveBidAndUpdateTopBid(slot, builder1pk, parentHash, proposerPk, laterReceivedAt, _buildGetHeaderResponse(80), true)
+	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+	topBid, err = cache.GetBestBid(slot, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, "90", topBid.Bellatrix.Data.Message.Value.String())
+
+	// builder2 cancels as well, builder1's 80 is the top bid now
+	state, err = cache.SaveBidAndUpdateTopBid(slot, builder2pk, parentHash, proposerPk, laterReceivedAt, _buildGetHeaderResponse(70), true)
+	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+	topBid, err = cache.GetBestBid(slot, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, "80", topBid.Bellatrix.Data.Message.Value.String())
+}
+
+func TestBuilderBidsOutOfOrder(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	slot := uint64(123)
+	parentHash := "0xa1"
+	proposerPk := "0xa2"
+	builderPk := "0xb1"
+
+	receivedAt := time.Now()
+	earlierReceivedAt := receivedAt.Add(-time.Second)
+
+	// the newer payload is saved first
+	state, err := cache.SaveBidAndUpdateTopBid(slot, builderPk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(100), true)
+	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+
+	// the older payload is rejected, also with cancellations and a higher value
+	for _, isCancellationEnabled := range []bool{false, true} {
+		state, err = cache.SaveBidAndUpdateTopBid(slot, builderPk, parentHash, proposerPk, earlierReceivedAt, _buildGetHeaderResponse(110), isCancellationEnabled)
+		require.NoError(t, err)
+		require.False(t, state.WasBidSaved)
+		require.True(t, state.NewerPayloadExists)
+	}
+
+	topBid, err := cache.GetBestBid(slot, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, "100", topBid.Bellatrix.Data.Message.Value.String())
+	ts, err := cache.GetBuilderLatestPayloadReceivedAt(slot, builderPk, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, receivedAt.UnixMilli(), ts)
+
+	// a payload received at the same time is saved
+	state, err = cache.SaveBidAndUpdateTopBid(slot, builderPk, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(90), true)
+	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+	require.False(t, state.NewerPayloadExists)
+}
+
+func TestDemoteBlockBuilder(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	require.NoError(t, cache.SetBlockBuilderStatus("0xb1", RedisBlockBuilderStatusHighPrio))
+	require.NoError(t, cache.SetBlockBuilderStatus("0xb2", RedisBlockBuilderStatusBlacklisted))
+	for _, builderPubkey := range []string{"0xb1", "0xb2", "0xb3"} {
+		require.NoError(t, cache.DemoteBlockBuilder(builderPubkey))
+	}
+
+	isHighPrio, isBlacklisted, err := cache.GetBlockBuilderStatus("0xb1")
+	require.NoError(t, err)
+	require.False(t, isHighPrio)
+	require.False(t, isBlacklisted)
+
+	// a blacklisted builder stays blacklisted
+	_, isBlacklisted, err = cache.GetBlockBuilderStatus("0xb2")
+	require.NoError(t, err)
+	require.True(t, isBlacklisted)
+}
+
+func TestBlockBuilderCollateral(t *testing.T) {
+	cache := setupTestRedis(t)
+	builderPubkey := "0xfa1ed37c3553d0ce1e9349b2c5063cf6e394d231c8d3e0df75e9462257c081543086109ffddaacc0aa76f33dc9661c83"
+
+	// no collateral posted
+	collateral, err := cache.GetBlockBuilderCollateral(builderPubkey)
+	require.NoError(t, err)
+	require.Equal(t, "0", collateral.String())
+
+	err = cache.SetBlockBuilderCollateral(builderPubkey, "1000000000000000000")
+	require.NoError(t, err)
+	collateral, err = cache.GetBlockBuilderCollateral(builderPubkey)
+	require.NoError(t, err)
+	require.Equal(t, "1000000000000000000", collateral.String())
+}
+
+func TestTopBidUpdates(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	slot := uint64(123)
+	parentHash := "0xa1"
+	proposerPk := "0xa2"
+	builder1pk := "0xb1"
+	builder2pk := "0xb2"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan *common.TopBidUpdate, 10)
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- cache.SubscribeTopBidUpdates(ctx, func(update *common.TopBidUpdate) { updates <- update })
+	}()
+	require.Eventually(t, func() bool {
+		channels, err := cache.client.PubSubChannels(context.Background(), cache.channelTopBidUpdates).Result()
+		return err == nil && len(channels) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	expectUpdate := func(builderPubkey, value string) {
+		t.Helper()
+		select {
+		case update := <-updates:
+			require.Equal(t, slot, update.Slot)
+			require.Equal(t, parentHash, update.ParentHash)
+			require.Equal(t, proposerPk, update.ProposerPubkey)
+			require.Equal(t, builderPubkey, update.BuilderPubkey)
+			require.Equal(t, value, update.Value)
+			require.NotZero(t, update.TimestampMs)
+		case <-time.After(time.Second):
+			t.Fatal("no top bid update received")
+		}
+	}
+
+	// a new top bid is published
+	_, err := cache.SaveBidAndUpdateTopBid(slot, builder1pk, parentHash, proposerPk, time.Now(), _buildGetHeaderResponse(100), false)
+	require.NoError(t, err)
+	expectUpdate(builder1pk, "100")
+
+	// a lower bid doesn't change the top bid, so nothing is published
+	_, err = cache.SaveBidAndUpdateTopBid(slot, builder2pk, parentHash, proposerPk, time.Now(), _buildGetHeaderResponse(99), false)
+	require.NoError(t, err)
+
+	// a higher bid by another builder is published
+	_, err = cache.SaveBidAndUpdateTopBid(slot, builder2pk, parentHash, proposerPk, time.Now(), _buildGetHeaderResponse(101), false)
+	require.NoError(t, err)
+	expectUpdate(builder2pk, "101")
+	require.Empty(t, updates)
+
+	cancel()
+	require.ErrorIs(t, <-subscribed, context.Canceled)
+}
+
+func TestBlockBuilderStreamToken(t *testing.T) {
+	cache := setupTestRedis(t)
+	builderPubkey := "0xFA1ED37C3553D0CE1E9349B2C5063CF6E394D231C8D3E0DF75E9462257C081543086109FFDDAACC0AA76F33DC9661C83"
+
+	tokenHash, err := cache.GetBlockBuilderStreamToken(builderPubkey)
+	require.NoError(t, err)
+	require.Empty(t, tokenHash)
+
+	// tokens are looked up by the lowercase pubkey
+	require.NoError(t, cache.SetBlockBuilderStreamToken(builderPubkey, "0x1234"))
+	tokenHash, err = cache.GetBlockBuilderStreamToken(strings.ToLower(builderPubkey))
+	require.NoError(t, err)
+	require.Equal(t, "0x1234", tokenHash)
+
+	require.NoError(t, cache.DeleteBlockBuilderStreamToken(builderPubkey))
+	tokenHash, err = cache.GetBlockBuilderStreamToken(builderPubkey)
+	require.NoError(t, err)
+	require.Empty(t, tokenHash)
+}
+
+func TestSaveBidAndUpdateTopBidLargeValues(t *testing.T) {
+	cache := setupTestRedis(t)
+
+	// values above 2^53 are compared exactly
+	state, err := cache.SaveBidAndUpdateTopBid(123, "0xb1", "0xa1", "0xa2", time.Now(), _buildGetHeaderResponse(9007199254740993), false)
+	require.NoError(t, err)
+	require.True(t, state.WasBidSaved)
+	state, err = cache.SaveBidAndUpdateTopBid(123, "0xb2", "0xa1", "0xa2", time.Now(), _buildGetHeaderResponse(9007199254740992), false)
+	require.NoError(t, err)
+	require.Equal(t, "0xb1", state.TopBidBuilderPubkey)
+	require.Equal(t, "9007199254740993", state.TopBidValue.String())
+
+	state, err = cache.SaveBidAndUpdateTopBid(123, "0xb1", "0xa1", "0xa2", time.Now(), _buildGetHeaderResponse(9007199254740992), false)
+	require.NoError(t, err)
+	require.False(t, state.WasBidSaved)
+}
+
+func TestSaveBidAndUpdateTopBidConcurrent(t *testing.T) {
+	redisTestServer, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(redisTestServer.Close)
+
+	// several relay instances sharing redis
+	replicas := make([]*RedisCache, 4)
+	for i := range replicas {
+		replicas[i], err = NewRedisCache(redisTestServer.Addr(), "")
+		require.NoError(t, err)
+	}
+
+	slot := uint64(123)
+	parentHash := "0xa1"
+	proposerPk := "0xa2"
+	numBuilders := 8
+	numBidsPerBuilder := 20
+	maxValue := uint64(numBuilders * numBidsPerBuilder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan *common.TopBidUpdate, maxValue)
+	go func() {
+		_ = replicas[0].SubscribeTopBidUpdates(ctx, func(update *common.TopBidUpdate) { updates <- update })
+	}()
+	require.Eventually(t, func() bool {
+		channels, err := replicas[0].client.PubSubChannels(context.Background(), replicas[0].channelTopBidUpdates).Result()
+		return err == nil && len(channels) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// every builder sends increasing bids, all of them concurrently and via different instances. They share the receive
+	// time, as bids received before the builder's latest saved one are rejected.
+	receivedAt := time.Now()
+	var wg sync.WaitGroup
+	for b := 0; b < numBuilders; b++ {
+		for i := 0; i < numBidsPerBuilder; i++ {
+			wg.Add(1)
+			go func(b, i int) {
+				defer wg.Done()
+				builderPubkey := fmt.Sprintf("0xb%d", b)
+				value := uint64(i*numBuilders + b + 1)
+				_, err := replicas[(b+i)%len(replicas)].SaveBidAndUpdateTopBid(slot, builderPubkey, parentHash, proposerPk, receivedAt, _buildGetHeaderResponse(value), false)
+				require.NoError(t, err)
+			}(b, i)
+		}
+	}
+	wg.Wait()
+
+	// the top bid is the highest bid of all
+	topBid, err := replicas[1].GetBestBid(slot, parentHash, proposerPk)
+	require.NoError(t, err)
+	require.Equal(t, strconv.FormatUint(maxValue, 10), topBid.Bellatrix.Data.Message.Value.String())
+
+	// without cancellations the top bid can only increase, so a stale top bid would show as a lower published value
+	prevValue := uint64(0)
+	for prevValue < maxValue {
+		select {
+		case update := <-updates:
+			value, err := strconv.ParseUint(update.Value, 10, 64)
+			require.NoError(t, err)
+			require.Greater(t, value, prevValue)
+			prevValue = value
+		case <-time.After(time.Second):
+			t.Fatalf("no top bid update received after value %d", prevValue)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package metrics contains the prometheus metrics of the relay services, exposed on /metrics
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mevboostrelay"
+
+var (
+	// HTTPRequestDuration is the latency of all API requests, by route, http method and response status code
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by route, method and status code",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"route", "method", "code"})
+
+	SubmitNewBlockStageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "submitnewblock_stage_duration_seconds",
+		Help:      "Duration of the stages of processing a block submission",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"stage"})
+
+	BlockSimDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "blocksim_duration_seconds",
+		Help:      "Duration of block simulation requests, without waiting in the queue",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"result"})
+
+	BlockSimQueueDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "blocksim_queue_depth",
+		Help:      "Number of waiting and active block simulations when a new one is queued",
+		Buckets:   []float64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256},
+	})
+
+	ValidatorRegistrationBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "validator_registration_batch_size",
+		Help:      "Number of registrations per registerValidator request",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+
+	BeaconNodeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "beacon_node_errors_total",
+		Help:      "Number of failed beacon node requests, by beacon node and call",
+	}, []string{"uri", "call"})
+
+	KnownValidators = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "known_validators",
+		Help:      "Number of active and pending validators known to the housekeeper, by beacon chain status",
+	}, []string{"status"})
+
+	KnownValidatorsRemoved = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "known_validators_removed_total",
+		Help:      "Number of validators removed from the known validators after they exited or were slashed",
+	})
+
+	RedisCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_call_duration_seconds",
+		Help:      "Duration of redis commands (pipelines are reported as 'pipeline')",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+	}, []string{"command"})
+
+	DatabaseCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "database_call_duration_seconds",
+		Help:      "Duration of database calls",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"call"})
+)
+
+// StartServer serves the metrics on listenAddr/metrics, blocking
+func StartServer(listenAddr string) error {
+	router := http.NewServeMux()
+	router.Handle("/metrics", promhttp.Handler())
+
+	srv := &http.Server{
+		Addr:              listenAddr,
+		Handler:           router,
+		ReadHeaderTimeout: 1 * time.Second,
+	}
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, i.e. to flush streamed responses
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// HTTPMiddleware records the duration and status code of requests, labeled by the mux route template
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+
+		route := "unknown"
+		if currentRoute := mux.CurrentRoute(req); currentRoute != nil {
+			if tmpl, err := currentRoute.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		HTTPRequestDuration.WithLabelValues(route, req.Method, strconv.Itoa(recorder.code)).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/test/{slot:[0-9]+}", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	r.Use(HTTPMiddleware)
+
+	req := httptest.NewRequest(http.MethodGet, "/test/123", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// requests are labeled by the route template, not the actual path
+	count := testutil.CollectAndCount(HTTPRequestDuration, "mevboostrelay_http_request_duration_seconds")
+	require.Equal(t, 1, count)
+	observer, err := HTTPRequestDuration.GetMetricWithLabelValues("/test/{slot:[0-9]+}", http.MethodGet, "204")
+	require.NoError(t, err)
+	require.NotNil(t, observer)
+}
@@ -7,62 +7,350 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/flashbots/go-utils/cli"
 	"github.com/flashbots/go-utils/jsonrpc"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/metrics"
+	"github.com/flashbots/mev-boost-relay/tracing"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	uberatomic "go.uber.org/atomic"
 )
 
 var (
 	ErrRequestClosed    = errors.New("request context closed")
 	ErrSimulationFailed = errors.New("simulation failed")
+	ErrNoBlockSimNode   = errors.New("no block simulation node available")
+	ErrBlockSimNodeRPC  = errors.New("block simulation node returned an error")
+	ErrSlotPassed       = errors.New("slot of the queued simulation has passed")
 )
 
-var maxConcurrentBlocks = int64(cli.GetEnvInt("BLOCKSIM_MAX_CONCURRENT", 4)) // 0 for no maximum
+const (
+	BlockSimSelectionLeastLoaded = "least-loaded"
+	BlockSimSelectionRoundRobin  = "round-robin"
+)
+
+var (
+	maxConcurrentBlocks = int64(cli.GetEnvInt("BLOCKSIM_MAX_CONCURRENT", 4)) // per node, 0 for no maximum
+	blockSimSelection   = common.GetEnv("BLOCKSIM_SELECTION", BlockSimSelectionLeastLoaded)
+
+	blockSimHealthCheckInterval = time.Duration(cli.GetEnvInt("BLOCKSIM_HEALTHCHECK_INTERVAL_MS", 2000)) * time.Millisecond
+	blockSimMaxLagBlocks        = uint64(cli.GetEnvInt("BLOCKSIM_MAX_LAG_BLOCKS", 2)) // nodes further behind the highest one are unhealthy
+
+	blockSimHealthCheckClient = &http.Client{Timeout: 2 * time.Second}
+)
+
+// blockSimNode is a single block simulation endpoint
+type blockSimNode struct {
+	url     string
+	active  int64 // simulations in flight, guarded by the rate limiter lock
+	healthy uberatomic.Bool
+}
+
+func (n *blockSimNode) hasCapacity() bool {
+	return maxConcurrentBlocks == 0 || n.active < maxConcurrentBlocks
+}
+
+// blockNumber returns the latest block number of the node, used to check if it's reachable and synced
+func (n *blockSimNode) blockNumber() (uint64, error) {
+	req := jsonrpc.JSONRPCRequest{ID: "1", Method: "eth_blockNumber", Params: []interface{}{}, Version: "2.0"}
+	resp, err := sendJSONRPCRequest(context.Background(), blockSimHealthCheckClient, req, n.url, false)
+	if err != nil {
+		return 0, err
+	} else if resp.Error != nil {
+		return 0, fmt.Errorf("%w: %s", ErrBlockSimNodeRPC, resp.Error.Message)
+	}
+
+	var blockNumber hexutil.Uint64
+	err = json.Unmarshal(resp.Result, &blockNumber)
+	return uint64(blockNumber), err
+}
+
+// blockSimPool is a set of nodes, from which one is selected for each simulation
+type blockSimPool struct {
+	nodes []*blockSimNode
+	next  int // round-robin position, guarded by the rate limiter lock
+}
+
+// selectNode returns a node with free capacity, not considering excluded nodes. Unhealthy nodes are only used if no
+// healthy node is left. ok is false if there are no nodes left to try, node is nil if they are all at capacity.
+func (p *blockSimPool) selectNode(exclude map[*blockSimNode]bool) (node *blockSimNode, ok bool) {
+	var candidates []*blockSimNode
+	for _, onlyHealthy := range []bool{true, false} {
+		for _, n := range p.nodes {
+			if !exclude[n] && (n.healthy.Load() || !onlyHealthy) {
+				candidates = append(candidates, n)
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
 
+	if blockSimSelection == BlockSimSelectionRoundRobin {
+		for i := 0; i < len(candidates); i++ {
+			n := candidates[(p.next+i)%len(candidates)]
+			if n.hasCapacity() {
+				p.next = (p.next + i + 1) % len(candidates)
+				return n, true
+			}
+		}
+		return nil, true
+	}
+
+	for _, n := range candidates {
+		if n.hasCapacity() && (node == nil || n.active < node.active) {
+			node = n
+		}
+	}
+	return node, true
+}
+
+// blockSimRequest is a simulation waiting for a node
+type blockSimRequest struct {
+	pool          *blockSimPool
+	exclude       map[*blockSimNode]bool // nodes which already failed
+	isHighPrio    bool
+	isOptimistic  bool // optimistic simulations are not dropped when their slot passes, to still demote the builder
+	slot          uint64
+	builderPubkey string
+	seq           uint64
+	result        chan blockSimAssignment
+}
+
+type blockSimAssignment struct {
+	node *blockSimNode
+	err  error
+}
+
+// BlockSimulationRateLimiter distributes block simulations over the block simulation nodes, limiting the concurrent
+// simulations per node and failing over to another node if one is unreachable. High-prio builders can use a separate pool.
+//
+// Simulations waiting for a node are scheduled by priority: high-prio builders first, then the newest slot, then the
+// builder with the fewest pending simulations (so a single builder can't monopolize the nodes), then first come first served.
 type BlockSimulationRateLimiter struct {
-	cv          *sync.Cond
-	counter     int64
-	blockSimURL string
+	mu             sync.Mutex
+	queue          []*blockSimRequest
+	seq            uint64
+	builderPending map[string]int // queued and active simulations per builder
+	counter        int64
+
+	nodes        []*blockSimNode // all distinct nodes
+	pool         *blockSimPool
+	highPrioPool *blockSimPool // nil if high-prio builders use the regular pool
+}
+
+func NewBlockSimulationRateLimiter(blockSimURLs, highPrioBlockSimURLs []string) *BlockSimulationRateLimiter {
+	b := &BlockSimulationRateLimiter{
+		builderPending: make(map[string]int),
+		counter:        0,
+	}
+
+	// a node which is part of both pools shares its concurrency limit
+	nodesByURL := make(map[string]*blockSimNode)
+	newPool := func(urls []string) *blockSimPool {
+		pool := new(blockSimPool)
+		for _, url := range urls {
+			node, ok := nodesByURL[url]
+			if !ok {
+				node = &blockSimNode{url: url}
+				node.healthy.Store(true)
+				nodesByURL[url] = node
+				b.nodes = append(b.nodes, node)
+			}
+			pool.nodes = append(pool.nodes, node)
+		}
+		return pool
+	}
+
+	b.pool = newPool(blockSimURLs)
+	if len(highPrioBlockSimURLs) > 0 {
+		b.highPrioPool = newPool(highPrioBlockSimURLs)
+	}
+	return b
+}
+
+// before returns whether simulation r is scheduled before other, the lock must be held
+func (b *BlockSimulationRateLimiter) before(r, other *blockSimRequest) bool {
+	if r.isHighPrio != other.isHighPrio {
+		return r.isHighPrio
+	}
+	if r.slot != other.slot {
+		return r.slot > other.slot
+	}
+	if rPending, otherPending := b.builderPending[r.builderPubkey], b.builderPending[other.builderPubkey]; rPending != otherPending {
+		return rPending < otherPending
+	}
+	return r.seq < other.seq
+}
+
+// dispatch assigns nodes with free capacity to the queued simulations by priority, the lock must be held
+func (b *BlockSimulationRateLimiter) dispatch() {
+	sort.SliceStable(b.queue, func(i, j int) bool { return b.before(b.queue[i], b.queue[j]) })
+
+	remaining := b.queue[:0]
+	for _, r := range b.queue {
+		node, ok := r.pool.selectNode(r.exclude)
+		if !ok {
+			r.result <- blockSimAssignment{err: ErrNoBlockSimNode}
+		} else if node == nil {
+			remaining = append(remaining, r)
+		} else {
+			node.active++
+			r.result <- blockSimAssignment{node: node}
+		}
+	}
+	b.queue = remaining
+}
+
+// acquire queues the simulation until a node of its pool has capacity, and reserves a slot on it
+func (b *BlockSimulationRateLimiter) acquire(ctx context.Context, r *blockSimRequest) (*blockSimNode, error) {
+	b.mu.Lock()
+	b.queue = append(b.queue, r)
+	b.dispatch()
+	b.mu.Unlock()
+
+	select {
+	case assignment := <-r.result:
+		return assignment.node, assignment.err
+	case <-ctx.Done():
+	}
+
+	// remove the request from the queue, unless it was dispatched in the meantime
+	b.mu.Lock()
+	for i, queued := range b.queue {
+		if queued == r {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			b.mu.Unlock()
+			return nil, ErrRequestClosed
+		}
+	}
+	b.mu.Unlock()
+
+	if assignment := <-r.result; assignment.node != nil {
+		b.release(assignment.node)
+	}
+	return nil, ErrRequestClosed
 }
 
-func NewBlockSimulationRateLimiter(blockSimURL string) *BlockSimulationRateLimiter {
-	return &BlockSimulationRateLimiter{
-		cv:          sync.NewCond(&sync.Mutex{}),
-		counter:     0,
-		blockSimURL: blockSimURL,
+func (b *BlockSimulationRateLimiter) release(node *blockSimNode) {
+	b.mu.Lock()
+	node.active--
+	b.dispatch()
+	b.mu.Unlock()
+}
+
+// dropPastSlots removes the queued simulations for slots up to headSlot, which can't win anymore, and returns their number
+func (b *BlockSimulationRateLimiter) dropPastSlots(headSlot uint64) (numDropped int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	remaining := b.queue[:0]
+	for _, r := range b.queue {
+		if r.slot <= headSlot && !r.isOptimistic {
+			r.result <- blockSimAssignment{err: ErrSlotPassed}
+			numDropped++
+		} else {
+			remaining = append(remaining, r)
+		}
 	}
+	b.queue = remaining
+	return numDropped
 }
 
-func (b *BlockSimulationRateLimiter) send(context context.Context, payload *BuilderBlockValidationRequest, isHighPrio bool) error {
-	b.cv.L.Lock()
+func (b *BlockSimulationRateLimiter) send(ctx context.Context, payload *BuilderBlockValidationRequest, isHighPrio, isOptimistic bool) error {
+	ctx, span := tracing.Tracer.Start(ctx, "blockSim")
+	defer span.End()
+
 	cnt := atomic.AddInt64(&b.counter, 1)
-	if maxConcurrentBlocks > 0 && cnt > maxConcurrentBlocks {
-		b.cv.Wait()
+	metrics.BlockSimQueueDepth.Observe(float64(cnt - 1))
+	defer atomic.AddInt64(&b.counter, -1)
+
+	r := &blockSimRequest{
+		pool:          b.pool,
+		exclude:       make(map[*blockSimNode]bool),
+		isHighPrio:    isHighPrio,
+		isOptimistic:  isOptimistic,
+		slot:          payload.BuilderSubmitBlockRequest.Slot(),
+		builderPubkey: payload.BuilderSubmitBlockRequest.Message().BuilderPubkey.String(),
+		seq:           atomic.AddUint64(&b.seq, 1),
+		result:        make(chan blockSimAssignment, 1),
+	}
+	if isHighPrio && b.highPrioPool != nil {
+		r.pool = b.highPrioPool
 	}
-	b.cv.L.Unlock()
 
+	b.mu.Lock()
+	b.builderPending[r.builderPubkey]++
+	b.mu.Unlock()
 	defer func() {
-		b.cv.L.Lock()
-		atomic.AddInt64(&b.counter, -1)
-		b.cv.Signal()
-		b.cv.L.Unlock()
+		b.mu.Lock()
+		b.builderPending[r.builderPubkey]--
+		if b.builderPending[r.builderPubkey] <= 0 {
+			delete(b.builderPending, r.builderPubkey)
+		}
+		b.mu.Unlock()
 	}()
 
-	if err := context.Err(); err != nil {
-		return ErrRequestClosed
+	method := "flashbots_validateBuilderSubmissionV1"
+	if payload.BuilderSubmitBlockRequest.Capella != nil {
+		method = "flashbots_validateBuilderSubmissionV2"
 	}
+	simReq := jsonrpc.NewJSONRPCRequest("1", method, payload)
 
-	simReq := jsonrpc.NewJSONRPCRequest("1", "flashbots_validateBuilderSubmissionV1", payload)
-	simResp, err := SendJSONRPCRequest(*simReq, b.blockSimURL, isHighPrio)
-	if err != nil {
-		return err
-	} else if simResp.Error != nil {
-		return fmt.Errorf("%w: %s", ErrSimulationFailed, simResp.Error.Message)
-	}
+	// try the nodes of the pool until one responds, keeping the place in the queue
+	var err error
+	for {
+		node, acquireErr := b.acquire(ctx, r)
+		if errors.Is(acquireErr, ErrNoBlockSimNode) && err != nil {
+			acquireErr = err // all nodes failed, return the last error
+		}
+		if acquireErr != nil {
+			span.SetStatus(codes.Error, acquireErr.Error())
+			return acquireErr
+		}
+		r.exclude[node] = true
+		span.AddEvent("dequeued", trace.WithAttributes(attribute.String("node", node.url)))
+
+		if ctx.Err() != nil {
+			b.release(node)
+			return ErrRequestClosed
+		}
+
+		start := time.Now()
+		var simResp *jsonrpc.JSONRPCResponse
+		simResp, err = SendJSONRPCRequest(ctx, *simReq, node.url, isHighPrio)
+		b.release(node)
+		if ctx.Err() != nil {
+			// the builder went away during the simulation, which says nothing about the node
+			return ErrRequestClosed
+		} else if err != nil {
+			// the node is unreachable, mark it unhealthy until the next health check and fail over
+			metrics.BlockSimDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+			node.healthy.Store(false)
+			continue
+		} else if simResp.Error != nil {
+			metrics.BlockSimDuration.WithLabelValues("invalid").Observe(time.Since(start).Seconds())
+			span.SetStatus(codes.Error, simResp.Error.Message)
+			return fmt.Errorf("%w: %s", ErrSimulationFailed, simResp.Error.Message)
+		}
 
-	return nil
+		metrics.BlockSimDuration.WithLabelValues("valid").Observe(time.Since(start).Seconds())
+		return nil
+	}
 }
 
 // currentCounter returns the number of waiting and active requests
@@ -70,14 +358,62 @@ func (b *BlockSimulationRateLimiter) currentCounter() int64 {
 	return atomic.LoadInt64(&b.counter)
 }
 
-// SendJSONRPCRequest sends the request to URL and returns the general JsonRpcResponse, or an error (note: not the JSONRPCError)
-func SendJSONRPCRequest(req jsonrpc.JSONRPCRequest, url string, isHighPrio bool) (res *jsonrpc.JSONRPCResponse, err error) {
+// checkHealth queries the block number of all nodes, and marks those which are unreachable or lagging behind as unhealthy
+func (b *BlockSimulationRateLimiter) checkHealth(log *logrus.Entry) {
+	blockNumbers := make([]uint64, len(b.nodes))
+	errs := make([]error, len(b.nodes))
+
+	var wg sync.WaitGroup
+	for i, node := range b.nodes {
+		wg.Add(1)
+		go func(i int, node *blockSimNode) {
+			defer wg.Done()
+			blockNumbers[i], errs[i] = node.blockNumber()
+		}(i, node)
+	}
+	wg.Wait()
+
+	var highestBlockNumber uint64
+	for i := range b.nodes {
+		if errs[i] == nil && blockNumbers[i] > highestBlockNumber {
+			highestBlockNumber = blockNumbers[i]
+		}
+	}
+
+	for i, node := range b.nodes {
+		healthy := errs[i] == nil && blockNumbers[i]+blockSimMaxLagBlocks >= highestBlockNumber
+		if node.healthy.Swap(healthy) != healthy {
+			log.WithError(errs[i]).WithFields(logrus.Fields{
+				"node":               node.url,
+				"blockNumber":        blockNumbers[i],
+				"highestBlockNumber": highestBlockNumber,
+				"healthy":            healthy,
+			}).Warn("block simulation node health changed")
+		}
+	}
+}
+
+// startHealthChecks periodically checks the health of all nodes (blocking)
+func (b *BlockSimulationRateLimiter) startHealthChecks(log *logrus.Entry) {
+	for {
+		b.checkHealth(log)
+		time.Sleep(blockSimHealthCheckInterval)
+	}
+}
+
+// SendJSONRPCRequest sends the request to URL and returns the general JsonRpcResponse, or an error (note: not the JSONRPCError).
+// The trace context of ctx is propagated in the request headers, so the receiving node can join the trace.
+func SendJSONRPCRequest(ctx context.Context, req jsonrpc.JSONRPCRequest, url string, isHighPrio bool) (res *jsonrpc.JSONRPCResponse, err error) {
+	return sendJSONRPCRequest(ctx, http.DefaultClient, req, url, isHighPrio)
+}
+
+func sendJSONRPCRequest(ctx context.Context, client *http.Client, req jsonrpc.JSONRPCRequest, url string, isHighPrio bool) (res *jsonrpc.JSONRPCResponse, err error) {
 	buf, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(buf))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
 	if err != nil {
 		return nil, err
 	}
@@ -87,9 +423,10 @@ func SendJSONRPCRequest(req jsonrpc.JSONRPCRequest, url string, isHighPrio bool)
 	if isHighPrio {
 		httpReq.Header.Add("X-High-Priority", "true")
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
 	// execute request
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
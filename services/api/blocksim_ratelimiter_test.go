@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/flashbots/go-utils/jsonrpc"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBlockSimNode returns a JSON-RPC server which counts the simulations, and answers eth_blockNumber with blockNumber
+func newTestBlockSimNode(t *testing.T, blockNumber string) (server *httptest.Server, numSimulations *int64) {
+	t.Helper()
+	numSimulations = new(int64)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jsonrpc.JSONRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		resp := jsonrpc.JSONRPCResponse{ID: req.ID, Version: "2.0"}
+		if req.Method == "eth_blockNumber" {
+			resp.Result = json.RawMessage(`"` + blockNumber + `"`)
+		} else {
+			atomic.AddInt64(numSimulations, 1)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(server.Close)
+	return server, numSimulations
+}
+
+func newTestValidationRequest(t *testing.T) *BuilderBlockValidationRequest {
+	t.Helper()
+	jsonPayload, err := os.ReadFile("../../testdata/submitBlockPayloadCapella.json")
+	require.NoError(t, err)
+	payload, err := common.DecodeBuilderSubmitBlockRequest(jsonPayload, true)
+	require.NoError(t, err)
+	return &BuilderBlockValidationRequest{BuilderSubmitBlockRequest: payload}
+}
+
+func TestBlockSimPoolSelectNode(t *testing.T) {
+	nodes := []*blockSimNode{{url: "a", active: 2}, {url: "b", active: 1}, {url: "c", active: 3}}
+	for _, n := range nodes {
+		n.healthy.Store(true)
+	}
+	pool := &blockSimPool{nodes: nodes}
+
+	t.Run("least-loaded", func(t *testing.T) {
+		node, ok := pool.selectNode(nil)
+		require.True(t, ok)
+		require.Equal(t, "b", node.url)
+
+		// excluded and unhealthy nodes are skipped
+		node, ok = pool.selectNode(map[*blockSimNode]bool{nodes[1]: true})
+		require.True(t, ok)
+		require.Equal(t, "a", node.url)
+
+		nodes[0].healthy.Store(false)
+		defer nodes[0].healthy.Store(true)
+		node, ok = pool.selectNode(map[*blockSimNode]bool{nodes[1]: true})
+		require.True(t, ok)
+		require.Equal(t, "c", node.url)
+	})
+
+	t.Run("round-robin", func(t *testing.T) {
+		blockSimSelection = BlockSimSelectionRoundRobin
+		defer func() { blockSimSelection = BlockSimSelectionLeastLoaded }()
+
+		for _, expected := range []string{"a", "b", "c", "a"} {
+			node, ok := pool.selectNode(nil)
+			require.True(t, ok)
+			require.Equal(t, expected, node.url)
+		}
+	})
+
+	t.Run("unhealthy nodes are used if no healthy node is left", func(t *testing.T) {
+		for _, n := range nodes {
+			n.healthy.Store(false)
+			defer n.healthy.Store(true)
+		}
+		node, ok := pool.selectNode(nil)
+		require.True(t, ok)
+		require.Equal(t, "b", node.url)
+	})
+
+	t.Run("all nodes at capacity or tried", func(t *testing.T) {
+		node, ok := pool.selectNode(map[*blockSimNode]bool{nodes[0]: true, nodes[1]: true, nodes[2]: true})
+		require.False(t, ok)
+		require.Nil(t, node)
+
+		maxConcurrentBlocks = 3
+		defer func() { maxConcurrentBlocks = 4 }()
+		node, ok = pool.selectNode(map[*blockSimNode]bool{nodes[1]: true})
+		require.True(t, ok)
+		require.Equal(t, "a", node.url)
+		nodes[0].active = 3
+		defer func() { nodes[0].active = 2 }()
+		node, ok = pool.selectNode(map[*blockSimNode]bool{nodes[1]: true})
+		require.True(t, ok)
+		require.Nil(t, node)
+	})
+}
+
+func TestBlockSimulationRateLimiterFailover(t *testing.T) {
+	validationRequest := newTestValidationRequest(t)
+	down, _ := newTestBlockSimNode(t, "0x1")
+	down.Close()
+	up, numSimulations := newTestBlockSimNode(t, "0x1")
+
+	// the unreachable node is marked unhealthy, and the request is sent to the next one
+	rateLimiter := NewBlockSimulationRateLimiter([]string{down.URL, up.URL}, nil)
+	err := rateLimiter.send(context.Background(), validationRequest, false, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), atomic.LoadInt64(numSimulations))
+	require.False(t, rateLimiter.nodes[0].healthy.Load())
+	require.True(t, rateLimiter.nodes[1].healthy.Load())
+
+	// without any reachable node, the transport error is returned
+	rateLimiter = NewBlockSimulationRateLimiter([]string{down.URL}, nil)
+	err = rateLimiter.send(context.Background(), validationRequest, false, false)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrSimulationFailed)
+}
+
+func TestBlockSimulationRateLimiterRequestClosedInFlight(t *testing.T) {
+	validationRequest := newTestValidationRequest(t)
+	received, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	// the builder disconnects during the simulation: the node stays healthy and is released
+	rateLimiter := NewBlockSimulationRateLimiter([]string{server.URL}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+	err := rateLimiter.send(ctx, validationRequest, false, false)
+	require.ErrorIs(t, err, ErrRequestClosed)
+	require.True(t, rateLimiter.nodes[0].healthy.Load())
+	require.Equal(t, int64(0), rateLimiter.nodes[0].active)
+}
+
+func TestBlockSimulationRateLimiterHighPrioPool(t *testing.T) {
+	validationRequest := newTestValidationRequest(t)
+	regular, numRegular := newTestBlockSimNode(t, "0x1")
+	highPrio, numHighPrio := newTestBlockSimNode(t, "0x1")
+
+	rateLimiter := NewBlockSimulationRateLimiter([]string{regular.URL}, []string{highPrio.URL})
+	require.NoError(t, rateLimiter.send(context.Background(), validationRequest, false, false))
+	require.NoError(t, rateLimiter.send(context.Background(), validationRequest, true, false))
+	require.NoError(t, rateLimiter.send(context.Background(), validationRequest, true, false))
+	require.Equal(t, int64(1), atomic.LoadInt64(numRegular))
+	require.Equal(t, int64(2), atomic.LoadInt64(numHighPrio))
+
+	// without a high-prio pool, high-prio builders use the regular pool
+	rateLimiter = NewBlockSimulationRateLimiter([]string{regular.URL}, nil)
+	require.NoError(t, rateLimiter.send(context.Background(), validationRequest, true, false))
+	require.Equal(t, int64(2), atomic.LoadInt64(numRegular))
+}
+
+func TestBlockSimulationRateLimiterCheckHealth(t *testing.T) {
+	synced, _ := newTestBlockSimNode(t, "0x64")
+	lagging, _ := newTestBlockSimNode(t, "0x61")
+	down, _ := newTestBlockSimNode(t, "0x64")
+	down.Close()
+
+	rateLimiter := NewBlockSimulationRateLimiter([]string{synced.URL, lagging.URL, down.URL}, nil)
+	rateLimiter.checkHealth(common.TestLog)
+	require.True(t, rateLimiter.nodes[0].healthy.Load())
+	require.False(t, rateLimiter.nodes[1].healthy.Load())
+	require.False(t, rateLimiter.nodes[2].healthy.Load())
+}
+
+func TestBlockSimulationRateLimiterScheduling(t *testing.T) {
+	maxConcurrentBlocks = 1
+	defer func() { maxConcurrentBlocks = 4 }()
+
+	rateLimiter := NewBlockSimulationRateLimiter([]string{"http://node"}, nil)
+	node := rateLimiter.nodes[0]
+
+	// queue requests while the node is busy, and return them in the order they get the node
+	schedule := func(requests ...*blockSimRequest) (order []*blockSimRequest) {
+		t.Helper()
+		rateLimiter.mu.Lock()
+		node.active = 1
+		for i, r := range requests {
+			r.pool = rateLimiter.pool
+			r.seq = uint64(i)
+			r.result = make(chan blockSimAssignment, 1)
+			rateLimiter.queue = append(rateLimiter.queue, r)
+			rateLimiter.builderPending[r.builderPubkey]++
+		}
+		rateLimiter.mu.Unlock()
+
+		for range requests {
+			rateLimiter.release(node)
+			for _, r := range requests {
+				select {
+				case assignment := <-r.result:
+					require.Equal(t, node, assignment.node)
+					order = append(order, r)
+					rateLimiter.mu.Lock()
+					rateLimiter.builderPending[r.builderPubkey]--
+					rateLimiter.mu.Unlock()
+				default:
+				}
+			}
+		}
+		rateLimiter.release(node)
+		require.Empty(t, rateLimiter.queue)
+		return order
+	}
+
+	t.Run("high-prio first", func(t *testing.T) {
+		lowPrio := &blockSimRequest{slot: 11, builderPubkey: "a"}
+		highPrio := &blockSimRequest{slot: 10, builderPubkey: "b", isHighPrio: true}
+		require.Equal(t, []*blockSimRequest{highPrio, lowPrio}, schedule(lowPrio, highPrio))
+	})
+
+	t.Run("newest slot first", func(t *testing.T) {
+		older := &blockSimRequest{slot: 10, builderPubkey: "a"}
+		newer := &blockSimRequest{slot: 11, builderPubkey: "a"}
+		require.Equal(t, []*blockSimRequest{newer, older}, schedule(older, newer))
+	})
+
+	t.Run("fair share between builders", func(t *testing.T) {
+		// the spammy builder has more pending simulations, so the other builder goes first
+		spammy1 := &blockSimRequest{slot: 10, builderPubkey: "spammy"}
+		spammy2 := &blockSimRequest{slot: 10, builderPubkey: "spammy"}
+		other := &blockSimRequest{slot: 10, builderPubkey: "other"}
+		require.Equal(t, []*blockSimRequest{other, spammy1, spammy2}, schedule(spammy1, spammy2, other))
+	})
+
+	t.Run("first come first served", func(t *testing.T) {
+		first := &blockSimRequest{slot: 10, builderPubkey: "a"}
+		second := &blockSimRequest{slot: 10, builderPubkey: "b"}
+		require.Equal(t, []*blockSimRequest{first, second}, schedule(first, second))
+	})
+}
+
+func TestBlockSimulationRateLimiterDropPastSlots(t *testing.T) {
+	rateLimiter := NewBlockSimulationRateLimiter(nil, nil)
+	past := &blockSimRequest{slot: 10, result: make(chan blockSimAssignment, 1)}
+	pastOptimistic := &blockSimRequest{slot: 10, isOptimistic: true, result: make(chan blockSimAssignment, 1)}
+	current := &blockSimRequest{slot: 11, result: make(chan blockSimAssignment, 1)}
+	rateLimiter.queue = []*blockSimRequest{past, pastOptimistic, current}
+
+	require.Equal(t, 1, rateLimiter.dropPastSlots(10))
+	require.ErrorIs(t, (<-past.result).err, ErrSlotPassed)
+	require.Equal(t, []*blockSimRequest{pastOptimistic, current}, rateLimiter.queue)
+}
+
+func TestBlockSimulationRateLimiterRequestClosed(t *testing.T) {
+	maxConcurrentBlocks = 1
+	defer func() { maxConcurrentBlocks = 4 }()
+
+	rateLimiter := NewBlockSimulationRateLimiter([]string{"http://node"}, nil)
+	rateLimiter.nodes[0].active = 1
+
+	// a closed request leaves the queue
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := &blockSimRequest{pool: rateLimiter.pool, slot: 10, result: make(chan blockSimAssignment, 1)}
+	node, err := rateLimiter.acquire(ctx, r)
+	require.ErrorIs(t, err, ErrRequestClosed)
+	require.Nil(t, node)
+	require.Empty(t, rateLimiter.queue)
+}
+
+func TestSendJSONRPCRequestContext(t *testing.T) {
+	server, numSimulations := newTestBlockSimNode(t, "0x1")
+
+	// the request is not sent once the context is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := sendJSONRPCRequest(ctx, http.DefaultClient, jsonrpc.JSONRPCRequest{Method: "flashbots_validateBuilderSubmissionV2"}, server.URL, false)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int64(0), atomic.LoadInt64(numSimulations))
+}
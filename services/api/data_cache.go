@@ -0,0 +1,356 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/go-utils/cli"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/datastore"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	dataCacheTTL          = time.Duration(cli.GetEnvInt("DATA_API_CACHE_TTL_MS", 2000)) * time.Millisecond
+	dataCacheFinalizedTTL = time.Duration(cli.GetEnvInt("DATA_API_CACHE_FINALIZED_TTL_SEC", 3600)) * time.Second
+	dataCacheSize         = cli.GetEnvInt("DATA_API_CACHE_SIZE", 1000)
+	dataCacheMaxBytes     = cli.GetEnvInt("DATA_API_CACHE_MAX_BYTES", 64*1024*1024)
+
+	// slots this far behind the head slot are finalized, so responses limited to them can't change anymore
+	dataCacheFinalizedSlots = uint64(2 * common.SlotsPerEpoch)
+
+	// response headers which are cached with the body
+	dataCacheHeaders = []string{"Content-Type", HeaderNextCursor}
+)
+
+// computeETag returns a strong ETag of the response body
+func computeETag(body []byte) string {
+	hash := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// etagMatches returns whether the If-None-Match header matches the ETag, using the weak comparison of RFC 9110
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// dataCacheKey returns the normalized request: the path and the non-empty arguments, sorted by name and value
+func dataCacheKey(req *http.Request) string {
+	args := url.Values{}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			if value != "" {
+				args.Add(name, value)
+			}
+		}
+		sort.Strings(args[name])
+	}
+	return req.URL.Path + "?" + args.Encode()
+}
+
+// dataCacheEntry is a cached data API response
+type dataCacheEntry struct {
+	Header    map[string]string `json:"header"`
+	Body      []byte            `json:"body"`
+	ExpiresAt int64             `json:"expires_at"` // unix milliseconds
+}
+
+func (e *dataCacheEntry) ttl() time.Duration {
+	return time.Until(time.UnixMilli(e.ExpiresAt))
+}
+
+// size approximates the memory used by the entry
+func (e *dataCacheEntry) size() int {
+	size := len(e.Body)
+	for name, value := range e.Header {
+		size += len(name) + len(value)
+	}
+	return size
+}
+
+// dataCacheCall is a handler call for a cache miss, which concurrent requests for the same key wait for
+type dataCacheCall struct {
+	done     chan struct{}
+	entry    *dataCacheEntry
+	recorder *dataCacheRecorder
+}
+
+// dataCache caches the data API responses in-process, backed by redis to share them between the API instances.
+// The in-process cache is bounded by the number of entries and by the total size of the keys and entries.
+type dataCache struct {
+	redis *datastore.RedisCache
+
+	mu       sync.Mutex
+	entries  map[string]*dataCacheEntry
+	numBytes int
+	maxSize  int
+	maxBytes int
+	calls    map[string]*dataCacheCall
+}
+
+func newDataCache(redis *datastore.RedisCache, maxSize, maxBytes int) *dataCache {
+	return &dataCache{
+		redis:    redis,
+		entries:  make(map[string]*dataCacheEntry),
+		maxSize:  maxSize,
+		maxBytes: maxBytes,
+		calls:    make(map[string]*dataCacheCall),
+	}
+}
+
+// get returns the unexpired entry from memory, or else from redis
+func (c *dataCache) get(key string) (*dataCacheEntry, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && entry.ttl() > 0 {
+		return entry, nil
+	}
+
+	if c.redis == nil {
+		return nil, nil
+	}
+	data, err := c.redis.GetDataAPIResponse(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	entry = new(dataCacheEntry)
+	if err = json.Unmarshal(data, entry); err != nil || entry.ttl() <= 0 {
+		return nil, err
+	}
+	c.setInMemory(key, entry)
+	return entry, nil
+}
+
+// set saves the entry in memory and in redis
+func (c *dataCache) set(key string, entry *dataCacheEntry) error {
+	c.setInMemory(key, entry)
+
+	if c.redis == nil {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.redis.SetDataAPIResponse(key, data, entry.ttl())
+}
+
+// setInMemory saves the entry, evicting expired entries (or else arbitrary ones) until it fits. Entries larger than
+// the whole cache are only kept in redis.
+func (c *dataCache) setInMemory(key string, entry *dataCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.delete(key)
+	size := len(key) + entry.size()
+	if size > c.maxBytes {
+		return
+	}
+
+	fits := func() bool { return len(c.entries) < c.maxSize && c.numBytes+size <= c.maxBytes }
+	if !fits() {
+		for k, e := range c.entries {
+			if e.ttl() <= 0 {
+				c.delete(k)
+			}
+		}
+		for k := range c.entries {
+			if fits() {
+				break
+			}
+			c.delete(k)
+		}
+	}
+	c.entries[key] = entry
+	c.numBytes += size
+}
+
+// delete removes the entry from memory, the lock must be held
+func (c *dataCache) delete(key string) {
+	if entry, ok := c.entries[key]; ok {
+		c.numBytes -= len(key) + entry.size()
+		delete(c.entries, key)
+	}
+}
+
+// do calls fn once for concurrent cache misses of the same key, and returns its result to all of them
+func (c *dataCache) do(key string, fn func() (*dataCacheEntry, *dataCacheRecorder)) (*dataCacheEntry, *dataCacheRecorder) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.entry, call.recorder
+	}
+	call := &dataCacheCall{done: make(chan struct{})} //nolint:exhaustruct
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.entry, call.recorder = fn()
+	return call.entry, call.recorder
+}
+
+// dataCacheRecorder captures the response of a data API handler
+type dataCacheRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *dataCacheRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *dataCacheRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *dataCacheRecorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+// dataCacheTTLForArgs returns the long TTL if one of the slotArgs limits the response to finalized slots
+func (api *RelayAPI) dataCacheTTLForArgs(args url.Values, slotArgs []string) time.Duration {
+	headSlot := api.headSlot.Load()
+	if headSlot <= dataCacheFinalizedSlots {
+		return dataCacheTTL
+	}
+	for _, name := range slotArgs {
+		slot, err := strconv.ParseUint(args.Get(name), 10, 64)
+		if err == nil && slot <= headSlot-dataCacheFinalizedSlots {
+			return dataCacheFinalizedTTL
+		}
+	}
+	return dataCacheTTL
+}
+
+// recordDataResponse calls the data API handler, and returns its successful response as an entry with an ETag of the
+// body, or else nil and the recorded response
+func (api *RelayAPI) recordDataResponse(handler http.HandlerFunc, req *http.Request, ttl time.Duration) (*dataCacheEntry, *dataCacheRecorder) {
+	recorder := &dataCacheRecorder{header: make(http.Header)} //nolint:exhaustruct
+	handler(recorder, req)
+	if recorder.code != http.StatusOK {
+		return nil, recorder
+	}
+
+	entry := &dataCacheEntry{
+		Header:    map[string]string{"ETag": computeETag(recorder.body.Bytes())},
+		Body:      recorder.body.Bytes(),
+		ExpiresAt: time.Now().Add(ttl).UnixMilli(),
+	}
+	for _, name := range dataCacheHeaders {
+		if value := recorder.header.Get(name); value != "" {
+			entry.Header[name] = value
+		}
+	}
+	return entry, recorder
+}
+
+// writeRecordedResponse writes the response of a failed handler call as is
+func writeRecordedResponse(w http.ResponseWriter, recorder *dataCacheRecorder) {
+	for name, values := range recorder.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(recorder.code)
+	_, _ = w.Write(recorder.body.Bytes())
+}
+
+// writeDataEntry writes the successful response, or 304 Not Modified without body if the If-None-Match header matches
+// its ETag
+func writeDataEntry(w http.ResponseWriter, req *http.Request, entry *dataCacheEntry) error {
+	for name, value := range entry.Header {
+		w.Header().Set(name, value)
+	}
+	if etag := entry.Header["ETag"]; etag != "" && etagMatches(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(entry.Body)
+	return err
+}
+
+// cachedDataHandler serves the responses of the data API handler from the cache, keyed by the normalized request.
+// slotArgs are the arguments which limit the response to slots up to their value: responses for finalized slots are
+// cached with the long TTL. Only successful responses are cached. Concurrent requests missing the cache for the same
+// key share a single handler call. Successful responses have an ETag of their body for conditional requests, also if
+// the cache is disabled.
+func (api *RelayAPI) cachedDataHandler(handler http.HandlerFunc, slotArgs ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if api.dataCache == nil {
+			entry, recorder := api.recordDataResponse(handler, req, 0)
+			if entry == nil {
+				writeRecordedResponse(w, recorder)
+			} else if err := writeDataEntry(w, req, entry); err != nil {
+				api.log.WithError(err).Error("could not write data API response")
+			}
+			return
+		}
+
+		key := dataCacheKey(req)
+		log := api.log.WithFields(logrus.Fields{
+			"method": "cachedDataHandler",
+			"key":    key,
+		})
+
+		entry, err := api.dataCache.get(key)
+		if err != nil {
+			log.WithError(err).Error("could not get cached data API response")
+		}
+
+		if entry == nil {
+			var recorder *dataCacheRecorder
+			entry, recorder = api.dataCache.do(key, func() (*dataCacheEntry, *dataCacheRecorder) {
+				entry, recorder := api.recordDataResponse(handler, req, api.dataCacheTTLForArgs(req.URL.Query(), slotArgs))
+				if entry == nil {
+					return nil, recorder
+				}
+				if err := api.dataCache.set(key, entry); err != nil {
+					log.WithError(err).Error("could not cache data API response")
+				}
+				return entry, recorder
+			})
+
+			if entry == nil {
+				if recorder == nil {
+					api.RespondError(w, http.StatusInternalServerError, "internal server error")
+					return
+				}
+				writeRecordedResponse(w, recorder)
+				return
+			}
+		}
+
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(entry.ttl().Seconds())))
+		if err = writeDataEntry(w, req, entry); err != nil {
+			log.WithError(err).Error("could not write cached data API response")
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mev-boost-relay/database"
+	"github.com/stretchr/testify/require"
+)
+
+// countingPayloadsDB counts the queries of delivered payloads
+type countingPayloadsDB struct {
+	database.MockDB
+	numCalls int
+}
+
+func (db *countingPayloadsDB) GetRecentDeliveredPayloads(filters database.GetPayloadsFilters) ([]*database.DeliveredPayloadEntry, error) {
+	db.numCalls++
+	return []*database.DeliveredPayloadEntry{{Slot: filters.Slot, Value: fmt.Sprint(db.numCalls)}}, nil
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc"`
+	require.True(t, etagMatches(`"abc"`, etag))
+	require.True(t, etagMatches(`W/"abc"`, etag))
+	require.True(t, etagMatches(`"xyz", "abc"`, etag))
+	require.True(t, etagMatches(`*`, etag))
+	require.False(t, etagMatches(``, etag))
+	require.False(t, etagMatches(`"xyz"`, etag))
+	require.False(t, etagMatches(`abc`, etag))
+}
+
+func TestDataCacheKey(t *testing.T) {
+	key := func(query string) string {
+		return dataCacheKey(httptest.NewRequest(http.MethodGet, pathDataProposerPayloadDelivered+"?"+query, nil))
+	}
+	require.Equal(t, pathDataProposerPayloadDelivered+"?limit=10&slot=100", key("slot=100&limit=10"))
+	require.Equal(t, key("slot=100&limit=10"), key("limit=10&slot=100&builder_pubkey="))
+	require.Equal(t, key("a=1&a=2"), key("a=2&a=1"))
+	require.NotEqual(t, key("slot=100"), key("slot=101"))
+}
+
+func TestDataCacheTTL(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	args := url.Values{"slot": []string{"1000"}, "cursor": []string{"900"}}
+
+	// without a head slot, nothing is known to be finalized
+	require.Equal(t, dataCacheTTL, backend.relay.dataCacheTTLForArgs(args, []string{"slot"}))
+
+	backend.relay.headSlot.Store(1000 + dataCacheFinalizedSlots)
+	require.Equal(t, dataCacheFinalizedTTL, backend.relay.dataCacheTTLForArgs(args, []string{"slot"}))
+	require.Equal(t, dataCacheTTL, backend.relay.dataCacheTTLForArgs(args, nil))
+	backend.relay.headSlot.Store(999 + dataCacheFinalizedSlots)
+	require.Equal(t, dataCacheTTL, backend.relay.dataCacheTTLForArgs(args, []string{"slot"}))
+	require.Equal(t, dataCacheFinalizedTTL, backend.relay.dataCacheTTLForArgs(args, []string{"slot", "cursor"}))
+}
+
+func TestDataCacheEviction(t *testing.T) {
+	cache := newDataCache(nil, 2, 1024)
+	expires := time.Now().Add(time.Minute).UnixMilli()
+	require.NoError(t, cache.set("expired", &dataCacheEntry{ExpiresAt: time.Now().Add(-time.Second).UnixMilli()}))
+	require.NoError(t, cache.set("a", &dataCacheEntry{ExpiresAt: expires}))
+	require.NoError(t, cache.set("b", &dataCacheEntry{ExpiresAt: expires}))
+	require.Len(t, cache.entries, 2)
+	require.Contains(t, cache.entries, "a")
+	require.Contains(t, cache.entries, "b")
+
+	// expired entries are never returned
+	entry, err := cache.get("expired")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+
+	require.NoError(t, cache.set("c", &dataCacheEntry{ExpiresAt: expires}))
+	require.Len(t, cache.entries, 2)
+	require.Contains(t, cache.entries, "c")
+
+	t.Run("by total size", func(t *testing.T) {
+		cache := newDataCache(nil, 10, 100)
+		require.NoError(t, cache.set("a", &dataCacheEntry{Body: make([]byte, 60), ExpiresAt: expires}))
+		require.NoError(t, cache.set("a", &dataCacheEntry{Body: make([]byte, 40), ExpiresAt: expires}))
+		require.Equal(t, 41, cache.numBytes)
+		require.NoError(t, cache.set("b", &dataCacheEntry{Body: make([]byte, 40), ExpiresAt: expires}))
+		require.Equal(t, 82, cache.numBytes)
+
+		require.NoError(t, cache.set("c", &dataCacheEntry{Body: make([]byte, 40), ExpiresAt: expires}))
+		require.Len(t, cache.entries, 2)
+		require.Contains(t, cache.entries, "c")
+		require.Equal(t, 82, cache.numBytes)
+
+		// entries larger than the cache are not kept in memory
+		require.NoError(t, cache.set("d", &dataCacheEntry{Body: make([]byte, 100), ExpiresAt: expires}))
+		require.NotContains(t, cache.entries, "d")
+		require.Equal(t, 82, cache.numBytes)
+	})
+}
+
+func TestDataCacheDo(t *testing.T) {
+	cache := newDataCache(nil, 10, 1024)
+	numCalls := 0
+	started, release := make(chan struct{}), make(chan struct{})
+	fn := func() (*dataCacheEntry, *dataCacheRecorder) {
+		numCalls++
+		close(started)
+		<-release
+		return &dataCacheEntry{Body: []byte("body")}, nil
+	}
+
+	// concurrent misses of the same key wait for the first call
+	results := make(chan *dataCacheEntry, 3)
+	go func() {
+		entry, _ := cache.do("a", fn)
+		results <- entry
+	}()
+	<-started
+	for i := 0; i < 2; i++ {
+		go func() {
+			entry, _ := cache.do("a", fn)
+			results <- entry
+		}()
+	}
+	time.Sleep(20 * time.Millisecond) // let the other requests start waiting
+	close(release)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, []byte("body"), (<-results).Body)
+	}
+	require.Equal(t, 1, numCalls)
+	require.Empty(t, cache.calls)
+}
+
+func TestCachedDataHandler(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	db := &countingPayloadsDB{}
+	backend.relay.db = db
+	path := pathDataProposerPayloadDelivered + "?slot=100"
+
+	// the second request is served from the cache
+	rr := backend.request(http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	rr2 := backend.request(http.MethodGet, path+"&limit=", nil)
+	require.Equal(t, http.StatusOK, rr2.Code)
+	require.Equal(t, rr.Body.String(), rr2.Body.String())
+	require.Equal(t, etag, rr2.Header().Get("ETag"))
+	require.Equal(t, 1, db.numCalls)
+
+	// other filters are queried
+	rr = backend.request(http.MethodGet, pathDataProposerPayloadDelivered+"?slot=101", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotEqual(t, etag, rr.Header().Get("ETag"))
+	require.Equal(t, 2, db.numCalls)
+
+	t.Run("if-none-match", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("If-None-Match", etag)
+		rr := httptest.NewRecorder()
+		backend.relay.getRouter().ServeHTTP(rr, req)
+		require.Equal(t, http.StatusNotModified, rr.Code)
+		require.Empty(t, rr.Body.Bytes())
+		require.Equal(t, etag, rr.Header().Get("ETag"))
+	})
+
+	t.Run("shared with other instances via redis", func(t *testing.T) {
+		entry, err := newDataCache(backend.redis, 10, 1024).get(pathDataProposerPayloadDelivered + "?slot=100")
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+		require.Equal(t, etag, entry.Header["ETag"])
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		rr := backend.request(http.MethodGet, pathDataProposerPayloadDelivered+"?slot=abc", nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		entry, err := backend.relay.dataCache.get(pathDataProposerPayloadDelivered + "?slot=abc")
+		require.NoError(t, err)
+		require.Nil(t, entry)
+	})
+
+	t.Run("next cursor header is cached", func(t *testing.T) {
+		db := &builderSubmissionsDB{}
+		for i := 0; i < 2; i++ {
+			db.entries = append(db.entries, &database.BuilderBlockSubmissionEntry{ID: int64(10 + i), Slot: uint64(100 - i), Value: fmt.Sprint(i)})
+		}
+		backend.relay.db = db
+
+		rr := backend.request(http.MethodGet, pathDataBuilderBidsReceived+"?limit=1", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NotEmpty(t, rr.Header().Get(HeaderNextCursor))
+		rr2 := backend.request(http.MethodGet, pathDataBuilderBidsReceived+"?limit=1", nil)
+		require.Equal(t, rr.Header().Get(HeaderNextCursor), rr2.Header().Get(HeaderNextCursor))
+		require.Len(t, db.filters, 1)
+	})
+
+	t.Run("etag without the cache", func(t *testing.T) {
+		db := &builderSubmissionsDB{}
+		backend.relay.db = db
+		backend.relay.dataCache = nil
+
+		rr := backend.request(http.MethodGet, pathDataBuilderBidsReceived, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		etag := rr.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+		require.Empty(t, rr.Header().Get("Cache-Control"))
+
+		req := httptest.NewRequest(http.MethodGet, pathDataBuilderBidsReceived, nil)
+		req.Header.Set("If-None-Match", etag)
+		rr = httptest.NewRecorder()
+		backend.relay.getRouter().ServeHTTP(rr, req)
+		require.Equal(t, http.StatusNotModified, rr.Code)
+		require.Empty(t, rr.Body.Bytes())
+		require.Len(t, db.filters, 2)
+
+		rr = backend.request(http.MethodGet, pathDataBuilderBidsReceived+"?slot=abc", nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Empty(t, rr.Header().Get("ETag"))
+	})
+}
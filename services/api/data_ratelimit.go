@@ -0,0 +1,184 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/go-utils/cli"
+	"github.com/flashbots/mev-boost-relay/database"
+)
+
+var (
+	// requests per minute and burst size of anonymous clients (per IP) and of API keys, a rate of 0 disables the limit
+	dataRateLimitAnonymous = rateLimitTier{
+		perMinute: float64(cli.GetEnvInt("DATA_API_RATE_LIMIT_ANON", 300)),
+		burst:     float64(cli.GetEnvInt("DATA_API_RATE_LIMIT_ANON_BURST", 60)),
+	}
+	dataRateLimitKeyed = rateLimitTier{
+		perMinute: float64(cli.GetEnvInt("DATA_API_RATE_LIMIT_KEYED", 3000)),
+		burst:     float64(cli.GetEnvInt("DATA_API_RATE_LIMIT_KEYED_BURST", 300)),
+	}
+
+	// number of reverse proxies in front of the API instances which append the client address to X-Forwarded-For.
+	// Without trusted proxies the header is ignored, as clients can set it to anything.
+	dataTrustedProxyHops = cli.GetEnvInt("DATA_API_TRUSTED_PROXY_HOPS", 0)
+
+	// how often the API keys are reloaded from the database
+	dataAPIKeysReloadInterval = 30 * time.Second
+
+	// how often buckets which are full again are dropped
+	rateLimiterPruneInterval = time.Minute
+)
+
+type rateLimitTier struct {
+	perMinute float64
+	burst     float64
+}
+
+func (t rateLimitTier) ratePerSec() float64 {
+	return t.perMinute / 60
+}
+
+// tokenBucket holds the tokens of a client at the time of its last request
+type tokenBucket struct {
+	tier      rateLimitTier
+	tokens    float64
+	updatedAt time.Time
+}
+
+// refill adds the tokens since the last request, up to the burst size
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.tier.burst, b.tokens+now.Sub(b.updatedAt).Seconds()*b.tier.ratePerSec())
+	b.updatedAt = now
+}
+
+// rateLimiter limits the requests of each client with a token bucket, which holds up to burst tokens and is
+// refilled at the rate of its tier
+type rateLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*tokenBucket
+	prunedAt time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets:  make(map[string]*tokenBucket),
+		prunedAt: time.Now(),
+	}
+}
+
+// allow takes a token from the client's bucket. If there is none, it returns how long until the next token.
+func (l *rateLimiter) allow(client string, tier rateLimitTier, now time.Time) (ok bool, retryAfter time.Duration) {
+	if tier.perMinute <= 0 {
+		return true, 0
+	}
+	tier.burst = math.Max(tier.burst, 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.prunedAt) > rateLimiterPruneInterval {
+		for c, b := range l.buckets {
+			if b.refill(now); b.tokens >= b.tier.burst {
+				delete(l.buckets, c)
+			}
+		}
+		l.prunedAt = now
+	}
+
+	bucket, found := l.buckets[client]
+	if !found || bucket.tier != tier {
+		bucket = &tokenBucket{tier: tier, tokens: tier.burst, updatedAt: now}
+		l.buckets[client] = bucket
+	}
+	bucket.refill(now)
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / tier.ratePerSec() * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// clientIP returns the IP of the client, without the port. Behind trustedProxyHops proxies, this is the X-Forwarded-For
+// entry added by the first of them, that many entries from the right: entries further left are set by the client.
+// Otherwise it's the remote address.
+func clientIP(req *http.Request, trustedProxyHops int) string {
+	ip := req.RemoteAddr
+	if forwarded := req.Header.Values("X-Forwarded-For"); trustedProxyHops > 0 && len(forwarded) > 0 {
+		entries := strings.Split(strings.Join(forwarded, ","), ",")
+		ip = entries[0]
+		if len(entries) >= trustedProxyHops {
+			ip = entries[len(entries)-trustedProxyHops]
+		}
+	}
+	ip = strings.TrimSpace(ip)
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		return host
+	}
+	return ip
+}
+
+// reloadDataAPIKeys loads the hashes of the unrevoked API keys from the database
+func (api *RelayAPI) reloadDataAPIKeys() error {
+	entries, err := api.db.GetDataAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		if !entry.RevokedAt.Valid {
+			keys[entry.KeyHash] = entry.ID
+		}
+	}
+	api.dataAPIKeys.Store(&keys)
+	return nil
+}
+
+func (api *RelayAPI) startDataAPIKeyReloads() {
+	for {
+		time.Sleep(dataAPIKeysReloadInterval)
+		if err := api.reloadDataAPIKeys(); err != nil {
+			api.log.WithError(err).Error("could not reload data API keys, keeping the previous ones")
+		}
+	}
+}
+
+// dataAPIKeyID returns the id of the API key, if it's valid
+func (api *RelayAPI) dataAPIKeyID(key string) (id int64, ok bool) {
+	keys := api.dataAPIKeys.Load()
+	if keys == nil {
+		return 0, false
+	}
+	id, ok = (*keys)[database.HashDataAPIKey(key)]
+	return id, ok
+}
+
+// rateLimitedDataHandler limits the requests to the data API handler per API key (X-API-Key header), or else per
+// IP. Requests with an invalid key are rejected, and requests over the limit get a 429 response with Retry-After.
+func (api *RelayAPI) rateLimitedDataHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		client, tier := "ip:"+clientIP(req, dataTrustedProxyHops), dataRateLimitAnonymous
+		if key := req.Header.Get(HeaderAPIKey); key != "" {
+			id, ok := api.dataAPIKeyID(key)
+			if !ok {
+				api.RespondError(w, http.StatusUnauthorized, "invalid API key")
+				return
+			}
+			client, tier = "key:"+strconv.FormatInt(id, 10), dataRateLimitKeyed
+		}
+
+		if ok, retryAfter := api.dataRateLimiter.allow(client, tier, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			api.RespondError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		handler(w, req)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mev-boost-relay/database"
+	"github.com/stretchr/testify/require"
+)
+
+// dataAPIKeysDB returns fixed API keys
+type dataAPIKeysDB struct {
+	database.MockDB
+	keys []*database.DataAPIKeyEntry
+}
+
+func (db *dataAPIKeysDB) GetDataAPIKeys() ([]*database.DataAPIKeyEntry, error) {
+	return db.keys, nil
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := newRateLimiter()
+	tier := rateLimitTier{perMinute: 60, burst: 2}
+	now := time.Now()
+
+	// the burst is allowed at once, then one request per second
+	for i := 0; i < 2; i++ {
+		ok, _ := limiter.allow("a", tier, now)
+		require.True(t, ok)
+	}
+	ok, retryAfter := limiter.allow("a", tier, now)
+	require.False(t, ok)
+	require.Equal(t, time.Second, retryAfter)
+	ok, retryAfter = limiter.allow("a", tier, now.Add(500*time.Millisecond))
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, retryAfter)
+	ok, _ = limiter.allow("a", tier, now.Add(time.Second))
+	require.True(t, ok)
+
+	// clients have their own buckets
+	ok, _ = limiter.allow("b", tier, now)
+	require.True(t, ok)
+
+	// a rate of 0 disables the limit
+	for i := 0; i < 10; i++ {
+		ok, _ = limiter.allow("c", rateLimitTier{perMinute: 0, burst: 0}, now)
+		require.True(t, ok)
+	}
+
+	// buckets which are full again are dropped
+	ok, _ = limiter.allow("b", tier, now.Add(rateLimiterPruneInterval+time.Second))
+	require.True(t, ok)
+	require.Len(t, limiter.buckets, 1)
+	require.Contains(t, limiter.buckets, "b")
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	require.Equal(t, "10.0.0.1", clientIP(req, 0))
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	require.Equal(t, "2001:db8::1", clientIP(req, 0))
+
+	// the header is only used behind trusted proxies, taking the entry added by the first of them
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 192.168.0.1, 10.0.0.2")
+	require.Equal(t, "2001:db8::1", clientIP(req, 0))
+	require.Equal(t, "10.0.0.2", clientIP(req, 1))
+	require.Equal(t, "192.168.0.1", clientIP(req, 2))
+	req.Header.Add("X-Forwarded-For", "10.0.0.3")
+	require.Equal(t, "10.0.0.3", clientIP(req, 1))
+	require.Equal(t, "1.1.1.1", clientIP(req, 5))
+}
+
+func TestRateLimitedDataHandler(t *testing.T) {
+	anonymous, keyed := dataRateLimitAnonymous, dataRateLimitKeyed
+	dataRateLimitAnonymous, dataRateLimitKeyed = rateLimitTier{perMinute: 1, burst: 2}, rateLimitTier{perMinute: 1, burst: 3}
+	defer func() { dataRateLimitAnonymous, dataRateLimitKeyed = anonymous, keyed }()
+
+	backend := newTestBackend(t, 1)
+	backend.relay.db = &dataAPIKeysDB{keys: []*database.DataAPIKeyEntry{
+		{ID: 1, KeyHash: database.HashDataAPIKey("valid")},
+		{ID: 2, KeyHash: database.HashDataAPIKey("revoked"), RevokedAt: database.NewNullTime(time.Now())},
+	}}
+	require.NoError(t, backend.relay.reloadDataAPIKeys())
+
+	request := func(ip, apiKey string, forwardedFor ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, pathDataProposerPayloadDelivered, nil)
+		req.RemoteAddr = ip + ":1234"
+		for _, forwarded := range forwardedFor {
+			req.Header.Add("X-Forwarded-For", forwarded)
+		}
+		if apiKey != "" {
+			req.Header.Set(HeaderAPIKey, apiKey)
+		}
+		rr := httptest.NewRecorder()
+		backend.relay.getRouter().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("anonymous clients are limited per IP", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, request("10.0.0.1", "").Code)
+		require.Equal(t, http.StatusOK, request("10.0.0.1", "").Code)
+		rr := request("10.0.0.1", "")
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		require.Equal(t, "60", rr.Header().Get("Retry-After"))
+
+		require.Equal(t, http.StatusOK, request("10.0.0.2", "").Code)
+	})
+
+	t.Run("spoofed forwarded for headers don't reset the limit", func(t *testing.T) {
+		require.Equal(t, http.StatusTooManyRequests, request("10.0.0.1", "", "192.168.0.1").Code)
+
+		dataTrustedProxyHops = 1
+		defer func() { dataTrustedProxyHops = 0 }()
+		require.Equal(t, http.StatusOK, request("10.1.0.1", "", "192.168.0.5, 10.0.0.5").Code)
+		require.Equal(t, http.StatusOK, request("10.1.0.1", "", "192.168.0.6, 10.0.0.5").Code)
+		require.Equal(t, http.StatusTooManyRequests, request("10.1.0.1", "", "192.168.0.7, 10.0.0.5").Code)
+	})
+
+	t.Run("keyed clients are limited per key", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			require.Equal(t, http.StatusOK, request("10.0.0.1", "valid").Code)
+		}
+		require.Equal(t, http.StatusTooManyRequests, request("10.0.0.3", "valid").Code)
+	})
+
+	t.Run("invalid and revoked keys are rejected", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, request("10.0.0.4", "invalid").Code)
+		require.Equal(t, http.StatusUnauthorized, request("10.0.0.4", "revoked").Code)
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/database"
+)
+
+const (
+	statsDayLayout      = "2006-01-02"
+	statsDefaultNumDays = 30
+	statsDurationPerDay = 24 * time.Hour
+)
+
+// parseStatsDay parses a day argument (YYYY-MM-DD), which defaults to defaultDay
+func parseStatsDay(args url.Values, name string, defaultDay time.Time) (time.Time, error) {
+	if args.Get(name) == "" {
+		return defaultDay, nil
+	}
+	day, err := time.Parse(statsDayLayout, args.Get(name))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrInvalidArgument, name)
+	}
+	return day, nil
+}
+
+// parseStatsDayRange parses the from_day and to_day arguments. By default, the range ends today (UTC) and spans
+// statsDefaultNumDays.
+func parseStatsDayRange(args url.Values, today time.Time) (fromDay, toDay time.Time, err error) {
+	if toDay, err = parseStatsDay(args, "to_day", today); err != nil {
+		return fromDay, toDay, err
+	}
+	if fromDay, err = parseStatsDay(args, "from_day", toDay.AddDate(0, 0, -(statsDefaultNumDays-1))); err != nil {
+		return fromDay, toDay, err
+	}
+
+	if fromDay.After(toDay) {
+		return fromDay, toDay, fmt.Errorf("%w: from_day is after to_day", ErrInvalidRange)
+	} else if toDay.Sub(fromDay) >= database.StatsMaxNumDays*statsDurationPerDay {
+		return fromDay, toDay, fmt.Errorf("%w: at most %d days", ErrInvalidRange, database.StatsMaxNumDays)
+	}
+	return fromDay, toDay, nil
+}
+
+// handleDataStatsDaily returns the daily aggregates of the delivered payloads and builder submissions, newest first
+func (api *RelayAPI) handleDataStatsDaily(w http.ResponseWriter, req *http.Request) {
+	today := time.Now().UTC().Truncate(statsDurationPerDay)
+	fromDay, toDay, err := parseStatsDayRange(req.URL.Query(), today)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := api.db.GetDailyStats(fromDay, toDay)
+	if err != nil {
+		api.log.WithError(err).Error("error getting daily stats")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]common.DailyStatsJSON, len(entries))
+	for i, entry := range entries {
+		response[i] = database.DailyStatsEntryToJSON(entry)
+	}
+	api.RespondOK(w, response)
+}
+
+// handleDataStatsBuilders returns the aggregates of the builders on a day (today by default), with the most
+// delivered payloads first
+func (api *RelayAPI) handleDataStatsBuilders(w http.ResponseWriter, req *http.Request) {
+	today := time.Now().UTC().Truncate(statsDurationPerDay)
+	day, err := parseStatsDay(req.URL.Query(), "day", today)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := api.db.GetBuilderDailyStats(day)
+	if err != nil {
+		api.log.WithError(err).Error("error getting builder stats")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := make([]common.BuilderDailyStatsJSON, len(entries))
+	for i, entry := range entries {
+		response[i] = database.BuilderDailyStatsEntryToJSON(entry)
+	}
+	api.RespondOK(w, response)
+}
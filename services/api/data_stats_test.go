@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/database"
+	"github.com/stretchr/testify/require"
+)
+
+// statsDB returns fixed stats, and records the requested days
+type statsDB struct {
+	database.MockDB
+	daily    []*database.DailyStatsEntry
+	builders []*database.BuilderDailyStatsEntry
+	days     []time.Time
+}
+
+func (db *statsDB) GetDailyStats(fromDay, toDay time.Time) ([]*database.DailyStatsEntry, error) {
+	db.days = append(db.days, fromDay, toDay)
+	return db.daily, nil
+}
+
+func (db *statsDB) GetBuilderDailyStats(day time.Time) ([]*database.BuilderDailyStatsEntry, error) {
+	db.days = append(db.days, day)
+	return db.builders, nil
+}
+
+func TestParseStatsDayRange(t *testing.T) {
+	today := time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2023, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		query   string
+		fromDay time.Time
+		toDay   time.Time
+		err     string
+	}{
+		{query: "", fromDay: day(2), toDay: today},
+		{query: "to_day=2023-03-10", fromDay: day(10).AddDate(0, 0, -29), toDay: day(10)},
+		{query: "from_day=2023-03-05&to_day=2023-03-10", fromDay: day(5), toDay: day(10)},
+		{query: "from_day=2023-03-10&to_day=2023-03-10", fromDay: day(10), toDay: day(10)},
+		{query: "from_day=2022-03-31", fromDay: time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC), toDay: today},
+		{query: "from_day=2022-03-30", err: "invalid range: at most 366 days"},
+		{query: "from_day=2023-03-11&to_day=2023-03-10", err: "invalid range: from_day is after to_day"},
+		{query: "from_day=1680000000", err: "invalid argument: from_day"},
+		{query: "to_day=2023-3-1", err: "invalid argument: to_day"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			args, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			fromDay, toDay, err := parseStatsDayRange(args, today)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.fromDay, fromDay)
+			require.Equal(t, tc.toDay, toDay)
+		})
+	}
+}
+
+func TestDataApiStats(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	day := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	stats := database.DailyStatsEntry{
+		Day:                  day,
+		UpdatedAt:            day.Add(time.Hour),
+		NumPayloadsDelivered: 7000,
+		TotalValue:           "700000",
+		MedianValue:          "90",
+		AvgNumTx:             150.5,
+		NumSubmissions:       1000000,
+	}
+	db := &statsDB{
+		daily: []*database.DailyStatsEntry{&stats},
+		builders: []*database.BuilderDailyStatsEntry{
+			{DailyStatsEntry: stats, BuilderPubkey: "0xb1", NumSubmissionsTotal: 200, NumSubmissionsSimError: 5},
+			{DailyStatsEntry: stats, BuilderPubkey: "0xb2"},
+		},
+	}
+	backend.relay.db = db
+
+	t.Run("daily", func(t *testing.T) {
+		rr := backend.request(http.MethodGet, pathDataStatsDaily+"?from_day=2023-03-01&to_day=2023-03-02", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, []time.Time{day, day.AddDate(0, 0, 1)}, db.days)
+
+		response := []common.DailyStatsJSON{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		require.Equal(t, []common.DailyStatsJSON{{
+			Day:                  "2023-03-01",
+			NumPayloadsDelivered: 7000,
+			TotalValue:           "700000",
+			MedianValue:          "90",
+			AvgNumTx:             150.5,
+			NumSubmissions:       1000000,
+			UpdatedAt:            day.Add(time.Hour).Unix(),
+		}}, response)
+
+		rr = backend.request(http.MethodGet, pathDataStatsDaily+"?from_day=2023-03-02&to_day=2023-03-01", nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("builders", func(t *testing.T) {
+		db.days = nil
+		rr := backend.request(http.MethodGet, pathDataStatsBuilders+"?day=2023-03-01", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, []time.Time{day}, db.days)
+
+		response := []common.BuilderDailyStatsJSON{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		require.Len(t, response, 2)
+		require.Equal(t, "0xb1", response[0].BuilderPubkey)
+		require.Equal(t, "2023-03-01", response[0].Day)
+		require.InDelta(t, 0.025, response[0].SimErrorRate, 1e-9)
+		require.Equal(t, "0xb2", response[1].BuilderPubkey)
+		require.Zero(t, response[1].SimErrorRate)
+
+		rr = backend.request(http.MethodGet, pathDataStatsBuilders+"?day=yesterday", nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/flashbots/go-boost-utils/types"
+)
+
+var (
+	ErrInvalidGasLimitBounds = errors.New("min_gas_limit is greater than max_gas_limit")
+
+	// how often the registration policy file is checked for changes
+	registrationPolicyReloadInterval = 10 * time.Second
+)
+
+// RegistrationPolicyReason is why a validator registration was rejected by the registration policy
+type RegistrationPolicyReason string
+
+const (
+	RegistrationPolicyReasonPubkeyNotAllowed                RegistrationPolicyReason = "pubkey_not_allowed"
+	RegistrationPolicyReasonPubkeyDenied                    RegistrationPolicyReason = "pubkey_denied"
+	RegistrationPolicyReasonWithdrawalCredentialsNotAllowed RegistrationPolicyReason = "withdrawal_credentials_not_allowed"
+	RegistrationPolicyReasonWithdrawalCredentialsDenied     RegistrationPolicyReason = "withdrawal_credentials_denied"
+	RegistrationPolicyReasonGasLimitOutOfBounds             RegistrationPolicyReason = "gas_limit_out_of_bounds"
+	RegistrationPolicyReasonFeeRecipientDenied              RegistrationPolicyReason = "fee_recipient_denied"
+)
+
+// RegistrationPolicyConfig is the JSON config file of the registration policy. Empty lists and zero gas limits don't
+// restrict registrations.
+type RegistrationPolicyConfig struct {
+	AllowedPubkeys               []string `json:"allowed_pubkeys"` // if set, only these validators can register
+	DeniedPubkeys                []string `json:"denied_pubkeys"`
+	AllowedWithdrawalCredentials []string `json:"allowed_withdrawal_credentials"` // if set, only validators with these withdrawal credentials can register
+	DeniedWithdrawalCredentials  []string `json:"denied_withdrawal_credentials"`
+	MinGasLimit                  uint64   `json:"min_gas_limit"`
+	MaxGasLimit                  uint64   `json:"max_gas_limit"`
+	DeniedFeeRecipients          []string `json:"denied_fee_recipients"` // i.e. sanctioned addresses
+}
+
+// RegistrationPolicy restricts which validator registrations are accepted, in addition to the builder-specs checks
+type RegistrationPolicy struct {
+	allowedPubkeys               map[string]bool
+	deniedPubkeys                map[string]bool
+	allowedWithdrawalCredentials map[string]bool
+	deniedWithdrawalCredentials  map[string]bool
+	minGasLimit                  uint64
+	maxGasLimit                  uint64
+	deniedFeeRecipients          map[string]bool
+
+	modTime time.Time // of the file the policy was loaded from
+}
+
+// hexSet returns the lowercase set of the hex strings
+func hexSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[strings.ToLower(value)] = true
+	}
+	return set
+}
+
+func NewRegistrationPolicy(config *RegistrationPolicyConfig) (*RegistrationPolicy, error) {
+	if config.MaxGasLimit != 0 && config.MinGasLimit > config.MaxGasLimit {
+		return nil, ErrInvalidGasLimitBounds
+	}
+
+	return &RegistrationPolicy{
+		allowedPubkeys:               hexSet(config.AllowedPubkeys),
+		deniedPubkeys:                hexSet(config.DeniedPubkeys),
+		allowedWithdrawalCredentials: hexSet(config.AllowedWithdrawalCredentials),
+		deniedWithdrawalCredentials:  hexSet(config.DeniedWithdrawalCredentials),
+		minGasLimit:                  config.MinGasLimit,
+		maxGasLimit:                  config.MaxGasLimit,
+		deniedFeeRecipients:          hexSet(config.DeniedFeeRecipients),
+	}, nil
+}
+
+// LoadRegistrationPolicy reads the registration policy from a JSON config file, and records the file's modification
+// time before reading it, so later changes are never missed
+func LoadRegistrationPolicy(path string) (*RegistrationPolicy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	config := new(RegistrationPolicyConfig)
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid registration policy %s: %w", path, err)
+	}
+	policy, err := NewRegistrationPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	policy.modTime = info.ModTime()
+	return policy, nil
+}
+
+// usesWithdrawalCredentials is true if the policy needs the withdrawal credentials of the validators
+func (p *RegistrationPolicy) usesWithdrawalCredentials() bool {
+	return len(p.allowedWithdrawalCredentials) > 0 || len(p.deniedWithdrawalCredentials) > 0
+}
+
+// check returns why the registration is rejected, or an empty reason if it's accepted. getWithdrawalCredentials is
+// only called if the policy has withdrawal credentials rules.
+func (p *RegistrationPolicy) check(pubkey types.PubkeyHex, feeRecipient string, gasLimit uint64, getWithdrawalCredentials func() (string, error)) (RegistrationPolicyReason, error) {
+	pk := strings.ToLower(pubkey.String())
+	if len(p.allowedPubkeys) > 0 && !p.allowedPubkeys[pk] {
+		return RegistrationPolicyReasonPubkeyNotAllowed, nil
+	} else if p.deniedPubkeys[pk] {
+		return RegistrationPolicyReasonPubkeyDenied, nil
+	}
+
+	if gasLimit < p.minGasLimit || (p.maxGasLimit != 0 && gasLimit > p.maxGasLimit) {
+		return RegistrationPolicyReasonGasLimitOutOfBounds, nil
+	}
+
+	if p.deniedFeeRecipients[strings.ToLower(feeRecipient)] {
+		return RegistrationPolicyReasonFeeRecipientDenied, nil
+	}
+
+	if p.usesWithdrawalCredentials() {
+		withdrawalCredentials, err := getWithdrawalCredentials()
+		if err != nil {
+			return "", err
+		}
+		withdrawalCredentials = strings.ToLower(withdrawalCredentials)
+		if len(p.allowedWithdrawalCredentials) > 0 && !p.allowedWithdrawalCredentials[withdrawalCredentials] {
+			return RegistrationPolicyReasonWithdrawalCredentialsNotAllowed, nil
+		} else if p.deniedWithdrawalCredentials[withdrawalCredentials] {
+			return RegistrationPolicyReasonWithdrawalCredentialsDenied, nil
+		}
+	}
+
+	return "", nil
+}
+
+// startRegistrationPolicyReloads reloads the registration policy whenever its file changes. An invalid policy is
+// logged, and the previous one stays in use.
+func (api *RelayAPI) startRegistrationPolicyReloads() {
+	path := api.opts.RegistrationPolicyFile
+	log := api.log.WithField("registrationPolicyFile", path)
+
+	var lastModTime time.Time
+	if policy := api.registrationPolicy.Load(); policy != nil {
+		lastModTime = policy.modTime
+	}
+
+	for {
+		time.Sleep(registrationPolicyReloadInterval)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			log.WithError(err).Error("could not stat registration policy file")
+			continue
+		} else if info.ModTime().Equal(lastModTime) {
+			continue
+		}
+
+		policy, err := LoadRegistrationPolicy(path)
+		if err != nil {
+			log.WithError(err).Error("could not reload registration policy, keeping the previous one")
+			lastModTime = info.ModTime()
+			continue
+		}
+		api.registrationPolicy.Store(policy)
+		lastModTime = policy.modTime
+		log.WithField("modTime", lastModTime).Info("reloaded registration policy")
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationPolicyCheck(t *testing.T) {
+	pubkey := types.PubkeyHex("0x8a1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca249")
+	feeRecipient := "0xfeefeefeefeefeefeefeefeefeefeefeefeefee1"
+	withdrawalCredentials := "0x010000000000000000000000abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+	getWithdrawalCredentials := func() (string, error) { return withdrawalCredentials, nil }
+
+	testCases := []struct {
+		name   string
+		config RegistrationPolicyConfig
+		reason RegistrationPolicyReason
+	}{
+		{
+			name:   "empty policy",
+			config: RegistrationPolicyConfig{},
+		},
+		{
+			name:   "allowed pubkey, case-insensitive",
+			config: RegistrationPolicyConfig{AllowedPubkeys: []string{"0x8A1D7B8DD64E0AAFE7EA7B6C95065C9364CF99D38470C12EE807D55F7DE1529AD29CE2C422E0B65E3D5A05C02CACA249"}},
+		},
+		{
+			name:   "pubkey not allowed",
+			config: RegistrationPolicyConfig{AllowedPubkeys: []string{"0xb1"}},
+			reason: RegistrationPolicyReasonPubkeyNotAllowed,
+		},
+		{
+			name:   "pubkey denied",
+			config: RegistrationPolicyConfig{DeniedPubkeys: []string{pubkey.String()}},
+			reason: RegistrationPolicyReasonPubkeyDenied,
+		},
+		{
+			name:   "gas limit below min",
+			config: RegistrationPolicyConfig{MinGasLimit: 30_000_001},
+			reason: RegistrationPolicyReasonGasLimitOutOfBounds,
+		},
+		{
+			name:   "gas limit above max",
+			config: RegistrationPolicyConfig{MaxGasLimit: 29_999_999},
+			reason: RegistrationPolicyReasonGasLimitOutOfBounds,
+		},
+		{
+			name:   "gas limit within bounds",
+			config: RegistrationPolicyConfig{MinGasLimit: 30_000_000, MaxGasLimit: 30_000_000},
+		},
+		{
+			name:   "fee recipient denied",
+			config: RegistrationPolicyConfig{DeniedFeeRecipients: []string{feeRecipient}},
+			reason: RegistrationPolicyReasonFeeRecipientDenied,
+		},
+		{
+			name:   "withdrawal credentials not allowed",
+			config: RegistrationPolicyConfig{AllowedWithdrawalCredentials: []string{"0x00"}},
+			reason: RegistrationPolicyReasonWithdrawalCredentialsNotAllowed,
+		},
+		{
+			name:   "withdrawal credentials denied",
+			config: RegistrationPolicyConfig{DeniedWithdrawalCredentials: []string{withdrawalCredentials}},
+			reason: RegistrationPolicyReasonWithdrawalCredentialsDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := NewRegistrationPolicy(&tc.config)
+			require.NoError(t, err)
+			reason, err := policy.check(pubkey, feeRecipient, 30_000_000, getWithdrawalCredentials)
+			require.NoError(t, err)
+			require.Equal(t, tc.reason, reason)
+		})
+	}
+
+	t.Run("withdrawal credentials are only looked up if needed", func(t *testing.T) {
+		policy, err := NewRegistrationPolicy(&RegistrationPolicyConfig{DeniedPubkeys: []string{"0xb1"}})
+		require.NoError(t, err)
+		_, err = policy.check(pubkey, feeRecipient, 30_000_000, func() (string, error) {
+			t.Fatal("unexpected withdrawal credentials lookup")
+			return "", nil
+		})
+		require.NoError(t, err)
+
+		errLookup := errors.New("lookup failed")
+		policy, err = NewRegistrationPolicy(&RegistrationPolicyConfig{DeniedWithdrawalCredentials: []string{"0x00"}})
+		require.NoError(t, err)
+		_, err = policy.check(pubkey, feeRecipient, 30_000_000, func() (string, error) { return "", errLookup })
+		require.ErrorIs(t, err, errLookup)
+	})
+}
+
+func TestLoadRegistrationPolicy(t *testing.T) {
+	writePolicy := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("valid policy", func(t *testing.T) {
+		path := writePolicy(t, `{"denied_fee_recipients": ["0xFEE1"], "min_gas_limit": 30000000}`)
+		modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+		policy, err := LoadRegistrationPolicy(path)
+		require.NoError(t, err)
+		require.True(t, policy.deniedFeeRecipients["0xfee1"])
+		require.Equal(t, uint64(30_000_000), policy.minGasLimit)
+		require.True(t, modTime.Equal(policy.modTime))
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := LoadRegistrationPolicy(writePolicy(t, `{"denied_fee_recipient": ["0xfee1"]}`))
+		require.Error(t, err)
+	})
+
+	t.Run("invalid gas limit bounds", func(t *testing.T) {
+		_, err := LoadRegistrationPolicy(writePolicy(t, `{"min_gas_limit": 2, "max_gas_limit": 1}`))
+		require.ErrorIs(t, err, ErrInvalidGasLimitBounds)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadRegistrationPolicy(filepath.Join(t.TempDir(), "missing.json"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -9,16 +9,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	_ "net/http/pprof"
+	"net/url"
 	"os"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/buger/jsonparser"
 	"github.com/flashbots/go-boost-utils/bls"
 	"github.com/flashbots/go-boost-utils/types"
@@ -28,9 +33,13 @@ import (
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
 	"github.com/flashbots/mev-boost-relay/datastore"
+	"github.com/flashbots/mev-boost-relay/metrics"
+	"github.com/flashbots/mev-boost-relay/tracing"
 	"github.com/go-redis/redis/v9"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	uberatomic "go.uber.org/atomic"
 )
 
@@ -41,6 +50,11 @@ var (
 	ErrRelayPubkeyMismatch        = errors.New("relay pubkey does not match existing one")
 	ErrServerAlreadyStarted       = errors.New("server was already started")
 	ErrBuilderAPIWithoutSecretKey = errors.New("cannot start builder API without secret key")
+	ErrNoWithdrawalsResponse      = errors.New("no withdrawals response from beacon node")
+	ErrInvalidLimit               = errors.New("invalid limit argument")
+	ErrLimitTooHigh               = errors.New("limit argument is too high")
+	ErrInvalidArgument            = errors.New("invalid argument")
+	ErrInvalidRange               = errors.New("invalid range")
 )
 
 var (
@@ -53,18 +67,27 @@ var (
 	// Block builder API
 	pathBuilderGetValidators = "/relay/v1/builder/validators"
 	pathSubmitNewBlock       = "/relay/v1/builder/blocks"
+	pathBuilderTopBidStream  = "/relay/v1/builder/top_bid_stream"
 
 	// Data API
 	pathDataProposerPayloadDelivered = "/relay/v1/data/bidtraces/proposer_payload_delivered"
 	pathDataBuilderBidsReceived      = "/relay/v1/data/bidtraces/builder_blocks_received"
 	pathDataValidatorRegistration    = "/relay/v1/data/validator_registration"
+	pathDataValidatorRegHistory      = "/relay/v1/data/validator_registration_history"
+	pathDataValidatorRegistrations   = "/relay/v1/data/validator_registrations"
+	pathDataProposerHeaderServed     = "/relay/v1/data/bidtraces/proposer_header_served"
+	pathDataStatsDaily               = "/relay/v1/data/stats/daily"
+	pathDataStatsBuilders            = "/relay/v1/data/stats/builders"
 
 	// Internal API
-	pathInternalBuilderStatus = "/internal/v1/builder/{pubkey:0x[a-fA-F0-9]+}"
+	pathInternalBuilderStatus      = "/internal/v1/builder/{pubkey:0x[a-fA-F0-9]+}"
+	pathInternalBuilderCollateral  = "/internal/v1/builder/collateral/{pubkey:0x[a-fA-F0-9]+}"
+	pathInternalBuilderStreamToken = "/internal/v1/builder/stream_token/{pubkey:0x[a-fA-F0-9]+}"
 
 	// number of goroutines to save active validator
 	numActiveValidatorProcessors = cli.GetEnvInt("NUM_ACTIVE_VALIDATOR_PROCESSORS", 10)
 	numValidatorRegProcessors    = cli.GetEnvInt("NUM_VALIDATOR_REG_PROCESSORS", 10)
+	numValidatorRegVerifiers     = cli.GetEnvInt("NUM_VALIDATOR_REG_VERIFIERS", runtime.NumCPU())
 	timeoutGetPayloadRetryMs     = cli.GetEnvInt("GETPAYLOAD_RETRY_TIMEOUT_MS", 100)
 )
 
@@ -72,8 +95,11 @@ var (
 type RelayAPIOpts struct {
 	Log *logrus.Entry
 
-	ListenAddr  string
-	BlockSimURL string
+	ListenAddr           string
+	BlockSimURLs         []string
+	HighPrioBlockSimURLs []string // if empty, high-prio builders use BlockSimURLs
+
+	RegistrationPolicyFile string // if set, validator registrations are filtered by this policy, reloaded on changes
 
 	BeaconClient beaconclient.IMultiBeaconClient
 	Datastore    *datastore.Datastore
@@ -98,6 +124,11 @@ type randaoHelper struct {
 	prevRandao string
 }
 
+type withdrawalsHelper struct {
+	slot uint64
+	root phase0.Root
+}
+
 // RelayAPI represents a single Relay instance
 type RelayAPI struct {
 	opts RelayAPIOpts
@@ -124,6 +155,11 @@ type RelayAPI struct {
 	isUpdatingProposerDuties uberatomic.Bool
 
 	blockSimRateLimiter *BlockSimulationRateLimiter
+	topBidStream        *topBidStream
+	registrationPolicy  atomic.Pointer[RegistrationPolicy]
+	dataCache           *dataCache // nil if the data API responses aren't cached
+	dataRateLimiter     *rateLimiter
+	dataAPIKeys         atomic.Pointer[map[string]int64] // key hash -> id of the unrevoked data API keys
 
 	activeValidatorC chan types.PubkeyHex
 	validatorRegC    chan types.SignedValidatorRegistration
@@ -132,13 +168,18 @@ type RelayAPI struct {
 	getPayloadCallsInFlight sync.WaitGroup
 
 	// Feature flags
-	ffForceGetHeader204      bool
-	ffDisableBlockPublishing bool
-	ffDisableLowPrioBuilders bool
+	ffForceGetHeader204        bool
+	ffDisableBlockPublishing   bool
+	ffDisableLowPrioBuilders   bool
+	ffEnableOptimisticRelaying bool
 
 	expectedPrevRandao         randaoHelper
 	expectedPrevRandaoLock     sync.RWMutex
 	expectedPrevRandaoUpdating uint64
+
+	expectedWithdrawals         withdrawalsHelper
+	expectedWithdrawalsLock     sync.RWMutex
+	expectedWithdrawalsUpdating uint64
 }
 
 // NewRelayAPI creates a new service. if builders is nil, allow any builder
@@ -193,12 +234,28 @@ func NewRelayAPI(opts RelayAPIOpts) (api *RelayAPI, err error) {
 		redis:                  opts.Redis,
 		db:                     opts.DB,
 		proposerDutiesResponse: []types.BuilderGetValidatorsResponseEntry{},
-		blockSimRateLimiter:    NewBlockSimulationRateLimiter(opts.BlockSimURL),
+		blockSimRateLimiter:    NewBlockSimulationRateLimiter(opts.BlockSimURLs, opts.HighPrioBlockSimURLs),
+		topBidStream:           newTopBidStream(),
+		dataRateLimiter:        newRateLimiter(),
 
 		activeValidatorC: make(chan types.PubkeyHex, 450_000),
 		validatorRegC:    make(chan types.SignedValidatorRegistration, 450_000),
 	}
 
+	if opts.RegistrationPolicyFile != "" {
+		policy, err := LoadRegistrationPolicy(opts.RegistrationPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		api.registrationPolicy.Store(policy)
+	}
+
+	if os.Getenv("DISABLE_DATA_API_CACHE") == "1" {
+		api.log.Warn("env: DISABLE_DATA_API_CACHE - data API responses are not cached")
+	} else if opts.DataAPI {
+		api.dataCache = newDataCache(opts.Redis, dataCacheSize, dataCacheMaxBytes)
+	}
+
 	if os.Getenv("FORCE_GET_HEADER_204") == "1" {
 		api.log.Warn("env: FORCE_GET_HEADER_204 - forcing getHeader to always return 204")
 		api.ffForceGetHeader204 = true
@@ -214,6 +271,11 @@ func NewRelayAPI(opts RelayAPIOpts) (api *RelayAPI, err error) {
 		api.ffDisableLowPrioBuilders = true
 	}
 
+	if os.Getenv("ENABLE_OPTIMISTIC_RELAYING") == "1" {
+		api.log.Warn("env: ENABLE_OPTIMISTIC_RELAYING - accepting bids of high-prio builders with collateral before simulation")
+		api.ffEnableOptimisticRelaying = true
+	}
+
 	return api, nil
 }
 
@@ -236,14 +298,20 @@ func (api *RelayAPI) getRouter() http.Handler {
 		api.log.Info("block builder API enabled")
 		r.HandleFunc(pathBuilderGetValidators, api.handleBuilderGetValidators).Methods(http.MethodGet)
 		r.HandleFunc(pathSubmitNewBlock, api.handleSubmitNewBlock).Methods(http.MethodPost)
+		r.HandleFunc(pathBuilderTopBidStream, api.handleBuilderTopBidStream).Methods(http.MethodGet)
 	}
 
 	// Data API
 	if api.opts.DataAPI {
 		api.log.Info("data API enabled")
-		r.HandleFunc(pathDataProposerPayloadDelivered, api.handleDataProposerPayloadDelivered).Methods(http.MethodGet)
-		r.HandleFunc(pathDataBuilderBidsReceived, api.handleDataBuilderBidsReceived).Methods(http.MethodGet)
-		r.HandleFunc(pathDataValidatorRegistration, api.handleDataValidatorRegistration).Methods(http.MethodGet)
+		r.HandleFunc(pathDataProposerPayloadDelivered, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataProposerPayloadDelivered, "slot", "slot_to", "cursor"))).Methods(http.MethodGet)
+		r.HandleFunc(pathDataBuilderBidsReceived, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataBuilderBidsReceived, "slot", "slot_to"))).Methods(http.MethodGet)
+		r.HandleFunc(pathDataValidatorRegistration, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataValidatorRegistration))).Methods(http.MethodGet)
+		r.HandleFunc(pathDataValidatorRegHistory, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataValidatorRegistrationHistory))).Methods(http.MethodGet)
+		r.HandleFunc(pathDataValidatorRegistrations, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataValidatorRegistrations))).Methods(http.MethodGet)
+		r.HandleFunc(pathDataProposerHeaderServed, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataProposerHeaderServed, "slot"))).Methods(http.MethodGet)
+		r.HandleFunc(pathDataStatsDaily, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataStatsDaily))).Methods(http.MethodGet)
+		r.HandleFunc(pathDataStatsBuilders, api.rateLimitedDataHandler(api.cachedDataHandler(api.handleDataStatsBuilders))).Methods(http.MethodGet)
 	}
 
 	// Pprof
@@ -256,12 +324,23 @@ func (api *RelayAPI) getRouter() http.Handler {
 	if api.opts.InternalAPI {
 		api.log.Info("internal API enabled")
 		r.HandleFunc(pathInternalBuilderStatus, api.handleInternalBuilderStatus).Methods(http.MethodGet, http.MethodPost, http.MethodPut)
+		r.HandleFunc(pathInternalBuilderCollateral, api.handleInternalBuilderCollateral).Methods(http.MethodPost, http.MethodPut)
+		r.HandleFunc(pathInternalBuilderStreamToken, api.handleInternalBuilderStreamToken).Methods(http.MethodPost, http.MethodDelete)
 	}
 
 	// r.Use(mux.CORSMethodMiddleware(r))
+	r.Use(metrics.HTTPMiddleware)
 	loggedRouter := httplogger.LoggingMiddlewareLogrus(api.log, r)
 	withGz := gziphandler.GzipHandler(loggedRouter)
-	return withGz
+
+	// the top bid stream is long-lived, so it bypasses compression and request logging, which buffer the response
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if api.opts.BlockBuilderAPI && req.URL.Path == pathBuilderTopBidStream {
+			r.ServeHTTP(w, req)
+			return
+		}
+		withGz.ServeHTTP(w, req)
+	})
 }
 
 // StartServer starts the HTTP server for this instance
@@ -286,6 +365,12 @@ func (api *RelayAPI) StartServer() (err error) {
 	if api.opts.BlockBuilderAPI {
 		// Get current proposer duties blocking before starting, to have them ready
 		api.updateProposerDuties(bestSyncStatus.HeadSlot)
+
+		// Periodically check which block simulation nodes are reachable and synced
+		go api.blockSimRateLimiter.startHealthChecks(api.log)
+
+		// Forward the top bid updates of all relay instances to the connected builders
+		go api.startTopBidStream()
 	}
 
 	// start things specific for the proposer API
@@ -293,6 +378,11 @@ func (api *RelayAPI) StartServer() (err error) {
 		// Update list of known validators, and start refresh loop
 		go api.startKnownValidatorUpdates()
 
+		// Reload the registration policy on changes
+		if api.opts.RegistrationPolicyFile != "" {
+			go api.startRegistrationPolicyReloads()
+		}
+
 		// Start the worker pool to process active validators
 		api.log.Infof("starting %d active validator processors", numActiveValidatorProcessors)
 		for i := 0; i < numActiveValidatorProcessors; i++ {
@@ -306,6 +396,15 @@ func (api *RelayAPI) StartServer() (err error) {
 		}
 	}
 
+	// start things for the data API
+	if api.opts.DataAPI {
+		// Load the API keys before serving, and reload them to pick up new and revoked keys
+		if err := api.reloadDataAPIKeys(); err != nil {
+			api.log.WithError(err).Error("could not load data API keys")
+		}
+		go api.startDataAPIKeyReloads()
+	}
+
 	// Process current slot
 	api.processNewSlot(bestSyncStatus.HeadSlot)
 
@@ -401,8 +500,16 @@ func (api *RelayAPI) processNewSlot(headSlot uint64) {
 		// query the expected prev_randao field
 		go api.updatedExpectedRandao(headSlot)
 
+		// query the expected withdrawals
+		go api.updatedExpectedWithdrawals(headSlot)
+
 		// update proposer duties in the background
 		go api.updateProposerDuties(headSlot)
+
+		// simulations still queued for this slot are too late
+		if numDropped := api.blockSimRateLimiter.dropPastSlots(headSlot); numDropped > 0 {
+			api.log.WithField("slotHead", headSlot).Infof("dropped %d queued block simulations for past slots", numDropped)
+		}
 	}
 
 	// log
@@ -486,6 +593,26 @@ func (api *RelayAPI) RespondOK(w http.ResponseWriter, response any) {
 	}
 }
 
+// RespondOKSSZ writes an ssz encoded response, with the fork version in the Eth-Consensus-Version header
+func (api *RelayAPI) RespondOKSSZ(w http.ResponseWriter, version types.VersionString, response []byte) {
+	w.Header().Set("Content-Type", HeaderContentTypeSSZ)
+	w.Header().Set(HeaderEthConsensusVersion, string(version))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(response); err != nil {
+		api.log.WithError(err).Error("Couldn't write OK ssz response")
+	}
+}
+
+// acceptsSSZ returns whether the client asked for an ssz encoded response
+func acceptsSSZ(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), HeaderContentTypeSSZ)
+}
+
+// isSSZRequest returns whether the request body is ssz encoded
+func isSSZRequest(req *http.Request) bool {
+	return req.Header.Get("Content-Type") == HeaderContentTypeSSZ
+}
+
 func (api *RelayAPI) handleStatus(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -510,11 +637,22 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 	start := time.Now()
 	registrationTimeUpperBound := start.Add(10 * time.Second)
 
+	// With ?results=1, all registrations are processed and the result of each one is returned. Otherwise the
+	// processing stops at the first invalid registration, with a 400 response.
+	withResults := req.URL.Query().Get("results") == "1"
+
 	numRegTotal := 0
 	numRegProcessed := 0
 	numRegActive := 0
 	numRegNew := 0
 	processingStoppedByError := false
+	policyRejections := []RegisterValidatorRejection{}
+	results := []RegisterValidatorResult{}
+	newRegistrations := []*types.SignedValidatorRegistration{}
+	newRegistrationIndexes := []int{} // index of the new registrations in results
+	numRegRejected := 0
+	numRegFailed := 0
+	policy := api.registrationPolicy.Load()
 
 	respondError := func(code int, msg string) {
 		processingStoppedByError = true
@@ -543,31 +681,26 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 
 		timestamp, err := jsonparser.GetUnsafeString(value, "message", "timestamp")
 		if err != nil {
-			return pkHex, timestampInt, fmt.Errorf("registration message error (timestamp): %w", err)
+			return types.PubkeyHex(pubkey), timestampInt, fmt.Errorf("registration message error (timestamp): %w", err)
 		}
 
 		timestampInt, err = strconv.ParseInt(timestamp, 10, 64)
 		if err != nil {
-			return pkHex, timestampInt, fmt.Errorf("invalid timestamp: %w", err)
+			return types.PubkeyHex(pubkey), timestampInt, fmt.Errorf("invalid timestamp: %w", err)
 		}
 
 		return types.PubkeyHex(pubkey), timestampInt, nil
 	}
 
-	// Iterate over the registrations
-	_, err = jsonparser.ArrayEach(body, func(value []byte, dataType jsonparser.ValueType, offset int, _err error) {
-		numRegTotal += 1
-		if processingStoppedByError {
-			return
-		}
-		numRegProcessed += 1
-
+	// processRegistration checks a single registration. New registrations are returned without a status, for their
+	// signatures to be verified in parallel.
+	processRegistration := func(value []byte) (RegisterValidatorResult, *types.SignedValidatorRegistration) {
 		// Extract immediately necessary registration fields
 		pkHex, timestampInt, err := parseRegistration(value)
 		if err != nil {
-			respondError(http.StatusBadRequest, err.Error())
-			return
+			return RegisterValidatorResult{Pubkey: pkHex.String(), Status: RegisterValidatorStatusInvalid, Error: err.Error()}, nil
 		}
+		result := RegisterValidatorResult{Pubkey: pkHex.String()}
 
 		// Add validator pubkey to logs
 		regLog := api.log.WithField("pubkey", pkHex.String())
@@ -575,15 +708,17 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 		// Ensure registration is not too far in the future
 		registrationTime := time.Unix(timestampInt, 0)
 		if registrationTime.After(registrationTimeUpperBound) {
-			respondError(http.StatusBadRequest, "timestamp too far in the future")
-			return
+			result.Status = RegisterValidatorStatusTimestampInFuture
+			result.Error = "timestamp too far in the future"
+			return result, nil
 		}
 
 		// Check if a real validator
 		isKnownValidator := api.datastore.IsKnownValidator(pkHex)
 		if !isKnownValidator {
-			respondError(http.StatusBadRequest, fmt.Sprintf("not a known validator: %s", pkHex.String()))
-			return
+			result.Status = RegisterValidatorStatusUnknownValidator
+			result.Error = fmt.Sprintf("not a known validator: %s", pkHex.String())
+			return result, nil
 		}
 
 		// Track active validators here
@@ -594,13 +729,29 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 			regLog.Error("active validator channel full")
 		}
 
+		// Check the registration policy
+		if policy != nil {
+			reason, err := api.checkRegistrationPolicy(policy, pkHex, value)
+			if err != nil {
+				regLog.WithError(err).Error("error checking registration policy")
+				result.Status = RegisterValidatorStatusInvalid
+				result.Error = fmt.Sprintf("error checking registration policy: %s", err.Error())
+				return result, nil
+			} else if reason != "" {
+				result.Status = RegisterValidatorStatusRejectedByPolicy
+				result.Reason = reason
+				return result, nil
+			}
+		}
+
 		// Check for a previous registration timestamp
 		prevTimestamp, err := api.redis.GetValidatorRegistrationTimestamp(pkHex)
 		if err != nil {
 			regLog.WithError(err).Error("error getting last registration timestamp")
 		} else if prevTimestamp >= uint64(timestampInt) {
 			// abort if the current registration timestamp is older or equal to the last known one
-			return
+			result.Status = RegisterValidatorStatusUnchanged
+			return result, nil
 		}
 
 		// Now we have a new registration to process
@@ -611,27 +762,32 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 		err = json.Unmarshal(value, signedValidatorRegistration)
 		if err != nil {
 			regLog.WithError(err).Error("error unmarshalling signed validator registration")
-			respondError(http.StatusBadRequest, fmt.Sprintf("error unmarshalling signed validator registration: %s", err.Error()))
-			return
+			result.Status = RegisterValidatorStatusInvalid
+			result.Error = fmt.Sprintf("error unmarshalling signed validator registration: %s", err.Error())
+			return result, nil
 		}
 
-		// Verify the signature
-		ok, err := types.VerifySignature(signedValidatorRegistration.Message, api.opts.EthNetDetails.DomainBuilder, signedValidatorRegistration.Message.Pubkey[:], signedValidatorRegistration.Signature[:])
-		if err != nil {
-			regLog.WithError(err).Error("error verifying registerValidator signature")
-			respondError(http.StatusBadRequest, fmt.Sprintf("error verifying registerValidator signature: %s", err.Error()))
-			return
-		} else if !ok {
-			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("failed to verify validator signature for %s", signedValidatorRegistration.Message.Pubkey.String()))
+		return result, signedValidatorRegistration
+	}
+
+	// Iterate over the registrations
+	_, err = jsonparser.ArrayEach(body, func(value []byte, dataType jsonparser.ValueType, offset int, _err error) {
+		numRegTotal += 1
+		if processingStoppedByError {
 			return
 		}
+		numRegProcessed += 1
 
-		// Save to database
-		select {
-		case api.validatorRegC <- *signedValidatorRegistration:
-		default:
-			regLog.Error("validator registration channel full")
+		result, newRegistration := processRegistration(value)
+		if newRegistration != nil {
+			newRegistrations = append(newRegistrations, newRegistration)
+			newRegistrationIndexes = append(newRegistrationIndexes, len(results))
+		} else if !withResults && result.Error != "" {
+			// Stop at the first invalid registration, but only respond after the signatures of the registrations
+			// before it are verified
+			processingStoppedByError = true
 		}
+		results = append(results, result)
 	})
 
 	if err != nil {
@@ -639,18 +795,124 @@ func (api *RelayAPI) handleRegisterValidator(w http.ResponseWriter, req *http.Re
 		return
 	}
 
+	// Verify the signatures of the new registrations
+	for i, err := range verifyRegistrationSignatures(newRegistrations, api.opts.EthNetDetails.DomainBuilder) {
+		result := &results[newRegistrationIndexes[i]]
+		if err != nil {
+			api.log.WithError(err).WithField("pubkey", result.Pubkey).Warn("invalid registerValidator signature")
+			result.Status = RegisterValidatorStatusBadSignature
+			result.Error = err.Error()
+		} else {
+			result.Status = RegisterValidatorStatusAccepted
+		}
+	}
+
+	// Queue the valid registrations to be saved, up to the first invalid one without ?results=1
+	newRegistrationsByIndex := make(map[int]*types.SignedValidatorRegistration, len(newRegistrations))
+	for i, registration := range newRegistrations {
+		newRegistrationsByIndex[newRegistrationIndexes[i]] = registration
+	}
+	for i, result := range results {
+		if result.Status == RegisterValidatorStatusRejectedByPolicy {
+			numRegRejected += 1
+			policyRejections = append(policyRejections, RegisterValidatorRejection{Pubkey: result.Pubkey, Reason: result.Reason})
+		} else if result.Error != "" {
+			numRegFailed += 1
+			if !withResults {
+				respondError(http.StatusBadRequest, result.Error)
+				break
+			}
+		} else if result.Status == RegisterValidatorStatusAccepted {
+			select {
+			case api.validatorRegC <- *newRegistrationsByIndex[i]:
+			default:
+				api.log.WithField("pubkey", result.Pubkey).Error("validator registration channel full")
+			}
+		}
+	}
+
+	metrics.ValidatorRegistrationBatchSize.Observe(float64(numRegTotal))
+
 	log = log.WithFields(logrus.Fields{
 		"timeNeededSec":             time.Since(start).Seconds(),
+		"withResults":               withResults,
 		"numRegistrations":          numRegTotal,
 		"numRegistrationsActive":    numRegActive,
 		"numRegistrationsProcessed": numRegProcessed,
 		"numRegistrationsNew":       numRegNew,
+		"numRegistrationsRejected":  numRegRejected,
+		"numRegistrationsFailed":    numRegFailed,
 		"processingStoppedByError":  processingStoppedByError,
 	})
 	log.Info("validator registrations call processed")
+
+	if processingStoppedByError {
+		return
+	} else if withResults {
+		api.RespondOK(w, RegisterValidatorResultsResponse{Results: results})
+		return
+	} else if len(policyRejections) > 0 {
+		api.RespondOK(w, RegisterValidatorResponse{Rejected: policyRejections})
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// checkRegistrationPolicy returns why the registration is rejected by the policy, or an empty reason if it's accepted
+func (api *RelayAPI) checkRegistrationPolicy(policy *RegistrationPolicy, pkHex types.PubkeyHex, registration []byte) (RegistrationPolicyReason, error) {
+	feeRecipient, err := jsonparser.GetUnsafeString(registration, "message", "fee_recipient")
+	if err != nil {
+		return "", fmt.Errorf("registration message error (fee_recipient): %w", err)
+	}
+
+	gasLimitStr, err := jsonparser.GetUnsafeString(registration, "message", "gas_limit")
+	if err != nil {
+		return "", fmt.Errorf("registration message error (gas_limit): %w", err)
+	}
+	gasLimit, err := strconv.ParseUint(gasLimitStr, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid gas_limit: %w", err)
+	}
+
+	return policy.check(pkHex, feeRecipient, gasLimit, func() (string, error) {
+		return api.redis.GetValidatorWithdrawalCredentials(pkHex)
+	})
+}
+
+// verifyRegistrationSignatures verifies the signatures of the registrations with a pool of numValidatorRegVerifiers
+// goroutines, and returns the error of each registration (nil if the signature is valid)
+func verifyRegistrationSignatures(registrations []*types.SignedValidatorRegistration, domain types.Domain) []error {
+	errs := make([]error, len(registrations))
+	indexes := make(chan int)
+	numWorkers := numValidatorRegVerifiers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers && i < len(registrations); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				registration := registrations[i]
+				ok, err := types.VerifySignature(registration.Message, domain, registration.Message.Pubkey[:], registration.Signature[:])
+				if err != nil {
+					errs[i] = fmt.Errorf("error verifying registerValidator signature: %w", err)
+				} else if !ok {
+					errs[i] = fmt.Errorf("failed to verify validator signature for %s", registration.Message.Pubkey.String())
+				}
+			}
+		}()
+	}
+	for i := range registrations {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
 func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	slotStr := vars["slot"]
@@ -702,22 +964,61 @@ func (api *RelayAPI) handleGetHeader(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if bid == nil || bid.Data == nil || bid.Data.Message == nil {
+	if bid == nil || (bid.Bellatrix == nil && bid.Capella == nil) {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	// Error on bid without value
-	if bid.Data.Message.Value.Cmp(&ZeroU256) == 0 {
+	value := bid.Value()
+	if value.Cmp(&ZeroU256) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	log.WithFields(logrus.Fields{
-		"value":     bid.Data.Message.Value.String(),
-		"blockHash": bid.Data.Message.Header.BlockHash.String(),
-	}).Info("bid delivered")
+	log = log.WithFields(logrus.Fields{
+		"value":     value.String(),
+		"blockHash": bid.BlockHash().String(),
+		"version":   bid.Version(),
+	})
+
+	servedHeader := database.GetHeaderServedEntry{
+		ServedAt:        time.Now().UTC(),
+		Slot:            slot,
+		Epoch:           slot / uint64(common.SlotsPerEpoch),
+		ParentHash:      parentHashHex,
+		ProposerPubkey:  proposerPubkeyHex,
+		BlockHash:       bid.BlockHash().String(),
+		Value:           value.String(),
+		UserAgent:       ua,
+		MevBoostVersion: common.GetMevBoostVersionFromUserAgent(ua),
+		IP:              common.GetIPXForwardedFor(req),
+	}
+
+	if acceptsSSZ(req) {
+		sszBid, err := bid.MarshalSSZ()
+		if err != nil {
+			log.WithError(err).Error("could not encode bid as ssz")
+			api.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.WithField("ssz", true).Info("bid delivered")
+		api.RespondOKSSZ(w, bid.Version(), sszBid)
+		go api.saveGetHeaderServed(log, servedHeader)
+		return
+	}
+
+	log.Info("bid delivered")
 	api.RespondOK(w, bid)
+	go api.saveGetHeaderServed(log, servedHeader)
+}
+
+// saveGetHeaderServed records which bid was returned to the proposer, to be able to reconcile it with the delivered payload
+func (api *RelayAPI) saveGetHeaderServed(log *logrus.Entry, entry database.GetHeaderServedEntry) {
+	err := api.db.SaveGetHeaderServed(entry)
+	if err != nil {
+		log.WithError(err).Error("failed to save served getHeader bid")
+	}
 }
 
 func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request) {
@@ -732,15 +1033,23 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 		"contentLength": req.ContentLength,
 	})
 
-	payload := new(types.SignedBlindedBeaconBlock)
-	if err := json.NewDecoder(req.Body).Decode(payload); err != nil {
-		log.WithError(err).Warn("getPayload request failed to decode")
+	requestBody, err := io.ReadAll(req.Body)
+	if err != nil {
+		log.WithError(err).Warn("getPayload request failed to read body")
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	requestIsSSZ := isSSZRequest(req)
+	payload, err := api.decodeSignedBlindedBeaconBlock(requestBody, requestIsSSZ)
+	if err != nil {
+		log.WithError(err).WithField("ssz", requestIsSSZ).Warn("getPayload request failed to decode")
 		api.RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	slot := payload.Message.Slot
-	blockHash := payload.Message.Body.ExecutionPayloadHeader.BlockHash
+	slot := payload.Slot()
+	blockHash := payload.BlockHash()
 	log = log.WithFields(logrus.Fields{
 		"slot":      slot,
 		"blockHash": blockHash.String(),
@@ -749,9 +1058,9 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 
 	log.Debug("getPayload request received")
 
-	proposerPubkey, found := api.datastore.GetKnownValidatorPubkeyByIndex(payload.Message.ProposerIndex)
+	proposerPubkey, found := api.datastore.GetKnownValidatorPubkeyByIndex(payload.ProposerIndex())
 	if !found {
-		log.Errorf("could not find proposer pubkey for index %d", payload.Message.ProposerIndex)
+		log.Errorf("could not find proposer pubkey for index %d", payload.ProposerIndex())
 		api.RespondError(w, http.StatusBadRequest, "could not match proposer index to pubkey")
 		return
 	}
@@ -767,7 +1076,7 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 	}
 
 	// Verify the signature
-	ok, err := types.VerifySignature(payload.Message, api.opts.EthNetDetails.DomainBeaconProposer, pk[:], payload.Signature[:])
+	ok, err := payload.VerifySignature(pk[:], api.opts.EthNetDetails.DomainBeaconProposerBellatrix, api.opts.EthNetDetails.DomainBeaconProposerCapella)
 	if !ok || err != nil {
 		log.WithError(err).Warn("could not verify payload signature")
 		api.RespondError(w, http.StatusBadRequest, "could not verify payload signature")
@@ -794,10 +1103,20 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 		}
 	}
 
-	api.RespondOK(w, getPayloadResp)
+	if acceptsSSZ(req) {
+		sszPayload, err := getPayloadResp.MarshalSSZ()
+		if err != nil {
+			log.WithError(err).Error("could not encode execution payload as ssz")
+			api.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		api.RespondOKSSZ(w, getPayloadResp.Version(), sszPayload)
+	} else {
+		api.RespondOK(w, getPayloadResp)
+	}
 	log = log.WithFields(logrus.Fields{
-		"numTx":       len(getPayloadResp.Data.Transactions),
-		"blockNumber": payload.Message.Body.ExecutionPayloadHeader.BlockNumber,
+		"numTx":   getPayloadResp.NumTx(),
+		"version": getPayloadResp.Version(),
 	})
 	log.Info("execution payload delivered")
 
@@ -826,6 +1145,16 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 		if err != nil {
 			log.WithError(err).Error("failed to increment builder-stats after getPayload")
 		}
+
+		// If the builder was demoted for this block, its collateral is needed for the proposer refund
+		if api.ffEnableOptimisticRelaying {
+			refundPending, err := api.db.UpdateBuilderDemotion(bidTrace, payload)
+			if err != nil {
+				log.WithError(err).Error("failed to update builder demotion after getPayload")
+			} else if refundPending {
+				log.Warn("delivered payload of a demoted builder, refund pending")
+			}
+		}
 	}()
 
 	// Publish the signed beacon block via beacon-node
@@ -834,7 +1163,11 @@ func (api *RelayAPI) handleGetPayload(w http.ResponseWriter, req *http.Request)
 			log.Info("publishing the block is disabled")
 			return
 		}
-		signedBeaconBlock := SignedBlindedBeaconBlockToBeaconBlock(payload, getPayloadResp.Data)
+		signedBeaconBlock, err := SignedBlindedBeaconBlockToBeaconBlock(payload, getPayloadResp)
+		if err != nil {
+			log.WithError(err).Error("could not construct signed beacon block")
+			return
+		}
 		_, _ = api.beaconClient.PublishBlock(signedBeaconBlock) // errors are logged inside
 	}()
 }
@@ -883,6 +1216,93 @@ func (api *RelayAPI) updatedExpectedRandao(slot uint64) {
 	}
 }
 
+// updatedExpectedWithdrawals updates the withdrawals root we expect from builder block submissions
+func (api *RelayAPI) updatedExpectedWithdrawals(slot uint64) {
+	if !api.opts.EthNetDetails.IsCapella(slot + 1) {
+		return
+	}
+
+	api.log.Infof("updating withdrawals for %d ...", slot)
+	api.expectedWithdrawalsLock.Lock()
+	latestKnownSlot := api.expectedWithdrawals.slot
+	if slot < latestKnownSlot || slot <= api.expectedWithdrawalsUpdating { // do nothing slot is already known or currently being updated
+		api.log.Debugf("- abort updating withdrawals - slot %d, latest: %d, updating: %d", slot, latestKnownSlot, api.expectedWithdrawalsUpdating)
+		api.expectedWithdrawalsLock.Unlock()
+		return
+	}
+	api.expectedWithdrawalsUpdating = slot
+	api.expectedWithdrawalsLock.Unlock()
+
+	// get withdrawals from BN
+	api.log.Debugf("- querying BN for withdrawals for slot %d", slot)
+	withdrawals, err := api.beaconClient.GetWithdrawals(slot)
+	if err == nil && withdrawals == nil {
+		err = ErrNoWithdrawalsResponse
+	}
+
+	var withdrawalsRoot phase0.Root
+	if err == nil {
+		withdrawalsRoot, err = common.ComputeWithdrawalsRoot(withdrawals.Data)
+	}
+
+	if err != nil {
+		api.log.WithField("slot", slot).WithError(err).Warn("failed to get withdrawals from beacon node")
+		api.expectedWithdrawalsLock.Lock()
+		api.expectedWithdrawalsUpdating = 0
+		api.expectedWithdrawalsLock.Unlock()
+		return
+	}
+
+	// after request, check if still the latest, then update
+	api.expectedWithdrawalsLock.Lock()
+	defer api.expectedWithdrawalsLock.Unlock()
+	targetSlot := slot + 1
+	api.log.Debugf("- after BN withdrawals: slot %d, targetSlot: %d latest: %d", slot, targetSlot, api.expectedWithdrawals.slot)
+
+	// update if still the latest
+	if targetSlot >= api.expectedWithdrawals.slot {
+		api.expectedWithdrawals = withdrawalsHelper{
+			slot: targetSlot, // the retrieved withdrawals are for the next slot
+			root: withdrawalsRoot,
+		}
+		api.log.WithField("slot", slot).Infof("updated expected withdrawals root to %s for slot %d", withdrawalsRoot.String(), targetSlot)
+	}
+}
+
+// decodeBuilderSubmission decodes a JSON or ssz builder submission into the fork of its slot
+func (api *RelayAPI) decodeBuilderSubmission(body []byte, isSSZ bool) (*common.BuilderSubmitBlockRequest, error) {
+	if isSSZ {
+		slot, err := common.SubmitBlockRequestSlotSSZ(body)
+		if err != nil {
+			return nil, err
+		}
+		return common.DecodeBuilderSubmitBlockRequestSSZ(body, api.opts.EthNetDetails.IsCapella(slot))
+	}
+
+	slot, err := getSlotFromJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	return common.DecodeBuilderSubmitBlockRequest(body, api.opts.EthNetDetails.IsCapella(slot))
+}
+
+// decodeSignedBlindedBeaconBlock decodes a JSON or ssz signed blinded beacon block into the fork of its slot
+func (api *RelayAPI) decodeSignedBlindedBeaconBlock(body []byte, isSSZ bool) (*common.SignedBlindedBeaconBlock, error) {
+	if isSSZ {
+		slot, err := common.SignedBlindedBeaconBlockSlotSSZ(body)
+		if err != nil {
+			return nil, err
+		}
+		return common.DecodeSignedBlindedBeaconBlockSSZ(body, api.opts.EthNetDetails.IsCapella(slot))
+	}
+
+	slot, err := getSlotFromJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	return common.DecodeSignedBlindedBeaconBlock(body, api.opts.EthNetDetails.IsCapella(slot))
+}
+
 func (api *RelayAPI) handleBuilderGetValidators(w http.ResponseWriter, req *http.Request) {
 	api.proposerDutiesLock.RLock()
 	defer api.proposerDutiesLock.RUnlock()
@@ -891,40 +1311,67 @@ func (api *RelayAPI) handleBuilderGetValidators(w http.ResponseWriter, req *http
 
 func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Request) {
 	receivedAt := time.Now().UTC()
+	isCancellationEnabled := req.URL.Query().Get("cancellations") == "1"
 	log := api.log.WithFields(logrus.Fields{
-		"method":        "submitNewBlock",
-		"contentLength": req.ContentLength,
+		"method":                "submitNewBlock",
+		"contentLength":         req.ContentLength,
+		"isCancellationEnabled": isCancellationEnabled,
 	})
 
+	ctx, span := tracing.Tracer.Start(req.Context(), "submitNewBlock")
+	defer span.End()
+
+	// the processing stages are timed and traced, a stage aborted by an early return only ends its span
+	stages := &submissionStages{ctx: ctx}
+	defer stages.abort()
+	stages.start("decode")
+
 	var err error
 	var r io.Reader = req.Body
 	if req.Header.Get("Content-Encoding") == "gzip" {
 		r, err = gzip.NewReader(req.Body)
 		if err != nil {
 			log.WithError(err).Warn("could not create gzip reader")
-			api.RespondError(w, http.StatusBadRequest, err.Error())
+			api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonInvalidRequestBody, err.Error())
 			return
 		}
 		log = log.WithField("gzip-req", true)
 	}
 
-	payload := new(types.BuilderSubmitBlockRequest)
-	if err := json.NewDecoder(r).Decode(payload); err != nil {
-		log.WithError(err).Warn("could not decode payload")
-		api.RespondError(w, http.StatusBadRequest, err.Error())
+	requestBody, err := io.ReadAll(r)
+	if err != nil {
+		log.WithError(err).Warn("could not read payload")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonInvalidRequestBody, err.Error())
 		return
 	}
 
-	if payload.Message == nil || payload.ExecutionPayload == nil {
-		api.RespondError(w, http.StatusBadRequest, "missing parts of the payload")
+	requestIsSSZ := isSSZRequest(req)
+	if requestIsSSZ {
+		log = log.WithField("ssz-req", true)
+	}
+
+	payload, err := api.decodeBuilderSubmission(requestBody, requestIsSSZ)
+	if errors.Is(err, common.ErrEmptyPayload) {
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonMissingPayloadFields, "missing parts of the payload")
+		return
+	} else if err != nil {
+		log.WithError(err).Warn("could not decode payload")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonInvalidPayload, err.Error())
 		return
 	}
 
 	log = log.WithFields(logrus.Fields{
-		"slot":          payload.Message.Slot,
-		"builderPubkey": payload.Message.BuilderPubkey.String(),
-		"blockHash":     payload.Message.BlockHash.String(),
+		"slot":          payload.Slot(),
+		"builderPubkey": payload.Message().BuilderPubkey.String(),
+		"blockHash":     payload.Message().BlockHash.String(),
+		"version":       payload.Version(),
 	})
+	span.SetAttributes(
+		attribute.Int64("slot", int64(payload.Slot())),
+		attribute.String("builderPubkey", payload.Message().BuilderPubkey.String()),
+		attribute.String("blockHash", payload.Message().BlockHash.String()),
+	)
+	stages.start("checks")
 
 	// Reject new submissions once the payload for this slot was delivered
 	slotStr, err := api.redis.GetStats(datastore.RedisStatsFieldSlotLastPayloadDelivered)
@@ -934,14 +1381,14 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 		slotLastPayloadDelivered, err := strconv.ParseUint(slotStr, 10, 64)
 		if err != nil {
 			log.WithError(err).Errorf("failed to parse delivered payload slot from redis: %s", slotStr)
-		} else if payload.Message.Slot <= slotLastPayloadDelivered {
+		} else if payload.Message().Slot <= slotLastPayloadDelivered {
 			log.Info("rejecting submission because payload for this slot was already delivered")
-			api.RespondError(w, http.StatusBadRequest, "payload for this slot was already delivered")
+			api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonPayloadAlreadyDelivered, "payload for this slot was already delivered")
 			return
 		}
 	}
 
-	builderIsHighPrio, builderIsBlacklisted, err := api.redis.GetBlockBuilderStatus(payload.Message.BuilderPubkey.String())
+	builderIsHighPrio, builderIsBlacklisted, err := api.redis.GetBlockBuilderStatus(payload.Message().BuilderPubkey.String())
 	log = log.WithFields(logrus.Fields{
 		"builderIsHighPrio":    builderIsHighPrio,
 		"builderIsBlacklisted": builderIsBlacklisted,
@@ -951,10 +1398,10 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	}
 
 	// Timestamp check
-	expectedTimestamp := api.genesisInfo.Data.GenesisTime + (payload.Message.Slot * 12)
-	if payload.ExecutionPayload.Timestamp != expectedTimestamp {
-		log.Warnf("incorrect timestamp. got %d, expected %d", payload.ExecutionPayload.Timestamp, expectedTimestamp)
-		api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("incorrect timestamp. got %d, expected %d", payload.ExecutionPayload.Timestamp, expectedTimestamp))
+	expectedTimestamp := api.genesisInfo.Data.GenesisTime + (payload.Message().Slot * 12)
+	if payload.Timestamp() != expectedTimestamp {
+		log.Warnf("incorrect timestamp. got %d, expected %d", payload.Timestamp(), expectedTimestamp)
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonIncorrectTimestamp, fmt.Sprintf("incorrect timestamp. got %d, expected %d", payload.Timestamp(), expectedTimestamp))
 		return
 	}
 
@@ -962,29 +1409,38 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	// - querying the randao from the BN if payload has a newer slot (might be faster than headSlot event)
 	// - check for validity happens later, again after validation (to use some time for BN request to finish...)
 	api.expectedPrevRandaoLock.RLock()
-	if payload.Message.Slot > api.expectedPrevRandao.slot {
-		go api.updatedExpectedRandao(payload.Message.Slot - 1)
+	if payload.Message().Slot > api.expectedPrevRandao.slot {
+		go api.updatedExpectedRandao(payload.Message().Slot - 1)
 	}
 	api.expectedPrevRandaoLock.RUnlock()
 
+	// same for the withdrawals, which are only part of the payload from Capella on
+	if payload.Capella != nil {
+		api.expectedWithdrawalsLock.RLock()
+		if payload.Slot() > api.expectedWithdrawals.slot {
+			go api.updatedExpectedWithdrawals(payload.Slot() - 1)
+		}
+		api.expectedWithdrawalsLock.RUnlock()
+	}
+
 	// ensure correct feeRecipient is used
 	api.proposerDutiesLock.RLock()
-	slotDuty := api.proposerDutiesMap[payload.Message.Slot]
+	slotDuty := api.proposerDutiesMap[payload.Message().Slot]
 	api.proposerDutiesLock.RUnlock()
 	if slotDuty == nil {
 		log.Warn("could not find slot duty")
-		api.RespondError(w, http.StatusBadRequest, "could not find slot duty")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonUnknownSlotDuty, "could not find slot duty")
 		return
-	} else if slotDuty.FeeRecipient != payload.Message.ProposerFeeRecipient {
+	} else if slotDuty.FeeRecipient != payload.Message().ProposerFeeRecipient {
 		log.Info("fee recipient does not match")
-		api.RespondError(w, http.StatusBadRequest, "fee recipient does not match")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonFeeRecipientMismatch, "fee recipient does not match")
 		return
 	}
 
 	if builderIsBlacklisted {
 		log.Info("builder is blacklisted")
 		time.Sleep(200 * time.Millisecond)
-		w.WriteHeader(http.StatusOK)
+		api.rejectSubmission(w, http.StatusOK, SubmitBlockReasonBuilderBlacklisted, "builder is blacklisted")
 		return
 	}
 
@@ -992,28 +1448,28 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	if api.ffDisableLowPrioBuilders && !builderIsHighPrio {
 		log.Info("rejecting low-prio builder (ff-disable-low-prio-builders)")
 		time.Sleep(200 * time.Millisecond)
-		w.WriteHeader(http.StatusOK)
+		api.rejectSubmission(w, http.StatusOK, SubmitBlockReasonLowPrioBuilderDisabled, "only high-prio builders are accepted")
 		return
 	}
 
 	log = log.WithFields(logrus.Fields{
 		"builderHighPrio": builderIsHighPrio,
-		"proposerPubkey":  payload.Message.ProposerPubkey.String(),
-		"parentHash":      payload.Message.ParentHash.String(),
-		"value":           payload.Message.Value.String(),
-		"tx":              len(payload.ExecutionPayload.Transactions),
+		"proposerPubkey":  payload.Message().ProposerPubkey.String(),
+		"parentHash":      payload.Message().ParentHash.String(),
+		"value":           payload.Message().Value.String(),
+		"tx":              payload.NumTx(),
 	})
 
-	if payload.Message.Slot <= api.headSlot.Load() {
+	if payload.Message().Slot <= api.headSlot.Load() {
 		api.log.Info("submitNewBlock failed: submission for past slot")
-		api.RespondError(w, http.StatusBadRequest, "submission for past slot")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonPastSlot, "submission for past slot")
 		return
 	}
 
 	// Don't accept blocks with 0 value
-	if payload.Message.Value.Cmp(&ZeroU256) == 0 || len(payload.ExecutionPayload.Transactions) == 0 {
+	if payload.Message().Value.Cmp(&ZeroU256) == 0 || payload.NumTx() == 0 {
 		api.log.Info("submitNewBlock failed: block with 0 value or no txs")
-		w.WriteHeader(http.StatusOK)
+		api.rejectSubmission(w, http.StatusOK, SubmitBlockReasonZeroValueOrNoTxs, "block with 0 value or no txs")
 		return
 	}
 
@@ -1021,7 +1477,7 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	err = SanityCheckBuilderBlockSubmission(payload)
 	if err != nil {
 		log.WithError(err).Info("block submission sanity checks failed")
-		api.RespondError(w, http.StatusBadRequest, err.Error())
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonSanityCheckFailed, err.Error())
 		return
 	}
 
@@ -1029,96 +1485,132 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	api.expectedPrevRandaoLock.RLock()
 	expectedRandao := api.expectedPrevRandao
 	api.expectedPrevRandaoLock.RUnlock()
-	if expectedRandao.slot != payload.Message.Slot { // we still don't have the prevrandao yet
+	if expectedRandao.slot != payload.Message().Slot { // we still don't have the prevrandao yet
 		log.Warn("prev_randao is not known yet")
-		api.RespondError(w, http.StatusInternalServerError, "prev_randao is not known yet")
+		api.rejectSubmission(w, http.StatusInternalServerError, SubmitBlockReasonPrevRandaoUnknown, "prev_randao is not known yet")
 		return
-	} else if expectedRandao.prevRandao != payload.ExecutionPayload.Random.String() {
-		msg := fmt.Sprintf("incorrect prev_randao - got: %s, expected: %s", payload.ExecutionPayload.Random.String(), expectedRandao.prevRandao)
+	} else if expectedRandao.prevRandao != payload.PrevRandao().String() {
+		msg := fmt.Sprintf("incorrect prev_randao - got: %s, expected: %s", payload.PrevRandao().String(), expectedRandao.prevRandao)
 		log.Info(msg)
-		api.RespondError(w, http.StatusBadRequest, msg)
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonIncorrectPrevRandao, msg)
 		return
 	}
 
+	// get the latest expected withdrawals and check the payload against them
+	if payload.Capella != nil {
+		api.expectedWithdrawalsLock.RLock()
+		expectedWithdrawals := api.expectedWithdrawals
+		api.expectedWithdrawalsLock.RUnlock()
+		err = checkSubmissionWithdrawals(payload, expectedWithdrawals)
+		if errors.Is(err, ErrWithdrawalsUnknown) {
+			log.Warn("withdrawals are not known yet")
+			api.rejectSubmission(w, http.StatusInternalServerError, SubmitBlockReasonWithdrawalsUnknown, err.Error())
+			return
+		} else if err != nil {
+			log.WithError(err).Info("withdrawals check failed")
+			api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonIncorrectWithdrawals, err.Error())
+			return
+		}
+	}
+
 	// Verify the signature
-	ok, err := types.VerifySignature(payload.Message, api.opts.EthNetDetails.DomainBuilder, payload.Message.BuilderPubkey[:], payload.Signature[:])
+	signature := payload.Signature()
+	ok, err := types.VerifySignature(payload.Message(), api.opts.EthNetDetails.DomainBuilder, payload.Message().BuilderPubkey[:], signature[:])
 	if !ok || err != nil {
 		log.WithError(err).Warn("could not verify builder signature")
-		api.RespondError(w, http.StatusBadRequest, "invalid signature")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonInvalidSignature, "invalid signature")
 		return
 	}
 
-	var simErr error
-
-	// At end of this function, save builder submission to database (in the background)
-	defer func() {
-		submissionEntry, err := api.db.SaveBuilderBlockSubmission(payload, simErr, receivedAt)
+	// Optimistic relaying: bids of high-prio builders which are covered by their collateral are accepted
+	// right away, and the block is simulated in the background (demoting the builder if it's invalid)
+	isOptimistic := false
+	if api.ffEnableOptimisticRelaying && builderIsHighPrio {
+		builderCollateral, err := api.redis.GetBlockBuilderCollateral(payload.Message().BuilderPubkey.String())
 		if err != nil {
-			log.WithError(err).WithField("payload", payload).Error("saving builder block submission to database failed")
-			return
+			log.WithError(err).Error("could not get block builder collateral")
+		} else {
+			isOptimistic = payload.Message().Value.Cmp(&builderCollateral) <= 0
 		}
+	}
+	log = log.WithField("optimistic", isOptimistic)
+	span.SetAttributes(attribute.Bool("optimistic", isOptimistic))
 
-		err = api.db.UpsertBlockBuilderEntryAfterSubmission(submissionEntry, simErr != nil)
-		if err != nil {
-			log.WithError(err).Error("failed to upsert block-builder-entry")
-		}
-	}()
+	var simErr error
+	simCtx := stages.start("simulation")
 
 	// Simulate the block submission and save to db
-	t := time.Now()
 	validationRequestPayload := &BuilderBlockValidationRequest{
-		BuilderSubmitBlockRequest: *payload,
+		BuilderSubmitBlockRequest: payload,
 		RegisteredGasLimit:        slotDuty.GasLimit,
 	}
-	simErr = api.blockSimRateLimiter.send(req.Context(), validationRequestPayload, builderIsHighPrio)
 
-	if simErr != nil {
-		log = log.WithField("simErr", simErr.Error())
-		log.WithError(simErr).WithFields(logrus.Fields{
-			"duration":   time.Since(t).Seconds(),
-			"numWaiting": api.blockSimRateLimiter.currentCounter(),
-		}).Info("block validation failed")
-		api.RespondError(w, http.StatusBadRequest, simErr.Error())
-		return
-	} else {
-		log.WithFields(logrus.Fields{
-			"duration":   time.Since(t).Seconds(),
-			"numWaiting": api.blockSimRateLimiter.currentCounter(),
-		}).Info("block validation successful")
-	}
+	// At end of this function, save builder submission to database. Optimistic submissions are simulated in the
+	// background then, and saved after their simulation. Only an invalid bid which was used demotes the builder.
+	isBidUsed := false
+	defer func() {
+		if isOptimistic {
+			go api.simulateOptimisticBlock(tracing.DetachedContext(simCtx), log, validationRequestPayload, receivedAt, isBidUsed)
+		} else {
+			api.saveBlockSubmission(ctx, log, payload, simErr, receivedAt)
+		}
+	}()
 
-	// Ensure this request is still the latest one
-	latestPayloadReceivedAt, err := api.redis.GetBuilderLatestPayloadReceivedAt(payload.Message.Slot, payload.Message.BuilderPubkey.String(), payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String())
-	if err != nil {
-		log.WithError(err).Error("failed getting latest payload receivedAt from redis")
-	} else if receivedAt.UnixMilli() < latestPayloadReceivedAt {
-		log.Infof("already have a newer payload: now=%d / prev=%d", receivedAt.UnixMilli(), latestPayloadReceivedAt)
-		api.RespondError(w, http.StatusBadRequest, "already using a newer payload")
-		return
+	var simDuration time.Duration
+	if !isOptimistic {
+		t := time.Now()
+		simErr = api.blockSimRateLimiter.send(simCtx, validationRequestPayload, builderIsHighPrio, false)
+		simDuration = time.Since(t)
+
+		if simErr != nil {
+			span.SetStatus(codes.Error, simErr.Error())
+			log = log.WithField("simErr", simErr.Error())
+			log.WithError(simErr).WithFields(logrus.Fields{
+				"duration":   simDuration.Seconds(),
+				"numWaiting": api.blockSimRateLimiter.currentCounter(),
+			}).Info("block validation failed")
+
+			// only an invalid block is the builder's fault, other errors are the relay's or the simulation node's
+			reason := SubmitBlockReasonSimulationError
+			if errors.Is(simErr, ErrSimulationFailed) {
+				reason = SubmitBlockReasonSimulationFailed
+			}
+			api.respondSubmission(w, http.StatusBadRequest, SubmitBlockResponse{
+				Code:                 http.StatusBadRequest,
+				Message:              simErr.Error(),
+				Status:               SubmitBlockStatusRejected,
+				Reason:               reason,
+				SimulationDurationMs: simDuration.Milliseconds(),
+			})
+			return
+		} else {
+			log.WithFields(logrus.Fields{
+				"duration":   simDuration.Seconds(),
+				"numWaiting": api.blockSimRateLimiter.currentCounter(),
+			}).Info("block validation successful")
+		}
 	}
+	stages.start("redis")
 
 	// Prepare the response data
-	signedBuilderBid, err := BuilderSubmitBlockRequestToSignedBuilderBid(payload, api.blsSk, api.publicKey, api.opts.EthNetDetails.DomainBuilder)
+	getHeaderResponse, err := BuildGetHeaderResponse(payload, api.blsSk, api.publicKey, api.opts.EthNetDetails.DomainBuilder)
 	if err != nil {
 		log.WithError(err).Error("could not sign builder bid")
-		api.RespondError(w, http.StatusBadRequest, err.Error())
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonInternalError, err.Error())
 		return
 	}
 
-	getHeaderResponse := types.GetHeaderResponse{
-		Version: VersionBellatrix,
-		Data:    signedBuilderBid,
-	}
-
-	getPayloadResponse := types.GetPayloadResponse{
-		Version: VersionBellatrix,
-		Data:    payload.ExecutionPayload,
+	getPayloadResponse, err := BuildGetPayloadResponse(payload)
+	if err != nil {
+		log.WithError(err).Error("could not build getPayload response")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonInternalError, err.Error())
+		return
 	}
 
 	bidTrace := common.BidTraceV2{
-		BidTrace:    *payload.Message,
-		BlockNumber: payload.ExecutionPayload.BlockNumber,
-		NumTx:       uint64(len(payload.ExecutionPayload.Transactions)),
+		BidTrace:    *payload.Message(),
+		BlockNumber: payload.BlockNumber(),
+		NumTx:       uint64(payload.NumTx()),
 	}
 
 	//
@@ -1128,45 +1620,145 @@ func (api *RelayAPI) handleSubmitNewBlock(w http.ResponseWriter, req *http.Reque
 	err = api.redis.SaveBidTrace(&bidTrace)
 	if err != nil {
 		log.WithError(err).Error("failed saving bidTrace in redis")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		api.rejectSubmission(w, http.StatusInternalServerError, SubmitBlockReasonInternalError, err.Error())
 		return
 	}
 
 	// save execution payload (getPayload response)
-	err = api.redis.SaveExecutionPayload(payload.Message.Slot, payload.Message.ProposerPubkey.String(), payload.Message.BlockHash.String(), &getPayloadResponse)
+	err = api.redis.SaveExecutionPayload(payload.Message().Slot, payload.Message().ProposerPubkey.String(), payload.Message().BlockHash.String(), getPayloadResponse)
 	if err != nil {
 		log.WithError(err).Error("failed saving execution payload in redis")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		api.rejectSubmission(w, http.StatusInternalServerError, SubmitBlockReasonInternalError, err.Error())
 		return
 	}
 
-	// save this builder's latest bid
-	err = api.redis.SaveLatestBuilderBid(payload.Message.Slot, payload.Message.BuilderPubkey.String(), payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String(), receivedAt, &getHeaderResponse)
+	// save this builder's latest bid (without cancellations, only if it's not lower than the builder's previous bid),
+	// and recalculate the top bid
+	bidState, err := api.redis.SaveBidAndUpdateTopBid(payload.Message().Slot, payload.Message().BuilderPubkey.String(), payload.Message().ParentHash.String(), payload.Message().ProposerPubkey.String(), receivedAt, getHeaderResponse, isCancellationEnabled)
 	if err != nil {
-		log.WithError(err).Error("could not save latest builder bid")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		log.WithError(err).Error("could not save latest builder bid and compute top bid")
+		api.rejectSubmission(w, http.StatusInternalServerError, SubmitBlockReasonInternalError, err.Error())
 		return
-	}
-
-	// recalculate top bid
-	err = api.redis.UpdateTopBid(payload.Message.Slot, payload.Message.ParentHash.String(), payload.Message.ProposerPubkey.String())
-	if err != nil {
-		log.WithError(err).Error("could not compute top bid")
-		api.RespondError(w, http.StatusInternalServerError, err.Error())
+	} else if bidState.NewerPayloadExists {
+		log.Info("already have a newer payload of this builder, not updating")
+		api.rejectSubmission(w, http.StatusBadRequest, SubmitBlockReasonNewerPayloadExists, "already using a newer payload")
+		return
+	} else if !bidState.WasBidSaved {
+		log.Info("bid is lower than the builder's previous bid and cancellations are disabled, not updating")
+		api.rejectSubmission(w, http.StatusOK, SubmitBlockReasonBidLowerThanPrevious, "bid is lower than the previous bid of this builder and cancellations are disabled")
 		return
 	}
+	isBidUsed = true
+	stages.complete()
 
 	//
 	// all done
 	//
 	log.WithFields(logrus.Fields{
-		"proposerPubkey": payload.Message.ProposerPubkey.String(),
-		"value":          payload.Message.Value.String(),
-		"tx":             len(payload.ExecutionPayload.Transactions),
+		"proposerPubkey": payload.Message().ProposerPubkey.String(),
+		"value":          payload.Message().Value.String(),
+		"tx":             payload.NumTx(),
 	}).Info("received block from builder")
 
-	// Respond with OK (TODO: proper response response data type https://flashbots.notion.site/Relay-API-Spec-5fb0819366954962bc02e81cb33840f5#fa719683d4ae4a57bc3bf60e138b0dc6)
-	w.WriteHeader(http.StatusOK)
+	api.respondSubmission(w, http.StatusOK, SubmitBlockResponse{
+		Status:               SubmitBlockStatusAccepted,
+		IsTopBid:             bidState.TopBidBuilderPubkey == payload.Message().BuilderPubkey.String(),
+		IsOptimistic:         isOptimistic,
+		SimulationDurationMs: simDuration.Milliseconds(),
+	})
+}
+
+// rejectSubmission responds to a rejected block submission with the reason
+func (api *RelayAPI) rejectSubmission(w http.ResponseWriter, code int, reason SubmitBlockReason, message string) {
+	resp := SubmitBlockResponse{
+		Message: message,
+		Status:  SubmitBlockStatusRejected,
+		Reason:  reason,
+	}
+	if code != http.StatusOK {
+		resp.Code = code
+	}
+	api.respondSubmission(w, code, resp)
+}
+
+// respondSubmission writes the result of a block submission
+func (api *RelayAPI) respondSubmission(w http.ResponseWriter, code int, resp SubmitBlockResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		api.log.WithField("response", resp).WithError(err).Error("Couldn't write submission response")
+	}
+}
+
+// saveBlockSubmission saves the builder submission and its simulation result to the database
+func (api *RelayAPI) saveBlockSubmission(ctx context.Context, log *logrus.Entry, payload *common.BuilderSubmitBlockRequest, simErr error, receivedAt time.Time) {
+	_, span := tracing.Tracer.Start(ctx, "saveBlockSubmission")
+	defer span.End()
+
+	submissionEntry, err := api.db.SaveBuilderBlockSubmission(payload, simErr, receivedAt)
+	if err != nil {
+		log.WithError(err).WithField("payload", payload).Error("saving builder block submission to database failed")
+		return
+	}
+
+	err = api.db.UpsertBlockBuilderEntryAfterSubmission(submissionEntry, simErr != nil)
+	if err != nil {
+		log.WithError(err).Error("failed to upsert block-builder-entry")
+	}
+}
+
+// simulateOptimisticBlock simulates a block submission whose bid was already processed, and demotes the builder if the
+// block is invalid and its bid was used
+func (api *RelayAPI) simulateOptimisticBlock(ctx context.Context, log *logrus.Entry, validationRequestPayload *BuilderBlockValidationRequest, receivedAt time.Time, isBidUsed bool) {
+	payload := validationRequestPayload.BuilderSubmitBlockRequest
+
+	t := time.Now()
+	simErr := api.blockSimRateLimiter.send(ctx, validationRequestPayload, true, true)
+	log = log.WithFields(logrus.Fields{
+		"duration":   time.Since(t).Seconds(),
+		"numWaiting": api.blockSimRateLimiter.currentCounter(),
+	})
+	api.saveBlockSubmission(ctx, log, payload, simErr, receivedAt)
+
+	if simErr == nil {
+		log.Info("optimistic block validation successful")
+		return
+	}
+
+	// only an invalid block is the builder's fault, not failing to reach the simulation node
+	if !errors.Is(simErr, ErrSimulationFailed) {
+		log.WithError(simErr).Error("optimistic block validation errored")
+		return
+	}
+
+	// a bid which was rejected was never served to a proposer
+	if !isBidUsed {
+		log.WithError(simErr).Warn("optimistic block validation failed for an unused bid, not demoting builder")
+		return
+	}
+
+	log.WithError(simErr).Warn("optimistic block validation failed, demoting builder")
+	api.demoteBuilder(log, payload, simErr)
+}
+
+// demoteBuilder removes the high-prio status of a builder (and with it optimistic relaying) and records the incident
+func (api *RelayAPI) demoteBuilder(log *logrus.Entry, payload *common.BuilderSubmitBlockRequest, simErr error) {
+	builderPubkey := payload.Message().BuilderPubkey.String()
+
+	err := api.redis.DemoteBlockBuilder(builderPubkey)
+	if err != nil {
+		log.WithError(err).Error("could not demote block builder in redis")
+	}
+
+	err = api.db.DemoteBlockBuilder(builderPubkey)
+	if err != nil {
+		log.WithError(err).Error("could not demote block builder in database")
+	}
+
+	err = api.db.InsertBuilderDemotion(payload, simErr)
+	if err != nil {
+		log.WithError(err).Error("could not save builder demotion")
+	}
 }
 
 // ---------------
@@ -1217,6 +1809,37 @@ func (api *RelayAPI) handleInternalBuilderStatus(w http.ResponseWriter, req *htt
 	}
 }
 
+func (api *RelayAPI) handleInternalBuilderCollateral(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	builderPubkey := vars["pubkey"]
+
+	var collateral types.U256Str
+	err := collateral.UnmarshalText([]byte(req.URL.Query().Get("value")))
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid value argument")
+		return
+	}
+
+	api.log.WithFields(logrus.Fields{
+		"builderPubkey": builderPubkey,
+		"collateral":    collateral.String(),
+	}).Info("updating builder collateral")
+
+	err = api.redis.SetBlockBuilderCollateral(builderPubkey, collateral.String())
+	if err != nil {
+		api.log.WithError(err).Error("could not set block builder collateral in redis")
+	}
+
+	err = api.db.SetBlockBuilderCollateral(builderPubkey, collateral.String())
+	if err != nil {
+		api.log.WithError(err).Error("could not set block builder collateral in database")
+	}
+
+	api.RespondOK(w, struct {
+		Collateral string `json:"collateral"`
+	}{Collateral: collateral.String()})
+}
+
 // -----------
 //  DATA APIS
 // -----------
@@ -1280,17 +1903,9 @@ func (api *RelayAPI) handleDataProposerPayloadDelivered(w http.ResponseWriter, r
 		filters.BuilderPubkey = args.Get("builder_pubkey")
 	}
 
-	if args.Get("limit") != "" {
-		_limit, err := strconv.ParseUint(args.Get("limit"), 10, 64)
-		if err != nil {
-			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
-			return
-		}
-		if _limit > filters.Limit {
-			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum limit is %d", filters.Limit))
-			return
-		}
-		filters.Limit = _limit
+	if filters.Limit, err = parseDataLimit(args.Get("limit"), filters.Limit); err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	if args.Get("order_by") == "value" {
@@ -1299,6 +1914,12 @@ func (api *RelayAPI) handleDataProposerPayloadDelivered(w http.ResponseWriter, r
 		filters.OrderByValue = -1
 	}
 
+	filters.BidFilters, err = parseBidFilters(args)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	deliveredPayloads, err := api.db.GetRecentDeliveredPayloads(filters)
 	if err != nil {
 		api.log.WithError(err).Error("error getting recent payloads")
@@ -1318,16 +1939,25 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 	var err error
 	args := req.URL.Query()
 
+	bidFilters, err := parseBidFilters(args)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	filters := database.GetBuilderSubmissionsFilters{
+		BidFilters:    bidFilters,
 		Limit:         200,
 		Slot:          0,
 		BlockHash:     "",
 		BlockNumber:   0,
 		BuilderPubkey: "",
+		OrderBy:       database.BuilderSubmissionsOrder(args.Get("order_by")),
+		Cursor:        args.Get("cursor"),
 	}
 
-	if args.Get("cursor") != "" {
-		api.RespondError(w, http.StatusBadRequest, "cursor argument not supported on this API")
+	if !filters.OrderBy.IsValid() {
+		api.RespondError(w, http.StatusBadRequest, "invalid order_by argument")
 		return
 	}
 
@@ -1340,6 +1970,11 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 			api.RespondError(w, http.StatusBadRequest, "invalid slot argument")
 			return
 		}
+	} else if args.Get("cursor") != "" {
+		if err = database.ValidateBuilderSubmissionsCursor(filters.Cursor, filters.OrderBy); err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid cursor argument")
+			return
+		}
 	}
 
 	if args.Get("block_hash") != "" {
@@ -1368,17 +2003,9 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 		filters.BuilderPubkey = args.Get("builder_pubkey")
 	}
 
-	if args.Get("limit") != "" {
-		_limit, err := strconv.ParseUint(args.Get("limit"), 10, 64)
-		if err != nil {
-			api.RespondError(w, http.StatusBadRequest, "invalid limit argument")
-			return
-		}
-		if _limit > filters.Limit {
-			api.RespondError(w, http.StatusBadRequest, fmt.Sprintf("maximum limit is %d", filters.Limit))
-			return
-		}
-		filters.Limit = _limit
+	if filters.Limit, err = parseDataLimit(args.Get("limit"), filters.Limit); err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	blockSubmissions, err := api.db.GetBuilderSubmissions(filters)
@@ -1388,6 +2015,11 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 		return
 	}
 
+	// a full page may be followed by more submissions
+	if filters.Slot == 0 && len(blockSubmissions) > 0 && uint64(len(blockSubmissions)) >= filters.Limit {
+		w.Header().Set(HeaderNextCursor, database.BuilderSubmissionsCursor(blockSubmissions[len(blockSubmissions)-1], filters.OrderBy))
+	}
+
 	response := make([]common.BidTraceV2WithTimestampJSON, len(blockSubmissions))
 	for i, payload := range blockSubmissions {
 		response[i] = database.BuilderSubmissionEntryToBidTraceV2WithTimestampJSON(payload)
@@ -1396,6 +2028,70 @@ func (api *RelayAPI) handleDataBuilderBidsReceived(w http.ResponseWriter, req *h
 	api.RespondOK(w, response)
 }
 
+func (api *RelayAPI) handleDataProposerHeaderServed(w http.ResponseWriter, req *http.Request) {
+	var err error
+	args := req.URL.Query()
+
+	filters := database.GetHeaderServedFilters{
+		Limit: 200,
+	}
+
+	if args.Get("slot") != "" && args.Get("cursor") != "" {
+		api.RespondError(w, http.StatusBadRequest, "cannot specify both slot and cursor")
+		return
+	} else if args.Get("slot") != "" {
+		filters.Slot, err = strconv.ParseUint(args.Get("slot"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid slot argument")
+			return
+		}
+	} else if args.Get("cursor") != "" {
+		filters.Cursor, err = strconv.ParseUint(args.Get("cursor"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid cursor argument")
+			return
+		}
+	}
+
+	if args.Get("block_hash") != "" {
+		var hash types.Hash
+		err = hash.UnmarshalText([]byte(args.Get("block_hash")))
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid block_hash argument")
+			return
+		}
+		filters.BlockHash = args.Get("block_hash")
+	}
+
+	if args.Get("proposer_pubkey") != "" {
+		if err = checkBLSPublicKeyHex(args.Get("proposer_pubkey")); err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid proposer_pubkey argument")
+			return
+		}
+		filters.ProposerPubkey = args.Get("proposer_pubkey")
+	}
+
+	if filters.Limit, err = parseDataLimit(args.Get("limit"), filters.Limit); err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	servedHeaders, err := api.db.GetGetHeaderServed(filters)
+	if err != nil {
+		api.log.WithError(err).Error("error getting served headers")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// the ip is stored for internal reconciliation only and not exposed
+	response := make([]common.GetHeaderServedJSON, len(servedHeaders))
+	for i, entry := range servedHeaders {
+		response[i] = database.GetHeaderServedEntryToJSON(entry)
+	}
+
+	api.RespondOK(w, response)
+}
+
 func (api *RelayAPI) handleDataValidatorRegistration(w http.ResponseWriter, req *http.Request) {
 	pkStr := req.URL.Query().Get("pubkey")
 	if pkStr == "" {
@@ -1430,3 +2126,170 @@ func (api *RelayAPI) handleDataValidatorRegistration(w http.ResponseWriter, req
 
 	api.RespondOK(w, signedRegistration)
 }
+
+// handleDataValidatorRegistrationHistory returns the changes of a validator's fee recipient and gas limit, newest
+// first. The next page starts before the cursor timestamp.
+func (api *RelayAPI) handleDataValidatorRegistrationHistory(w http.ResponseWriter, req *http.Request) {
+	var err error
+	args := req.URL.Query()
+
+	var pk types.PublicKey
+	if args.Get("pubkey") == "" {
+		api.RespondError(w, http.StatusBadRequest, "missing pubkey argument")
+		return
+	} else if err = pk.UnmarshalText([]byte(args.Get("pubkey"))); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid pubkey argument")
+		return
+	}
+
+	cursor := uint64(0)
+	if args.Get("cursor") != "" {
+		cursor, err = strconv.ParseUint(args.Get("cursor"), 10, 64)
+		if err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid cursor argument")
+			return
+		}
+	}
+
+	limit, err := parseDataLimit(args.Get("limit"), 200)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := api.db.GetValidatorRegistrationHistory(pk.String(), cursor, limit)
+	if err != nil {
+		api.log.WithError(err).Error("error getting validator registration history")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// a full page may be followed by older registrations
+	if len(entries) > 0 && uint64(len(entries)) >= limit {
+		w.Header().Set(HeaderNextCursor, strconv.FormatUint(entries[len(entries)-1].Timestamp, 10))
+	}
+	api.respondValidatorRegistrationEntries(w, entries)
+}
+
+// handleDataValidatorRegistrations returns the latest registrations of the validators currently using a fee
+// recipient, ordered by pubkey. The next page starts after the cursor pubkey.
+func (api *RelayAPI) handleDataValidatorRegistrations(w http.ResponseWriter, req *http.Request) {
+	var err error
+	args := req.URL.Query()
+
+	var feeRecipient types.Address
+	if args.Get("fee_recipient") == "" {
+		api.RespondError(w, http.StatusBadRequest, "missing fee_recipient argument")
+		return
+	} else if err = feeRecipient.UnmarshalText([]byte(args.Get("fee_recipient"))); err != nil {
+		api.RespondError(w, http.StatusBadRequest, "invalid fee_recipient argument")
+		return
+	}
+
+	cursor := ""
+	if args.Get("cursor") != "" {
+		var pk types.PublicKey
+		if err = pk.UnmarshalText([]byte(args.Get("cursor"))); err != nil {
+			api.RespondError(w, http.StatusBadRequest, "invalid cursor argument")
+			return
+		}
+		cursor = pk.String()
+	}
+
+	limit, err := parseDataLimit(args.Get("limit"), 200)
+	if err != nil {
+		api.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := api.db.GetValidatorRegistrationsByFeeRecipient(feeRecipient.String(), cursor, limit)
+	if err != nil {
+		api.log.WithError(err).Error("error getting validator registrations by fee recipient")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	api.respondValidatorRegistrationEntries(w, entries)
+}
+
+// parseBidFilters parses the slot range, time range (unix seconds), fee recipient and minimum value arguments of the
+// data API
+func parseBidFilters(args url.Values) (filters database.BidFilters, err error) {
+	parseUint := func(name string) (uint64, error) {
+		if args.Get(name) == "" {
+			return 0, nil
+		}
+		value, err := strconv.ParseUint(args.Get(name), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidArgument, name)
+		}
+		return value, nil
+	}
+	parseTime := func(name string) (time.Time, error) {
+		seconds, err := parseUint(name)
+		if err != nil || seconds == 0 {
+			return time.Time{}, err
+		}
+		return time.Unix(int64(seconds), 0), nil
+	}
+
+	if filters.SlotFrom, err = parseUint("slot_from"); err != nil {
+		return filters, err
+	} else if filters.SlotTo, err = parseUint("slot_to"); err != nil {
+		return filters, err
+	} else if filters.SlotTo > 0 && filters.SlotFrom > filters.SlotTo {
+		return filters, fmt.Errorf("%w: slot_from is after slot_to", ErrInvalidRange)
+	}
+
+	if filters.FromTime, err = parseTime("from_time"); err != nil {
+		return filters, err
+	} else if filters.ToTime, err = parseTime("to_time"); err != nil {
+		return filters, err
+	} else if !filters.ToTime.IsZero() && filters.FromTime.After(filters.ToTime) {
+		return filters, fmt.Errorf("%w: from_time is after to_time", ErrInvalidRange)
+	}
+
+	if args.Get("fee_recipient") != "" {
+		var feeRecipient types.Address
+		if err = feeRecipient.UnmarshalText([]byte(args.Get("fee_recipient"))); err != nil {
+			return filters, fmt.Errorf("%w: fee_recipient", ErrInvalidArgument)
+		}
+		filters.FeeRecipient = feeRecipient.String()
+	}
+
+	if args.Get("min_value") != "" {
+		minValue, ok := new(big.Int).SetString(args.Get("min_value"), 10)
+		if !ok || minValue.Sign() < 0 {
+			return filters, fmt.Errorf("%w: min_value", ErrInvalidArgument)
+		}
+		filters.MinValue = minValue.String()
+	}
+	return filters, nil
+}
+
+// parseDataLimit parses the limit argument of a data API request, which defaults to and may not exceed maxLimit
+func parseDataLimit(limitArg string, maxLimit uint64) (uint64, error) {
+	if limitArg == "" {
+		return maxLimit, nil
+	}
+	limit, err := strconv.ParseUint(limitArg, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidLimit
+	} else if limit > maxLimit {
+		return 0, fmt.Errorf("%w: maximum limit is %d", ErrLimitTooHigh, maxLimit)
+	}
+	return limit, nil
+}
+
+func (api *RelayAPI) respondValidatorRegistrationEntries(w http.ResponseWriter, entries []*database.ValidatorRegistrationEntry) {
+	response := make([]*types.SignedValidatorRegistration, len(entries))
+	for i, entry := range entries {
+		signedRegistration, err := entry.ToSignedValidatorRegistration()
+		if err != nil {
+			api.log.WithError(err).Error("error converting registration entry to signed validator registration")
+			api.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response[i] = signedRegistration
+	}
+	api.RespondOK(w, response)
+}
@@ -2,20 +2,31 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/flashbots/go-boost-utils/bls"
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/go-utils/jsonrpc"
 	"github.com/flashbots/mev-boost-relay/beaconclient"
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
 	"github.com/flashbots/mev-boost-relay/datastore"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 var (
@@ -53,12 +64,15 @@ func newTestBackend(t require.TestingT, numBeaconNodes int) *testBackend {
 		Redis:        redisCache,
 		DB:           db,
 		EthNetDetails: common.EthNetworkDetails{
-			Name:                     "test",
-			GenesisForkVersionHex:    genesisForkVersionHex,
-			GenesisValidatorsRootHex: "",
-			BellatrixForkVersionHex:  "0x00000000",
-			DomainBuilder:            builderSigningDomain,
-			DomainBeaconProposer:     types.Domain{},
+			Name:                          "test",
+			GenesisForkVersionHex:         genesisForkVersionHex,
+			GenesisValidatorsRootHex:      "",
+			BellatrixForkVersionHex:       "0x00000000",
+			CapellaForkVersionHex:         "0x00000000",
+			CapellaForkEpoch:              common.CapellaForkEpochNever,
+			DomainBuilder:                 builderSigningDomain,
+			DomainBeaconProposerBellatrix: types.Domain{},
+			DomainBeaconProposerCapella:   types.Domain{},
 		},
 		SecretKey:       sk,
 		ProposerAPI:     true,
@@ -78,6 +92,19 @@ func newTestBackend(t require.TestingT, numBeaconNodes int) *testBackend {
 	return &backend
 }
 
+func (be *testBackend) requestBytes(method, path string, payload []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, bytes.NewReader(payload))
+	require.NoError(be.t, err)
+
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
+
+	rr := httptest.NewRecorder()
+	be.relay.getRouter().ServeHTTP(rr, req)
+	return rr
+}
+
 func (be *testBackend) request(method, path string, payload any) *httptest.ResponseRecorder {
 	var req *http.Request
 	var err error
@@ -219,6 +246,183 @@ func TestRegisterValidator(t *testing.T) {
 	})
 }
 
+func TestRegisterValidatorPolicy(t *testing.T) {
+	path := "/eth/v1/builder/validators"
+	backend := newTestBackend(t, 1)
+
+	denied, err := generateSignedValidatorRegistration(nil, types.Address{0xde}, uint64(time.Now().Unix()))
+	require.NoError(t, err)
+	accepted, err := generateSignedValidatorRegistration(nil, types.Address{1}, uint64(time.Now().Unix()))
+	require.NoError(t, err)
+	for i, payload := range []*types.SignedValidatorRegistration{denied, accepted} {
+		require.NoError(t, backend.redis.SetKnownValidator(payload.Message.Pubkey.PubkeyHex(), uint64(i)))
+	}
+	_, err = backend.datastore.RefreshKnownValidators()
+	require.NoError(t, err)
+
+	policy, err := NewRegistrationPolicy(&RegistrationPolicyConfig{DeniedFeeRecipients: []string{denied.Message.FeeRecipient.String()}})
+	require.NoError(t, err)
+	backend.relay.registrationPolicy.Store(policy)
+
+	// the rejected registration is reported, and the batch is processed anyway
+	rr := backend.request(http.MethodPost, path, []types.SignedValidatorRegistration{*denied, *accepted})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	resp := new(RegisterValidatorResponse)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), resp))
+	require.Equal(t, []RegisterValidatorRejection{{Pubkey: denied.Message.Pubkey.String(), Reason: RegistrationPolicyReasonFeeRecipientDenied}}, resp.Rejected)
+
+	require.Len(t, backend.relay.validatorRegC, 1)
+	require.Equal(t, *accepted, <-backend.relay.validatorRegC)
+}
+
+func TestRegisterValidatorResults(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	now := uint64(time.Now().Unix())
+
+	generate := func(timestamp uint64, isKnown bool) *types.SignedValidatorRegistration {
+		t.Helper()
+		payload, err := generateSignedValidatorRegistration(nil, types.Address{1}, timestamp)
+		require.NoError(t, err)
+		if isKnown {
+			require.NoError(t, backend.redis.SetKnownValidator(payload.Message.Pubkey.PubkeyHex(), 1))
+		}
+		return payload
+	}
+	accepted := generate(now, true)
+	unchanged := generate(now, true)
+	require.NoError(t, backend.redis.SetValidatorRegistrationTimestamp(unchanged.Message.Pubkey.PubkeyHex(), now))
+	unknown := generate(now, false)
+	badSignature := generate(now, true)
+	badSignature.Message.FeeRecipient = types.Address{2}
+	inFuture := generate(now+60, true)
+	_, err := backend.datastore.RefreshKnownValidators()
+	require.NoError(t, err)
+
+	registrations := []types.SignedValidatorRegistration{*badSignature, *accepted, *unchanged, *unknown, *inFuture}
+	payload, err := json.Marshal(registrations)
+	require.NoError(t, err)
+	// the last registration has no timestamp
+	payload = append(payload[:len(payload)-1], []byte(`,{"message":{"pubkey":"0xb1"}}]`)...)
+
+	t.Run("stops at the first invalid registration by default", func(t *testing.T) {
+		rr := backend.requestBytes(http.MethodPost, pathRegisterValidator, payload, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Contains(t, rr.Body.String(), "failed to verify validator signature")
+		require.Empty(t, backend.relay.validatorRegC)
+	})
+
+	t.Run("processes all registrations with results", func(t *testing.T) {
+		rr := backend.requestBytes(http.MethodPost, pathRegisterValidator+"?results=1", payload, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		resp := new(RegisterValidatorResultsResponse)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), resp))
+
+		expected := []RegisterValidatorStatus{
+			RegisterValidatorStatusBadSignature,
+			RegisterValidatorStatusAccepted,
+			RegisterValidatorStatusUnchanged,
+			RegisterValidatorStatusUnknownValidator,
+			RegisterValidatorStatusTimestampInFuture,
+			RegisterValidatorStatusInvalid,
+		}
+		require.Len(t, resp.Results, len(expected))
+		for i, status := range expected {
+			require.Equal(t, status, resp.Results[i].Status, resp.Results[i].Error)
+		}
+		for i, registration := range registrations {
+			require.Equal(t, registration.Message.Pubkey.String(), resp.Results[i].Pubkey)
+		}
+		require.Equal(t, "0xb1", resp.Results[5].Pubkey)
+		require.Empty(t, resp.Results[1].Error)
+
+		require.Len(t, backend.relay.validatorRegC, 1)
+		require.Equal(t, *accepted, <-backend.relay.validatorRegC)
+	})
+}
+
+// newTestRegistrationsBackend returns a backend knowing the validators of the testdata registrations, which are
+// signed for kiln
+func newTestRegistrationsBackend(t require.TestingT, filename string) (*testBackend, []byte, []types.SignedValidatorRegistration) {
+	backend := newTestBackend(t, 1)
+	kiln, err := common.NewEthNetworkDetails(common.EthNetworkKiln)
+	require.NoError(t, err)
+	backend.relay.opts.EthNetDetails = *kiln
+
+	payload, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	registrations := []types.SignedValidatorRegistration{}
+	require.NoError(t, json.Unmarshal(payload, &registrations))
+
+	knownValidators := make(map[types.PubkeyHex]uint64, len(registrations))
+	for i, registration := range registrations {
+		knownValidators[registration.Message.Pubkey.PubkeyHex()] = uint64(i)
+	}
+	_, err = backend.redis.SetKnownValidators(knownValidators, nil)
+	require.NoError(t, err)
+	_, err = backend.datastore.RefreshKnownValidators()
+	require.NoError(t, err)
+	return backend, payload, registrations
+}
+
+func TestRegisterValidatorParallelVerification(t *testing.T) {
+	backend, _, registrations := newTestRegistrationsBackend(t, "../../testdata/valreg2.json")
+	badSignatureIndex := 600
+	registrations[badSignatureIndex].Signature = registrations[badSignatureIndex+1].Signature
+
+	drain := func() (queued []types.SignedValidatorRegistration) {
+		for len(backend.relay.validatorRegC) > 0 {
+			queued = append(queued, <-backend.relay.validatorRegC)
+		}
+		return queued
+	}
+
+	t.Run("registrations before the bad signature are queued in order", func(t *testing.T) {
+		rr := backend.request(http.MethodPost, pathRegisterValidator, registrations)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Contains(t, rr.Body.String(), "failed to verify validator signature for "+registrations[badSignatureIndex].Message.Pubkey.String())
+		require.Equal(t, registrations[:badSignatureIndex], drain())
+	})
+
+	t.Run("all other registrations are accepted with results", func(t *testing.T) {
+		rr := backend.request(http.MethodPost, pathRegisterValidator+"?results=1", registrations)
+		require.Equal(t, http.StatusOK, rr.Code)
+		resp := new(RegisterValidatorResultsResponse)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), resp))
+		require.Len(t, resp.Results, len(registrations))
+		for i, result := range resp.Results {
+			if i == badSignatureIndex {
+				require.Equal(t, RegisterValidatorStatusBadSignature, result.Status)
+			} else {
+				require.Equal(t, RegisterValidatorStatusAccepted, result.Status, result.Error)
+			}
+		}
+		require.Len(t, drain(), len(registrations)-1)
+	})
+}
+
+func BenchmarkRegisterValidator(b *testing.B) {
+	for _, filename := range []string{"valreg1.json", "valreg2.json"} {
+		for _, numVerifiers := range []int{1, 2, 4, 8} {
+			b.Run(fmt.Sprintf("%s/verifiers=%d", filename, numVerifiers), func(b *testing.B) {
+				numValidatorRegVerifiers = numVerifiers
+				defer func() { numValidatorRegVerifiers = runtime.NumCPU() }()
+
+				// registration timestamps are only saved by the db processor, so all signatures are verified every time
+				backend, payload, registrations := newTestRegistrationsBackend(b, "../../testdata/"+filename)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					rr := backend.requestBytes(http.MethodPost, pathRegisterValidator, payload, nil)
+					require.Equal(b, http.StatusOK, rr.Code)
+					require.Len(b, backend.relay.validatorRegC, len(registrations))
+					for len(backend.relay.validatorRegC) > 0 {
+						<-backend.relay.validatorRegC
+					}
+				}
+			})
+		}
+	}
+}
+
 func TestBuilderApiGetValidators(t *testing.T) {
 	path := "/relay/v1/builder/validators"
 
@@ -274,3 +478,481 @@ func TestDataApiGetDataProposerPayloadDelivered(t *testing.T) {
 		}
 	})
 }
+
+func TestDataApiGetValidatorRegistrationHistory(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	pubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+
+	testCases := []struct {
+		query string
+		code  int
+		msg   string
+	}{
+		{query: "?pubkey=" + pubkey, code: http.StatusOK},
+		{query: "?pubkey=" + pubkey + "&cursor=1663311456&limit=10", code: http.StatusOK},
+		{query: "", code: http.StatusBadRequest, msg: "missing pubkey argument"},
+		{query: "?pubkey=0xb1", code: http.StatusBadRequest, msg: "invalid pubkey argument"},
+		{query: "?pubkey=" + pubkey + "&cursor=x", code: http.StatusBadRequest, msg: "invalid cursor argument"},
+		{query: "?pubkey=" + pubkey + "&limit=201", code: http.StatusBadRequest, msg: "maximum limit is 200"},
+	}
+	for _, tc := range testCases {
+		rr := backend.request(http.MethodGet, pathDataValidatorRegHistory+tc.query, nil)
+		require.Equal(t, tc.code, rr.Code, tc.query)
+		require.Contains(t, rr.Body.String(), tc.msg)
+	}
+}
+
+// registrationHistoryDB returns the entries older than the cursor timestamp, newest first
+type registrationHistoryDB struct {
+	database.MockDB
+	entries []*database.ValidatorRegistrationEntry
+}
+
+func (db *registrationHistoryDB) GetValidatorRegistrationHistory(pubkey string, cursor, limit uint64) ([]*database.ValidatorRegistrationEntry, error) {
+	entries := []*database.ValidatorRegistrationEntry{}
+	for _, entry := range db.entries {
+		if (cursor == 0 || entry.Timestamp < cursor) && uint64(len(entries)) < limit {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func TestDataApiGetValidatorRegistrationHistoryPagination(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	pubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+	db := &registrationHistoryDB{}
+	for i := uint64(0); i < 3; i++ {
+		entry := database.SignedValidatorRegistrationToEntry(common.ValidPayloadRegisterValidator)
+		entry.Timestamp -= i
+		db.entries = append(db.entries, &entry)
+	}
+	backend.relay.db = db
+	timestamp := common.ValidPayloadRegisterValidator.Message.Timestamp
+
+	rr := backend.request(http.MethodGet, pathDataValidatorRegHistory+"?limit=2&pubkey="+pubkey, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	resp := []types.SignedValidatorRegistration{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp, 2)
+	require.Equal(t, strconv.FormatUint(timestamp-1, 10), rr.Header().Get(HeaderNextCursor))
+
+	// the last page has no next cursor
+	rr = backend.request(http.MethodGet, pathDataValidatorRegHistory+"?limit=2&pubkey="+pubkey+"&cursor="+rr.Header().Get(HeaderNextCursor), nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Empty(t, rr.Header().Get(HeaderNextCursor))
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp, 1)
+	require.Equal(t, timestamp-2, resp[0].Message.Timestamp)
+}
+
+func TestDataApiGetValidatorRegistrations(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	feeRecipient := common.ValidPayloadRegisterValidator.Message.FeeRecipient.String()
+	pubkey := common.ValidPayloadRegisterValidator.Message.Pubkey.String()
+
+	testCases := []struct {
+		query string
+		code  int
+		msg   string
+	}{
+		{query: "?fee_recipient=" + feeRecipient, code: http.StatusOK},
+		{query: "?fee_recipient=" + feeRecipient + "&cursor=" + pubkey + "&limit=10", code: http.StatusOK},
+		{query: "", code: http.StatusBadRequest, msg: "missing fee_recipient argument"},
+		{query: "?fee_recipient=0xfee", code: http.StatusBadRequest, msg: "invalid fee_recipient argument"},
+		{query: "?fee_recipient=" + feeRecipient + "&cursor=0xb1", code: http.StatusBadRequest, msg: "invalid cursor argument"},
+		{query: "?fee_recipient=" + feeRecipient + "&limit=x", code: http.StatusBadRequest, msg: "invalid limit argument"},
+	}
+	for _, tc := range testCases {
+		rr := backend.request(http.MethodGet, pathDataValidatorRegistrations+tc.query, nil)
+		require.Equal(t, tc.code, rr.Code, tc.query)
+		require.Contains(t, rr.Body.String(), tc.msg)
+	}
+}
+
+// builderSubmissionsDB returns the submissions from index cursor on, ignoring the order
+type builderSubmissionsDB struct {
+	database.MockDB
+	entries []*database.BuilderBlockSubmissionEntry
+	filters []database.GetBuilderSubmissionsFilters
+}
+
+func (db *builderSubmissionsDB) GetBuilderSubmissions(filters database.GetBuilderSubmissionsFilters) ([]*database.BuilderBlockSubmissionEntry, error) {
+	db.filters = append(db.filters, filters)
+	entries := db.entries
+	if filters.Cursor != "" {
+		for i, entry := range db.entries {
+			if database.BuilderSubmissionsCursor(entry, filters.OrderBy) == filters.Cursor {
+				entries = db.entries[i+1:]
+			}
+		}
+	}
+	if uint64(len(entries)) > filters.Limit {
+		entries = entries[:filters.Limit]
+	}
+	return entries, nil
+}
+
+func TestDataApiGetBuilderBidsReceivedPagination(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	db := &builderSubmissionsDB{}
+	for i := 0; i < 3; i++ {
+		db.entries = append(db.entries, &database.BuilderBlockSubmissionEntry{ID: int64(10 + i), Slot: uint64(100 - i), Value: fmt.Sprint(i)})
+	}
+	backend.relay.db = db
+
+	rr := backend.request(http.MethodGet, pathDataBuilderBidsReceived+"?limit=2&order_by=-value", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Equal(t, "1_11", rr.Header().Get(HeaderNextCursor))
+	require.Equal(t, database.BuilderSubmissionsOrderValueDesc, db.filters[0].OrderBy)
+
+	// the last page has no next cursor
+	rr = backend.request(http.MethodGet, pathDataBuilderBidsReceived+"?limit=2&order_by=-value&cursor=1_11", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Empty(t, rr.Header().Get(HeaderNextCursor))
+	resp := []common.BidTraceV2WithTimestampJSON{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp, 1)
+	require.Equal(t, uint64(98), resp[0].Slot)
+
+	for query, msg := range map[string]string{
+		"?order_by=slot":              "invalid order_by argument",
+		"?cursor=100":                 "invalid cursor argument",
+		"?cursor=x_1&order_by=-value": "invalid cursor argument",
+		"?cursor=100_1&slot=100":      "cannot specify both slot and cursor",
+	} {
+		rr = backend.request(http.MethodGet, pathDataBuilderBidsReceived+query, nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code, query)
+		require.Contains(t, rr.Body.String(), msg, query)
+	}
+}
+
+func TestParseBidFilters(t *testing.T) {
+	feeRecipient := "0xFFBB8996515293FCD87CA09B5C6FFE5C17F043C6"
+
+	t.Run("valid filters", func(t *testing.T) {
+		args := url.Values{}
+		args.Set("slot_from", "100")
+		args.Set("slot_to", "200")
+		args.Set("from_time", "1677672000")
+		args.Set("to_time", "1677672012")
+		args.Set("fee_recipient", feeRecipient)
+		args.Set("min_value", "1000000000000000000")
+		filters, err := parseBidFilters(args)
+		require.NoError(t, err)
+		require.Equal(t, database.BidFilters{
+			SlotFrom:     100,
+			SlotTo:       200,
+			FromTime:     time.Unix(1677672000, 0),
+			ToTime:       time.Unix(1677672012, 0),
+			FeeRecipient: strings.ToLower(feeRecipient),
+			MinValue:     "1000000000000000000",
+		}, filters)
+
+		filters, err = parseBidFilters(url.Values{})
+		require.NoError(t, err)
+		require.Equal(t, database.BidFilters{}, filters)
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		for query, expectedErr := range map[string]error{
+			"slot_from=x":           ErrInvalidArgument,
+			"to_time=-1":            ErrInvalidArgument,
+			"slot_from=2&slot_to=1": ErrInvalidRange,
+			"from_time=1677672012&to_time=1677672000": ErrInvalidRange,
+			"fee_recipient=0xffbb":                    ErrInvalidArgument,
+			"min_value=-1":                            ErrInvalidArgument,
+			"min_value=1e18":                          ErrInvalidArgument,
+		} {
+			args, err := url.ParseQuery(query)
+			require.NoError(t, err)
+			_, err = parseBidFilters(args)
+			require.ErrorIs(t, err, expectedErr, query)
+		}
+		_, err := parseBidFilters(url.Values{"slot_from": []string{"x"}})
+		require.EqualError(t, err, "invalid argument: slot_from")
+		_, err = parseBidFilters(url.Values{"slot_from": []string{"2"}, "slot_to": []string{"1"}})
+		require.EqualError(t, err, "invalid range: slot_from is after slot_to")
+	})
+
+	t.Run("filters are passed to the database", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+		db := &builderSubmissionsDB{}
+		backend.relay.db = db
+
+		rr := backend.request(http.MethodGet, pathDataBuilderBidsReceived+"?slot_from=100&min_value=5", nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.Equal(t, database.BidFilters{SlotFrom: 100, MinValue: "5"}, db.filters[0].BidFilters)
+
+		rr = backend.request(http.MethodGet, pathDataProposerPayloadDelivered+"?fee_recipient=0xfee", nil)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Contains(t, rr.Body.String(), "invalid argument: fee_recipient")
+	})
+}
+
+func TestDataApiGetDataProposerHeaderServed(t *testing.T) {
+	path := "/relay/v1/data/bidtraces/proposer_header_served"
+
+	t.Run("Accept valid arguments", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+		rr := backend.request(http.MethodGet, path+"?slot=123&limit=10", nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "[]\n", rr.Body.String())
+	})
+
+	t.Run("Reject invalid arguments", func(t *testing.T) {
+		backend := newTestBackend(t, 1)
+		testCases := map[string]string{
+			"?slot=1&cursor=2":        "cannot specify both slot and cursor",
+			"?block_hash=0xaaaa":      "invalid block_hash argument",
+			"?proposer_pubkey=0x1234": "invalid proposer_pubkey argument",
+			"?limit=1000":             "maximum limit is 200",
+		}
+		for query, errMsg := range testCases {
+			rr := backend.request(http.MethodGet, path+query, nil)
+			require.Equal(t, http.StatusBadRequest, rr.Code, query)
+			require.Contains(t, rr.Body.String(), errMsg)
+		}
+	})
+}
+
+func TestBuilderSubmitBlockSSZ(t *testing.T) {
+	path := "/relay/v1/builder/blocks"
+
+	jsonPayload, err := os.ReadFile("../../testdata/submitBlockPayloadCapella.json")
+	require.NoError(t, err)
+	sszPayload, err := os.ReadFile("../../testdata/submitBlockPayloadCapella.ssz")
+	require.NoError(t, err)
+
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.EthNetDetails.CapellaForkEpoch = 0
+
+	// randao and withdrawals already known, so no beacon node is queried
+	backend.relay.expectedPrevRandao.slot = 5552306
+	backend.relay.expectedWithdrawals.slot = 5552306
+
+	// align genesis with the payload timestamp, so the submission passes the timestamp check
+	backend.relay.genesisInfo = &beaconclient.GetGenesisResponse{}
+	backend.relay.genesisInfo.Data.GenesisTime = 1679345112 - 5552306*12
+
+	// both encodings go through the same validation and fail on the (missing) proposer duty
+	rrJSON := backend.requestBytes(http.MethodPost, path, jsonPayload, nil)
+	require.Equal(t, http.StatusBadRequest, rrJSON.Code)
+	require.Contains(t, rrJSON.Body.String(), "could not find slot duty")
+
+	rrSSZ := backend.requestBytes(http.MethodPost, path, sszPayload, map[string]string{"Content-Type": HeaderContentTypeSSZ})
+	require.Equal(t, http.StatusBadRequest, rrSSZ.Code)
+	require.Equal(t, rrJSON.Body.String(), rrSSZ.Body.String())
+
+	// truncated ssz is rejected while decoding
+	rr := backend.requestBytes(http.MethodPost, path, sszPayload[:200], map[string]string{"Content-Type": HeaderContentTypeSSZ})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.NotContains(t, rr.Body.String(), "could not find slot duty")
+}
+
+func TestBuilderSubmitBlockRejectionReasons(t *testing.T) {
+	path := "/relay/v1/builder/blocks"
+	jsonPayload, err := os.ReadFile("../../testdata/submitBlockPayloadCapella.json")
+	require.NoError(t, err)
+	payload, err := common.DecodeBuilderSubmitBlockRequest(jsonPayload, true)
+	require.NoError(t, err)
+	slot := payload.Slot()
+
+	testCases := []struct {
+		name           string
+		body           []byte
+		setup          func(backend *testBackend)
+		expectedCode   int
+		expectedReason SubmitBlockReason
+	}{
+		{
+			name:           "invalid payload",
+			body:           []byte(`{"message":{"slot":"1"}`),
+			expectedCode:   http.StatusBadRequest,
+			expectedReason: SubmitBlockReasonInvalidPayload,
+		},
+		{
+			name:           "missing payload fields",
+			body:           []byte(`{}`),
+			expectedCode:   http.StatusBadRequest,
+			expectedReason: SubmitBlockReasonMissingPayloadFields,
+		},
+		{
+			name:           "unknown slot duty",
+			body:           jsonPayload,
+			expectedCode:   http.StatusBadRequest,
+			expectedReason: SubmitBlockReasonUnknownSlotDuty,
+		},
+		{
+			name: "fee recipient mismatch",
+			body: jsonPayload,
+			setup: func(backend *testBackend) {
+				backend.relay.proposerDutiesMap[slot] = &types.RegisterValidatorRequestMessage{FeeRecipient: types.Address{0x01}}
+			},
+			expectedCode:   http.StatusBadRequest,
+			expectedReason: SubmitBlockReasonFeeRecipientMismatch,
+		},
+		{
+			name: "blacklisted builder",
+			body: jsonPayload,
+			setup: func(backend *testBackend) {
+				backend.relay.proposerDutiesMap[slot] = &types.RegisterValidatorRequestMessage{FeeRecipient: payload.Message().ProposerFeeRecipient}
+				err := backend.redis.SetBlockBuilderStatus(payload.Message().BuilderPubkey.String(), datastore.RedisBlockBuilderStatusBlacklisted)
+				require.NoError(t, err)
+			},
+			expectedCode:   http.StatusOK,
+			expectedReason: SubmitBlockReasonBuilderBlacklisted,
+		},
+		{
+			name: "past slot",
+			body: jsonPayload,
+			setup: func(backend *testBackend) {
+				backend.relay.proposerDutiesMap[slot] = &types.RegisterValidatorRequestMessage{FeeRecipient: payload.Message().ProposerFeeRecipient}
+				backend.relay.headSlot.Store(slot)
+			},
+			expectedCode:   http.StatusBadRequest,
+			expectedReason: SubmitBlockReasonPastSlot,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := newTestBackend(t, 1)
+			backend.relay.opts.EthNetDetails.CapellaForkEpoch = 0
+			backend.relay.expectedPrevRandao.slot = slot
+			backend.relay.expectedWithdrawals.slot = slot
+			backend.relay.genesisInfo = &beaconclient.GetGenesisResponse{}
+			backend.relay.genesisInfo.Data.GenesisTime = payload.Timestamp() - slot*12
+			backend.relay.proposerDutiesMap = make(map[uint64]*types.RegisterValidatorRequestMessage)
+			if tc.setup != nil {
+				tc.setup(backend)
+			}
+
+			rr := backend.requestBytes(http.MethodPost, path, tc.body, nil)
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			resp := new(SubmitBlockResponse)
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), resp))
+			require.Equal(t, SubmitBlockStatusRejected, resp.Status)
+			require.Equal(t, tc.expectedReason, resp.Reason)
+			require.False(t, resp.IsTopBid)
+			if tc.expectedCode != http.StatusOK {
+				require.Equal(t, tc.expectedCode, resp.Code)
+			}
+		})
+	}
+}
+
+func TestGetHeaderSSZ(t *testing.T) {
+	jsonPayload, err := os.ReadFile("../../testdata/submitBlockPayloadCapella.json")
+	require.NoError(t, err)
+
+	payload, err := common.DecodeBuilderSubmitBlockRequest(jsonPayload, true)
+	require.NoError(t, err)
+
+	backend := newTestBackend(t, 1)
+	getHeaderResponse, err := BuildGetHeaderResponse(payload, backend.relay.blsSk, backend.relay.publicKey, builderSigningDomain)
+	require.NoError(t, err)
+
+	slot := payload.Slot()
+	parentHash := payload.ParentHash().String()
+	proposerPubkey := payload.Message().ProposerPubkey.String()
+	_, err = backend.redis.SaveBidAndUpdateTopBid(slot, payload.Message().BuilderPubkey.String(), parentHash, proposerPubkey, time.Now(), getHeaderResponse, false)
+	require.NoError(t, err)
+
+	path := fmt.Sprintf("/eth/v1/builder/header/%d/%s/%s", slot, parentHash, proposerPubkey)
+
+	// JSON by default
+	rr := backend.requestBytes(http.MethodGet, path, nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	// ssz when accepted
+	rr = backend.requestBytes(http.MethodGet, path, nil, map[string]string{"Accept": HeaderContentTypeSSZ})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, HeaderContentTypeSSZ, rr.Header().Get("Content-Type"))
+	require.Equal(t, string(common.VersionCapella), rr.Header().Get(HeaderEthConsensusVersion))
+
+	bid := new(common.SignedBuilderBidCapella)
+	require.NoError(t, bid.UnmarshalSSZ(rr.Body.Bytes()))
+	require.Equal(t, getHeaderResponse.Capella.Data, bid)
+}
+
+func TestSimulateOptimisticBlock(t *testing.T) {
+	jsonPayload, err := os.ReadFile("../../testdata/submitBlockPayloadCapella.json")
+	require.NoError(t, err)
+	payload, err := common.DecodeBuilderSubmitBlockRequest(jsonPayload, true)
+	require.NoError(t, err)
+	builderPubkey := payload.Message().BuilderPubkey.String()
+
+	simServer := func(t *testing.T, simErr string) *httptest.Server {
+		t.Helper()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp := jsonrpc.JSONRPCResponse{ID: 1, Version: "2.0"}
+			if simErr != "" {
+				resp.Error = &jsonrpc.JSONRPCError{Code: -32000, Message: simErr}
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	testCases := []struct {
+		name           string
+		simErr         string
+		simUnreachable bool
+		isBidUnused    bool
+		expectDemoted  bool
+	}{
+		{name: "valid block", expectDemoted: false},
+		{name: "invalid block", simErr: "invalid block", expectDemoted: true},
+		{name: "invalid block of a rejected bid", simErr: "invalid block", isBidUnused: true, expectDemoted: false},
+		{name: "simulation node unreachable", simUnreachable: true, expectDemoted: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := newTestBackend(t, 1)
+			server := simServer(t, tc.simErr)
+			if tc.simUnreachable {
+				server.Close()
+			}
+			backend.relay.blockSimRateLimiter = NewBlockSimulationRateLimiter([]string{server.URL}, nil)
+
+			err := backend.redis.SetBlockBuilderStatus(builderPubkey, datastore.RedisBlockBuilderStatusHighPrio)
+			require.NoError(t, err)
+
+			validationRequest := &BuilderBlockValidationRequest{BuilderSubmitBlockRequest: payload}
+			backend.relay.simulateOptimisticBlock(context.Background(), backend.relay.log, validationRequest, time.Now(), !tc.isBidUnused)
+
+			isHighPrio, _, err := backend.redis.GetBlockBuilderStatus(builderPubkey)
+			require.NoError(t, err)
+			require.Equal(t, !tc.expectDemoted, isHighPrio)
+		})
+	}
+}
+
+func TestSendJSONRPCRequestTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator()) })
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":"1","result":null}`))
+	}))
+	defer server.Close()
+
+	// without a span, no trace context is sent
+	_, err := SendJSONRPCRequest(context.Background(), *jsonrpc.NewJSONRPCRequest("1", "test", nil), server.URL, false)
+	require.NoError(t, err)
+	require.Equal(t, "", traceparent)
+
+	// the trace and span id of the current span are propagated
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+	_, err = SendJSONRPCRequest(ctx, *jsonrpc.NewJSONRPCRequest("1", "test", nil), server.URL, false)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("00-%s-%s-01", span.SpanContext().TraceID(), span.SpanContext().SpanID()), traceparent)
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/go-utils/cli"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	topBidStreamBufferSize   = cli.GetEnvInt("TOP_BID_STREAM_BUFFER_SIZE", 64)
+	topBidStreamPingInterval = time.Duration(cli.GetEnvInt("TOP_BID_STREAM_PING_INTERVAL_SEC", 15)) * time.Second
+)
+
+// topBidStream fans out the top bid updates of all relay instances, received via redis pub/sub, to the
+// builders connected to this instance
+type topBidStream struct {
+	mu          sync.Mutex
+	subscribers map[chan *common.TopBidUpdate]struct{}
+}
+
+func newTopBidStream() *topBidStream {
+	return &topBidStream{subscribers: make(map[chan *common.TopBidUpdate]struct{})}
+}
+
+// subscribe returns a channel receiving the top bid updates, until unsubscribe is called
+func (s *topBidStream) subscribe() (updates chan *common.TopBidUpdate, unsubscribe func()) {
+	updates = make(chan *common.TopBidUpdate, topBidStreamBufferSize)
+	s.mu.Lock()
+	s.subscribers[updates] = struct{}{}
+	s.mu.Unlock()
+
+	return updates, func() {
+		s.mu.Lock()
+		delete(s.subscribers, updates)
+		s.mu.Unlock()
+	}
+}
+
+// publish sends the update to all subscribers. Slow subscribers with a full buffer miss the update, so they
+// can't hold back the others.
+func (s *topBidStream) publish(update *common.TopBidUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for updates := range s.subscribers {
+		select {
+		case updates <- update:
+		default:
+		}
+	}
+}
+
+func (s *topBidStream) numSubscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers)
+}
+
+// startTopBidStream forwards the top bid updates from redis to the stream subscribers, resubscribing on errors
+func (api *RelayAPI) startTopBidStream() {
+	for {
+		err := api.redis.SubscribeTopBidUpdates(context.Background(), api.topBidStream.publish)
+		api.log.WithError(err).Error("top bid updates subscription failed, resubscribing")
+		time.Sleep(time.Second)
+	}
+}
+
+func hashStreamToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// isValidStreamToken checks the token against the hash stored for the builder
+func (api *RelayAPI) isValidStreamToken(builderPubkey, token string) (bool, error) {
+	tokenHash, err := api.redis.GetBlockBuilderStreamToken(builderPubkey)
+	if err != nil || tokenHash == "" {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(tokenHash), []byte(hashStreamToken(token))) == 1, nil
+}
+
+// handleBuilderTopBidStream streams the top bid updates as server-sent events. Builders authenticate with HTTP
+// basic auth, using their pubkey as username and the token issued via the internal API as password.
+func (api *RelayAPI) handleBuilderTopBidStream(w http.ResponseWriter, req *http.Request) {
+	builderPubkey, token, ok := req.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="top bid stream"`)
+		api.RespondError(w, http.StatusUnauthorized, "missing credentials")
+		return
+	}
+	builderPubkey = strings.ToLower(builderPubkey) // tokens are stored by the lowercase pubkey
+
+	log := api.log.WithFields(logrus.Fields{
+		"method":        "topBidStream",
+		"builderPubkey": builderPubkey,
+	})
+
+	isValid, err := api.isValidStreamToken(builderPubkey, token)
+	if err != nil {
+		log.WithError(err).Error("could not get stream token")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if !isValid {
+		api.RespondError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	_, isBlacklisted, err := api.redis.GetBlockBuilderStatus(builderPubkey)
+	if err != nil {
+		log.WithError(err).Error("could not get block builder status")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if isBlacklisted {
+		api.RespondError(w, http.StatusForbidden, "builder is blacklisted")
+		return
+	}
+
+	// the stream outlives the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.WithError(err).Error("could not clear write deadline")
+		api.RespondError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	updates, unsubscribe := api.topBidStream.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.WithError(err).Error("could not flush stream")
+		return
+	}
+	log.Info("top bid stream opened")
+
+	ping := time.NewTicker(topBidStreamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			log.Info("top bid stream closed")
+			return
+		case update := <-updates:
+			data, err := json.Marshal(update)
+			if err != nil {
+				log.WithError(err).Error("could not marshal top bid update")
+				continue
+			}
+			_, err = fmt.Fprintf(w, "event: top_bid\ndata: %s\n\n", data)
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				log.WithError(err).Info("could not write top bid update, closing stream")
+				return
+			}
+		case <-ping.C:
+			// keeps the connection alive through proxies, and detects disconnected clients
+			_, err = fmt.Fprint(w, ": ping\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				log.WithError(err).Info("could not write ping, closing stream")
+				return
+			}
+		}
+	}
+}
+
+// handleInternalBuilderStreamToken issues (POST) or revokes (DELETE) the builder's top bid stream token. Issuing a
+// new token replaces the previous one. Only the hash of the token is stored.
+func (api *RelayAPI) handleInternalBuilderStreamToken(w http.ResponseWriter, req *http.Request) {
+	builderPubkey := strings.ToLower(mux.Vars(req)["pubkey"])
+	log := api.log.WithField("builderPubkey", builderPubkey)
+
+	if req.Method == http.MethodDelete {
+		if err := api.redis.DeleteBlockBuilderStreamToken(builderPubkey); err != nil {
+			log.WithError(err).Error("could not delete stream token")
+			api.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Info("revoked top bid stream token")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.WithError(err).Error("could not generate stream token")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	if err := api.redis.SetBlockBuilderStreamToken(builderPubkey, hashStreamToken(token)); err != nil {
+		log.WithError(err).Error("could not set stream token")
+		api.RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Info("issued top bid stream token")
+
+	api.RespondOK(w, struct {
+		Token string `json:"token"`
+	}{Token: token})
+}
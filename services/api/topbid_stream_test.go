@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopBidStreamPublish(t *testing.T) {
+	stream := newTopBidStream()
+	updates1, unsubscribe1 := stream.subscribe()
+	updates2, unsubscribe2 := stream.subscribe()
+	defer unsubscribe2()
+
+	update := &common.TopBidUpdate{Slot: 1}
+	stream.publish(update)
+	require.Equal(t, update, <-updates1)
+	require.Equal(t, update, <-updates2)
+
+	// unsubscribed channels don't receive updates anymore
+	unsubscribe1()
+	require.Equal(t, 1, stream.numSubscribers())
+	stream.publish(update)
+	require.Empty(t, updates1)
+	require.Equal(t, update, <-updates2)
+
+	// a full subscriber misses updates, without blocking the publisher
+	for i := 0; i < topBidStreamBufferSize+1; i++ {
+		stream.publish(update)
+	}
+	require.Len(t, updates2, topBidStreamBufferSize)
+}
+
+func TestBuilderTopBidStream(t *testing.T) {
+	backend := newTestBackend(t, 1)
+	backend.relay.opts.InternalAPI = true
+	builderPubkey := "0xfa1ed37c3553d0ce1e9349b2c5063cf6e394d231c8d3e0df75e9462257c081543086109ffddaacc0aa76f33dc9661c83"
+	pathStreamToken := "/internal/v1/builder/stream_token/" + builderPubkey
+
+	// issue a token
+	rr := backend.request(http.MethodPost, pathStreamToken, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp := struct {
+		Token string `json:"token"`
+	}{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Token, 64)
+
+	server := httptest.NewServer(backend.relay.getRouter())
+	t.Cleanup(server.Close)
+
+	openStream := func(t *testing.T, username, password string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+pathBuilderTopBidStream, nil)
+		require.NoError(t, err)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	t.Run("missing or invalid credentials", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, openStream(t, "", "").StatusCode)
+		require.Equal(t, http.StatusUnauthorized, openStream(t, builderPubkey, "invalid").StatusCode)
+		require.Equal(t, http.StatusUnauthorized, openStream(t, "0xb1", resp.Token).StatusCode)
+	})
+
+	t.Run("blacklisted builder", func(t *testing.T) {
+		require.NoError(t, backend.redis.SetBlockBuilderStatus(builderPubkey, datastore.RedisBlockBuilderStatusBlacklisted))
+		defer func() {
+			require.NoError(t, backend.redis.SetBlockBuilderStatus(builderPubkey, datastore.RedisBlockBuilderStatusLowPrio))
+		}()
+		require.Equal(t, http.StatusForbidden, openStream(t, builderPubkey, resp.Token).StatusCode)
+
+		// the pubkey is case insensitive
+		require.Equal(t, http.StatusForbidden, openStream(t, "0x"+strings.ToUpper(builderPubkey[2:]), resp.Token).StatusCode)
+	})
+
+	t.Run("streams the top bid updates", func(t *testing.T) {
+		res := openStream(t, builderPubkey, resp.Token)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+		require.Eventually(t, func() bool { return backend.relay.topBidStream.numSubscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+		update := &common.TopBidUpdate{Slot: 123, ParentHash: "0xa1", BuilderPubkey: "0xb1", Value: "100"}
+		backend.relay.topBidStream.publish(update)
+
+		reader := bufio.NewReader(res.Body)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "event: top_bid\n", line)
+		line, err = reader.ReadString('\n')
+		require.NoError(t, err)
+		received := new(common.TopBidUpdate)
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), received))
+		require.Equal(t, update, received)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		rr := backend.request(http.MethodDelete, pathStreamToken, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, http.StatusUnauthorized, openStream(t, builderPubkey, resp.Token).StatusCode)
+	})
+}
@@ -1,10 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 
+	apiv1capella "github.com/attestantio/go-eth2-client/api/v1/capella"
+	"github.com/attestantio/go-eth2-client/spec/capella"
 	"github.com/flashbots/go-boost-utils/bls"
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
 )
 
 var (
@@ -19,10 +24,155 @@ type HTTPErrorResp struct {
 
 var NilResponse = struct{}{}
 
-var VersionBellatrix types.VersionString = "bellatrix"
+// SubmitBlockStatus is whether a block submission was accepted by the relay
+type SubmitBlockStatus string
+
+const (
+	SubmitBlockStatusAccepted SubmitBlockStatus = "accepted"
+	SubmitBlockStatusRejected SubmitBlockStatus = "rejected"
+)
+
+// SubmitBlockReason is the stable, machine-readable reason a block submission was rejected
+type SubmitBlockReason string
+
+const (
+	SubmitBlockReasonInvalidRequestBody      SubmitBlockReason = "invalid_request_body"
+	SubmitBlockReasonMissingPayloadFields    SubmitBlockReason = "missing_payload_fields"
+	SubmitBlockReasonInvalidPayload          SubmitBlockReason = "invalid_payload"
+	SubmitBlockReasonPayloadAlreadyDelivered SubmitBlockReason = "payload_already_delivered"
+	SubmitBlockReasonIncorrectTimestamp      SubmitBlockReason = "incorrect_timestamp"
+	SubmitBlockReasonUnknownSlotDuty         SubmitBlockReason = "unknown_slot_duty"
+	SubmitBlockReasonFeeRecipientMismatch    SubmitBlockReason = "fee_recipient_mismatch"
+	SubmitBlockReasonBuilderBlacklisted      SubmitBlockReason = "builder_blacklisted"
+	SubmitBlockReasonLowPrioBuilderDisabled  SubmitBlockReason = "low_prio_builders_disabled"
+	SubmitBlockReasonPastSlot                SubmitBlockReason = "past_slot"
+	SubmitBlockReasonZeroValueOrNoTxs        SubmitBlockReason = "zero_value_or_no_txs"
+	SubmitBlockReasonSanityCheckFailed       SubmitBlockReason = "sanity_check_failed"
+	SubmitBlockReasonPrevRandaoUnknown       SubmitBlockReason = "prev_randao_unknown"
+	SubmitBlockReasonIncorrectPrevRandao     SubmitBlockReason = "incorrect_prev_randao"
+	SubmitBlockReasonWithdrawalsUnknown      SubmitBlockReason = "withdrawals_unknown"
+	SubmitBlockReasonIncorrectWithdrawals    SubmitBlockReason = "incorrect_withdrawals_root"
+	SubmitBlockReasonInvalidSignature        SubmitBlockReason = "invalid_signature"
+	SubmitBlockReasonSimulationFailed        SubmitBlockReason = "simulation_failed"
+	SubmitBlockReasonSimulationError         SubmitBlockReason = "simulation_error"
+	SubmitBlockReasonNewerPayloadExists      SubmitBlockReason = "newer_payload_exists"
+	SubmitBlockReasonBidLowerThanPrevious    SubmitBlockReason = "bid_lower_than_previous"
+	SubmitBlockReasonInternalError           SubmitBlockReason = "internal_error"
+)
+
+// SubmitBlockResponse is the result of a block submission. Rejections with an error status code also carry the
+// code and message fields of HTTPErrorResp, to stay compatible with clients which only parse those.
+type SubmitBlockResponse struct {
+	Code                 int               `json:"code,omitempty"`
+	Message              string            `json:"message,omitempty"`
+	Status               SubmitBlockStatus `json:"status"`
+	Reason               SubmitBlockReason `json:"reason,omitempty"`
+	IsTopBid             bool              `json:"is_top_bid"`
+	IsOptimistic         bool              `json:"is_optimistic"`
+	SimulationDurationMs int64             `json:"simulation_duration_ms,omitempty"`
+}
+
+// RegisterValidatorRejection is a validator registration rejected by the registration policy
+type RegisterValidatorRejection struct {
+	Pubkey string                   `json:"pubkey"`
+	Reason RegistrationPolicyReason `json:"reason"`
+}
+
+// RegisterValidatorResponse lists the registrations rejected by the registration policy, while the others in the
+// batch were processed
+type RegisterValidatorResponse struct {
+	Rejected []RegisterValidatorRejection `json:"rejected"`
+}
+
+// RegisterValidatorStatus is the outcome of a single validator registration
+type RegisterValidatorStatus string
+
+const (
+	RegisterValidatorStatusAccepted          RegisterValidatorStatus = "accepted"
+	RegisterValidatorStatusUnchanged         RegisterValidatorStatus = "unchanged" // not newer than the last registration
+	RegisterValidatorStatusUnknownValidator  RegisterValidatorStatus = "unknown_validator"
+	RegisterValidatorStatusBadSignature      RegisterValidatorStatus = "bad_signature"
+	RegisterValidatorStatusTimestampInFuture RegisterValidatorStatus = "timestamp_in_future"
+	RegisterValidatorStatusRejectedByPolicy  RegisterValidatorStatus = "rejected_by_policy"
+	RegisterValidatorStatusInvalid           RegisterValidatorStatus = "invalid"
+)
+
+// RegisterValidatorResult is the result of a single validator registration, in the per-registration results mode
+type RegisterValidatorResult struct {
+	Pubkey string                   `json:"pubkey"`
+	Status RegisterValidatorStatus  `json:"status"`
+	Reason RegistrationPolicyReason `json:"reason,omitempty"` // only for rejected_by_policy
+	Error  string                   `json:"error,omitempty"`
+}
+
+// RegisterValidatorResultsResponse has the results of all registrations of the batch, in the order of the request
+type RegisterValidatorResultsResponse struct {
+	Results []RegisterValidatorResult `json:"results"`
+}
+
+var (
+	HeaderContentTypeSSZ      = "application/octet-stream"
+	HeaderEthConsensusVersion = "Eth-Consensus-Version"
+	HeaderNextCursor          = "X-Next-Cursor"
+	HeaderAPIKey              = "X-API-Key"
+)
 
 var ZeroU256 = types.IntToU256(0)
 
+// BuildGetHeaderResponse signs a builder bid for the submission and wraps it in the matching fork's getHeader response
+func BuildGetHeaderResponse(payload *common.BuilderSubmitBlockRequest, sk *bls.SecretKey, pubkey *types.PublicKey, domain types.Domain) (*common.GetHeaderResponse, error) {
+	if payload == nil {
+		return nil, ErrMissingRequest
+	}
+
+	if payload.Capella != nil {
+		signedBuilderBid, err := BuilderSubmitBlockRequestCapellaToSignedBuilderBid(payload.Capella, sk, pubkey, domain)
+		if err != nil {
+			return nil, err
+		}
+		return &common.GetHeaderResponse{
+			Capella: &common.GetHeaderResponseCapella{
+				Version: common.VersionCapella,
+				Data:    signedBuilderBid,
+			},
+		}, nil
+	}
+
+	signedBuilderBid, err := BuilderSubmitBlockRequestToSignedBuilderBid(payload.Bellatrix, sk, pubkey, domain)
+	if err != nil {
+		return nil, err
+	}
+	return &common.GetHeaderResponse{
+		Bellatrix: &types.GetHeaderResponse{
+			Version: common.VersionBellatrix,
+			Data:    signedBuilderBid,
+		},
+	}, nil
+}
+
+// BuildGetPayloadResponse wraps the submitted execution payload in the matching fork's getPayload response
+func BuildGetPayloadResponse(payload *common.BuilderSubmitBlockRequest) (*common.GetPayloadResponse, error) {
+	if payload == nil {
+		return nil, ErrMissingRequest
+	}
+
+	if payload.Capella != nil {
+		return &common.GetPayloadResponse{
+			Capella: &common.GetPayloadResponseCapella{
+				Version: common.VersionCapella,
+				Data:    payload.Capella.ExecutionPayload,
+			},
+		}, nil
+	}
+
+	return &common.GetPayloadResponse{
+		Bellatrix: &types.GetPayloadResponse{
+			Version: common.VersionBellatrix,
+			Data:    payload.Bellatrix.ExecutionPayload,
+		},
+	}, nil
+}
+
 func BuilderSubmitBlockRequestToSignedBuilderBid(req *types.BuilderSubmitBlockRequest, sk *bls.SecretKey, pubkey *types.PublicKey, domain types.Domain) (*types.SignedBuilderBid, error) {
 	if req == nil {
 		return nil, ErrMissingRequest
@@ -54,7 +204,52 @@ func BuilderSubmitBlockRequestToSignedBuilderBid(req *types.BuilderSubmitBlockRe
 	}, nil
 }
 
-func SignedBlindedBeaconBlockToBeaconBlock(signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock, executionPayload *types.ExecutionPayload) *types.SignedBeaconBlock {
+func BuilderSubmitBlockRequestCapellaToSignedBuilderBid(req *common.BuilderSubmitBlockRequestCapella, sk *bls.SecretKey, pubkey *types.PublicKey, domain types.Domain) (*common.SignedBuilderBidCapella, error) {
+	if req == nil {
+		return nil, ErrMissingRequest
+	}
+
+	if sk == nil {
+		return nil, ErrMissingSecretKey
+	}
+
+	header, err := common.CapellaPayloadToPayloadHeader(req.ExecutionPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	builderBid := common.BuilderBidCapella{
+		Value:  req.Message.Value,
+		Header: header,
+		Pubkey: *pubkey,
+	}
+
+	sig, err := types.SignMessage(&builderBid, domain, sk)
+	if err != nil {
+		return nil, err
+	}
+
+	return &common.SignedBuilderBidCapella{
+		Message:   &builderBid,
+		Signature: sig,
+	}, nil
+}
+
+func SignedBlindedBeaconBlockToBeaconBlock(signedBlindedBeaconBlock *common.SignedBlindedBeaconBlock, getPayloadResponse *common.GetPayloadResponse) (*common.SignedBeaconBlock, error) {
+	if signedBlindedBeaconBlock.Capella != nil {
+		if getPayloadResponse.Capella == nil {
+			return nil, fmt.Errorf("%w: %s payload for capella block", common.ErrUnsupportedVersion, getPayloadResponse.Version())
+		}
+		return &common.SignedBeaconBlock{Capella: capellaBlindedBlockToBeaconBlock(signedBlindedBeaconBlock.Capella, getPayloadResponse.Capella.Data)}, nil
+	}
+
+	if getPayloadResponse.Bellatrix == nil {
+		return nil, fmt.Errorf("%w: %s payload for bellatrix block", common.ErrUnsupportedVersion, getPayloadResponse.Version())
+	}
+	return &common.SignedBeaconBlock{Bellatrix: bellatrixBlindedBlockToBeaconBlock(signedBlindedBeaconBlock.Bellatrix, getPayloadResponse.Bellatrix.Data)}, nil
+}
+
+func bellatrixBlindedBlockToBeaconBlock(signedBlindedBeaconBlock *types.SignedBlindedBeaconBlock, executionPayload *types.ExecutionPayload) *types.SignedBeaconBlock {
 	return &types.SignedBeaconBlock{
 		Signature: signedBlindedBeaconBlock.Signature,
 		Message: &types.BeaconBlock{
@@ -78,7 +273,52 @@ func SignedBlindedBeaconBlockToBeaconBlock(signedBlindedBeaconBlock *types.Signe
 	}
 }
 
+func capellaBlindedBlockToBeaconBlock(signedBlindedBeaconBlock *apiv1capella.SignedBlindedBeaconBlock, executionPayload *capella.ExecutionPayload) *capella.SignedBeaconBlock {
+	return &capella.SignedBeaconBlock{
+		Signature: signedBlindedBeaconBlock.Signature,
+		Message: &capella.BeaconBlock{
+			Slot:          signedBlindedBeaconBlock.Message.Slot,
+			ProposerIndex: signedBlindedBeaconBlock.Message.ProposerIndex,
+			ParentRoot:    signedBlindedBeaconBlock.Message.ParentRoot,
+			StateRoot:     signedBlindedBeaconBlock.Message.StateRoot,
+			Body: &capella.BeaconBlockBody{
+				RANDAOReveal:          signedBlindedBeaconBlock.Message.Body.RANDAOReveal,
+				ETH1Data:              signedBlindedBeaconBlock.Message.Body.ETH1Data,
+				Graffiti:              signedBlindedBeaconBlock.Message.Body.Graffiti,
+				ProposerSlashings:     signedBlindedBeaconBlock.Message.Body.ProposerSlashings,
+				AttesterSlashings:     signedBlindedBeaconBlock.Message.Body.AttesterSlashings,
+				Attestations:          signedBlindedBeaconBlock.Message.Body.Attestations,
+				Deposits:              signedBlindedBeaconBlock.Message.Body.Deposits,
+				VoluntaryExits:        signedBlindedBeaconBlock.Message.Body.VoluntaryExits,
+				SyncAggregate:         signedBlindedBeaconBlock.Message.Body.SyncAggregate,
+				ExecutionPayload:      executionPayload,
+				BLSToExecutionChanges: signedBlindedBeaconBlock.Message.Body.BLSToExecutionChanges,
+			},
+		},
+	}
+}
+
 type BuilderBlockValidationRequest struct {
-	types.BuilderSubmitBlockRequest
-	RegisteredGasLimit uint64 `json:"registered_gas_limit,string"`
+	BuilderSubmitBlockRequest *common.BuilderSubmitBlockRequest
+	RegisteredGasLimit        uint64 `json:"registered_gas_limit,string"`
+}
+
+// MarshalJSON flattens the fork-specific submission and the registered gas limit into a single object
+func (r *BuilderBlockValidationRequest) MarshalJSON() ([]byte, error) {
+	blockRequest, err := r.BuilderSubmitBlockRequest.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	gasLimit, err := json.Marshal(&struct {
+		RegisteredGasLimit uint64 `json:"registered_gas_limit,string"`
+	}{
+		RegisteredGasLimit: r.RegisteredGasLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gasLimit[0] = ','
+	return append(blockRequest[:len(blockRequest)-1], gasLimit...), nil
 }
@@ -1,11 +1,16 @@
 package api
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/flashbots/go-boost-utils/bls"
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/stretchr/testify/require"
 )
 
@@ -59,3 +64,80 @@ func TestBuilderBlockRequestToSignedBuilderBid(t *testing.T) {
 	require.Equal(t, 0, signedBuilderBid.Message.Value.Cmp(&reqPayload.Message.Value))
 	require.Equal(t, reqPayload.Message.BlockHash, signedBuilderBid.Message.Header.BlockHash)
 }
+
+func TestBuildGetHeaderResponseCapella(t *testing.T) {
+	builderPk, err := types.HexToPubkey("0xf9716c94aab536227804e859d15207aa7eaaacd839f39dcbdb5adc942842a8d2fb730f9f49fc719fdb86f1873e0ed1c2")
+	require.NoError(t, err)
+
+	reqPayload := &common.BuilderSubmitBlockRequest{
+		Capella: &common.BuilderSubmitBlockRequestCapella{
+			ExecutionPayload: &capella.ExecutionPayload{
+				ParentHash:   phase0.Hash32{0x01},
+				BlockNumber:  5001,
+				GasLimit:     5002,
+				GasUsed:      5003,
+				Timestamp:    5004,
+				ExtraData:    []byte{0x07},
+				BlockHash:    phase0.Hash32{0x09},
+				Transactions: []bellatrix.Transaction{{0x01, 0x02}},
+				Withdrawals: []*capella.Withdrawal{
+					{Index: 1, ValidatorIndex: 2, Address: bellatrix.ExecutionAddress{0x03}, Amount: 4},
+				},
+			},
+			Message: &types.BidTrace{
+				Slot:          1,
+				ParentHash:    types.Hash{0x01},
+				BlockHash:     types.Hash{0x09},
+				BuilderPubkey: builderPk,
+				Value:         types.IntToU256(123),
+			},
+		},
+	}
+
+	sk, _, err := bls.GenerateNewKeypair()
+	require.NoError(t, err)
+
+	publicKey, err := types.BlsPublicKeyToPublicKey(bls.PublicKeyFromSecretKey(sk))
+	require.NoError(t, err)
+
+	getHeaderResponse, err := BuildGetHeaderResponse(reqPayload, sk, &publicKey, builderSigningDomain)
+	require.NoError(t, err)
+	require.Nil(t, getHeaderResponse.Bellatrix)
+	require.Equal(t, common.VersionCapella, getHeaderResponse.Version())
+
+	bid := getHeaderResponse.Capella.Data
+	require.Equal(t, reqPayload.BlockHash(), types.Hash(bid.Message.Header.BlockHash))
+
+	withdrawalsRoot, err := common.ComputeWithdrawalsRoot(reqPayload.Capella.ExecutionPayload.Withdrawals)
+	require.NoError(t, err)
+	require.Equal(t, withdrawalsRoot, bid.Message.Header.WithdrawalsRoot)
+
+	ok, err := types.VerifySignature(bid.Message, builderSigningDomain, publicKey[:], bid.Signature[:])
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestBuilderBlockValidationRequestJSON(t *testing.T) {
+	validationRequest := &BuilderBlockValidationRequest{
+		BuilderSubmitBlockRequest: &common.BuilderSubmitBlockRequest{
+			Capella: &common.BuilderSubmitBlockRequestCapella{
+				Message:          &types.BidTrace{Slot: 1},
+				ExecutionPayload: &capella.ExecutionPayload{Transactions: []bellatrix.Transaction{}, Withdrawals: []*capella.Withdrawal{}},
+			},
+		},
+		RegisteredGasLimit: 30000000,
+	}
+
+	encoded, err := json.Marshal(validationRequest)
+	require.NoError(t, err)
+
+	decoded := new(struct {
+		Message            *types.BidTrace           `json:"message"`
+		ExecutionPayload   *capella.ExecutionPayload `json:"execution_payload"`
+		RegisteredGasLimit uint64                    `json:"registered_gas_limit,string"`
+	})
+	require.NoError(t, json.Unmarshal(encoded, decoded))
+	require.Equal(t, uint64(1), decoded.Message.Slot)
+	require.NotNil(t, decoded.ExecutionPayload)
+	require.Equal(t, uint64(30000000), decoded.RegisteredGasLimit)
+}
@@ -1,29 +1,105 @@
 package api
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/buger/jsonparser"
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/metrics"
+	"github.com/flashbots/mev-boost-relay/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	ErrBlockHashMismatch  = errors.New("blockHash mismatch")
-	ErrParentHashMismatch = errors.New("parentHash mismatch")
+	ErrBlockHashMismatch       = errors.New("blockHash mismatch")
+	ErrParentHashMismatch      = errors.New("parentHash mismatch")
+	ErrWithdrawalsUnknown      = errors.New("withdrawals are not known yet")
+	ErrWithdrawalsRootMismatch = errors.New("incorrect withdrawals root")
 )
 
-func SanityCheckBuilderBlockSubmission(payload *types.BuilderSubmitBlockRequest) error {
-	if payload.Message.BlockHash != payload.ExecutionPayload.BlockHash {
+func SanityCheckBuilderBlockSubmission(payload *common.BuilderSubmitBlockRequest) error {
+	if payload.Message().BlockHash != payload.BlockHash() {
 		return ErrBlockHashMismatch
 	}
 
-	if payload.Message.ParentHash != payload.ExecutionPayload.ParentHash {
+	if payload.Message().ParentHash != payload.ParentHash() {
 		return ErrParentHashMismatch
 	}
 
 	return nil
 }
 
+// checkSubmissionWithdrawals compares the withdrawals root of the payload with the root expected for its slot
+func checkSubmissionWithdrawals(payload *common.BuilderSubmitBlockRequest, expected withdrawalsHelper) error {
+	if expected.slot != payload.Slot() {
+		return ErrWithdrawalsUnknown
+	}
+
+	withdrawalsRoot, err := common.ComputeWithdrawalsRoot(payload.Withdrawals())
+	if err != nil {
+		return err
+	}
+
+	if withdrawalsRoot != expected.root {
+		return fmt.Errorf("%w - got: %s, expected: %s", ErrWithdrawalsRootMismatch, withdrawalsRoot.String(), expected.root.String())
+	}
+
+	return nil
+}
+
+// getSlotFromJSON returns the message slot of a JSON encoded submission or signed block, without decoding the rest
+func getSlotFromJSON(body []byte) (uint64, error) {
+	slotStr, err := jsonparser.GetString(body, "message", "slot")
+	if err != nil {
+		return 0, common.ErrEmptyPayload
+	}
+	return strconv.ParseUint(slotStr, 10, 64)
+}
+
 func checkBLSPublicKeyHex(pkHex string) error {
 	var proposerPubkey types.PublicKey
 	return proposerPubkey.UnmarshalText([]byte(pkHex))
 }
+
+// submissionStages times the consecutive processing stages of a block submission, as metrics and as trace spans
+type submissionStages struct {
+	ctx       context.Context
+	startedAt time.Time
+	name      string
+	span      trace.Span
+}
+
+// start completes the current stage and starts the next one, returning the context of its span
+func (s *submissionStages) start(stage string) context.Context {
+	s.complete()
+
+	var ctx context.Context
+	s.name = stage
+	s.startedAt = time.Now().UTC()
+	ctx, s.span = tracing.Tracer.Start(s.ctx, stage)
+	return ctx
+}
+
+// complete records the duration of the current stage and ends its span
+func (s *submissionStages) complete() {
+	if s.span == nil {
+		return
+	}
+	metrics.SubmitNewBlockStageDuration.WithLabelValues(s.name).Observe(time.Since(s.startedAt).Seconds())
+	s.span.End()
+	s.span = nil
+}
+
+// abort ends the span of a stage which did not complete, without recording its duration
+func (s *submissionStages) abort() {
+	if s.span == nil {
+		return
+	}
+	s.span.End()
+	s.span = nil
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSubmissionWithdrawals(t *testing.T) {
+	withdrawals := []*capella.Withdrawal{
+		{Index: 1, ValidatorIndex: 2, Address: bellatrix.ExecutionAddress{0x03}, Amount: 4},
+	}
+	withdrawalsRoot, err := common.ComputeWithdrawalsRoot(withdrawals)
+	require.NoError(t, err)
+
+	payload := &common.BuilderSubmitBlockRequest{
+		Capella: &common.BuilderSubmitBlockRequestCapella{
+			Message:          &types.BidTrace{Slot: 10},
+			ExecutionPayload: &capella.ExecutionPayload{Withdrawals: withdrawals},
+		},
+	}
+
+	// matching root
+	err = checkSubmissionWithdrawals(payload, withdrawalsHelper{slot: 10, root: withdrawalsRoot})
+	require.NoError(t, err)
+
+	// withdrawals for a different slot
+	err = checkSubmissionWithdrawals(payload, withdrawalsHelper{slot: 9, root: withdrawalsRoot})
+	require.ErrorIs(t, err, ErrWithdrawalsUnknown)
+
+	// wrong root
+	emptyRoot, err := common.ComputeWithdrawalsRoot(nil)
+	require.NoError(t, err)
+	err = checkSubmissionWithdrawals(payload, withdrawalsHelper{slot: 10, root: emptyRoot})
+	require.ErrorIs(t, err, ErrWithdrawalsRootMismatch)
+}
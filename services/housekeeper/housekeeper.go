@@ -4,6 +4,7 @@
 // - Updating proposer duties
 // - Saving metrics
 // - Deleting old bids
+// - Updating the stats rollups
 // - ...
 package housekeeper
 
@@ -15,10 +16,12 @@ import (
 	"time"
 
 	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/go-utils/cli"
 	"github.com/flashbots/mev-boost-relay/beaconclient"
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
 	"github.com/flashbots/mev-boost-relay/datastore"
+	"github.com/flashbots/mev-boost-relay/metrics"
 	"github.com/sirupsen/logrus"
 	uberatomic "go.uber.org/atomic"
 )
@@ -44,19 +47,31 @@ type Housekeeper struct {
 
 	headSlot uberatomic.Uint64
 
-	proposersAlreadySaved map[string]bool // to avoid repeating redis writes
+	knownValidators map[types.PubkeyHex]knownValidator // to track status changes, and to avoid repeating redis writes
+}
+
+// knownValidator is the beacon chain state of a validator, as last saved in Redis
+type knownValidator struct {
+	status                string
+	withdrawalCredentials string
 }
 
 var ErrServerAlreadyStarted = errors.New("server was already started")
 
+// changes of the known validators up to this size are published to the API instances as changelog entry
+var maxKnownValidatorsChangelogEntry = cli.GetEnvInt("KNOWN_VALIDATORS_MAX_CHANGELOG_ENTRY", 10000)
+
+// how often the stats rollups of the current and previous day are recomputed
+var statsRollupInterval = time.Duration(cli.GetEnvInt("STATS_ROLLUP_INTERVAL_SEC", 300)) * time.Second
+
 func NewHousekeeper(opts *HousekeeperOpts) *Housekeeper {
 	server := &Housekeeper{
-		opts:                  opts,
-		log:                   opts.Log,
-		redis:                 opts.Redis,
-		db:                    opts.DB,
-		beaconClient:          opts.BeaconClient,
-		proposersAlreadySaved: make(map[string]bool),
+		opts:            opts,
+		log:             opts.Log,
+		redis:           opts.Redis,
+		db:              opts.DB,
+		beaconClient:    opts.BeaconClient,
+		knownValidators: make(map[types.PubkeyHex]knownValidator),
 	}
 
 	return server
@@ -83,6 +98,7 @@ func (hk *Housekeeper) Start() (err error) {
 	go hk.periodicTaskUpdateKnownValidators()
 	go hk.periodicTaskLogValidators()
 	go hk.periodicTaskUpdateBuilderStatusInRedis()
+	go hk.periodicTaskUpdateStatsRollups()
 
 	// Process the current slot
 	headSlot := bestSyncStatus.HeadSlot
@@ -135,6 +151,61 @@ func (hk *Housekeeper) periodicTaskUpdateBuilderStatusInRedis() {
 	}
 }
 
+// periodicTaskUpdateStatsRollups keeps the stats rollups of the data API up to date, after backfilling the missing
+// days once
+func (hk *Housekeeper) periodicTaskUpdateStatsRollups() {
+	hk.backfillStatsRollups(time.Now().UTC().Truncate(24 * time.Hour))
+	for {
+		hk.updateStatsRollups(time.Now().UTC().Truncate(24 * time.Hour))
+		time.Sleep(statsRollupInterval)
+	}
+}
+
+// updateStatsRollups recomputes the stats rollups of today and the previous day, so that it's complete after
+// midnight (UTC)
+func (hk *Housekeeper) updateStatsRollups(today time.Time) {
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		hk.updateStatsRollupsOfDay(day)
+	}
+}
+
+// backfillStatsRollups computes the stats rollups of the days before today which have none, up to
+// database.StatsMaxNumDays back, e.g. after the relay was updated or the housekeeper was down for a while
+func (hk *Housekeeper) backfillStatsRollups(today time.Time) {
+	fromDay := today.AddDate(0, 0, -(database.StatsMaxNumDays - 1))
+	entries, err := hk.db.GetDailyStats(fromDay, today)
+	if err != nil {
+		hk.log.WithError(err).Error("failed to get stats rollups to backfill")
+		return
+	}
+
+	days := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		days[entry.Day.UTC().Format("2006-01-02")] = true
+	}
+
+	numBackfilled := 0
+	for day := fromDay; day.Before(today); day = day.AddDate(0, 0, 1) {
+		if !days[day.Format("2006-01-02")] {
+			hk.updateStatsRollupsOfDay(day)
+			numBackfilled++
+		}
+	}
+	if numBackfilled > 0 {
+		hk.log.WithField("numDays", numBackfilled).Info("backfilled stats rollups")
+	}
+}
+
+func (hk *Housekeeper) updateStatsRollupsOfDay(day time.Time) {
+	log := hk.log.WithField("day", day.Format("2006-01-02"))
+	timeStart := time.Now()
+	if err := hk.db.UpdateStatsRollups(day); err != nil {
+		log.WithError(err).Error("failed to update stats rollups")
+		return
+	}
+	log.WithField("durationMs", time.Since(timeStart).Milliseconds()).Debug("updated stats rollups")
+}
+
 func (hk *Housekeeper) processNewSlot(headSlot uint64) {
 	prevHeadSlot := hk.headSlot.Load()
 	if headSlot <= prevHeadSlot {
@@ -183,45 +254,139 @@ func (hk *Housekeeper) updateKnownValidators() {
 	log := hk.log.WithField("numKnownValidators", numValidators)
 	log.WithField("durationFetchValidators", time.Since(timeStartFetching).Seconds()).Infof("received validators from beacon-node")
 
+	// An empty validator set is a beacon node failure, and would remove all known validators
+	if numValidators == 0 {
+		log.Error("beacon node returned no validators, not updating known validators")
+		return
+	}
+
 	// Store total number of validators
 	err = hk.redis.SetStats(datastore.RedisStatsFieldValidatorsTotal, fmt.Sprint(numValidators))
 	if err != nil {
 		log.WithError(err).Error("failed to set stats for RedisStatsFieldValidatorsTotal")
 	}
 
-	// Update Redis with validators
+	// Update Redis with validators, skipping the ones already saved in previous rounds, and track status changes
+	unsavedValidators := make(map[types.PubkeyHex]uint64)
+	changedWithdrawalCredentials := make(map[types.PubkeyHex]string)
+	statusChanges := make(map[string]int)
+	numValidatorsByStatus := make(map[string]int)
+	for pubkey, validator := range validators {
+		numValidatorsByStatus[validator.Status]++
+		if validator.IsSlashed() {
+			continue // removed below
+		}
+		known, isSaved := hk.knownValidators[pubkey]
+		if !isSaved {
+			unsavedValidators[pubkey] = validator.Index
+		} else if known.status != validator.Status {
+			statusChanges[known.status+" -> "+validator.Status]++
+			known.status = validator.Status
+			hk.knownValidators[pubkey] = known
+		}
+		if known.withdrawalCredentials != validator.Validator.WithdrawalCredentials {
+			changedWithdrawalCredentials[pubkey] = validator.Validator.WithdrawalCredentials
+		}
+	}
+	metrics.KnownValidators.Reset()
+	for status, num := range numValidatorsByStatus {
+		metrics.KnownValidators.WithLabelValues(status).Set(float64(num))
+	}
+	if len(statusChanges) > 0 {
+		log.WithField("statusChanges", statusChanges).Info("validator status changes")
+	}
+
+	// The beacon node only returns active and pending validators, so all other known ones exited. Slashed validators
+	// are removed as well, while they are still active until their exit.
+	exitedValidators, err := hk.getExitedValidators(validators)
+	if err != nil {
+		log.WithError(err).Error("failed to get known validators from Redis")
+		return
+	}
+
 	log.Debug("Writing to Redis...")
 	timeStartWriting := time.Now()
 
-	printCounter := len(hk.proposersAlreadySaved) == 0 // only on first round
-	i := 0
-	newValidators := 0
-	for _, validator := range validators {
-		i++
-		if printCounter && i%10000 == 0 {
-			hk.log.Debugf("writing to redis: %d / %d", i, numValidators)
-		}
+	newValidators, removedValidators, err := hk.writeKnownValidatorsChange(unsavedValidators, exitedValidators)
+	if err != nil {
+		log.WithError(err).Error("failed to update known validators in Redis")
+		return
+	}
 
-		// avoid resaving
-		if hk.proposersAlreadySaved[validator.Validator.Pubkey] {
-			continue
-		}
+	for pubkey := range unsavedValidators {
+		hk.knownValidators[pubkey] = knownValidator{status: validators[pubkey].Status}
+	}
+	for _, pubkey := range exitedValidators {
+		delete(hk.knownValidators, pubkey)
+	}
+	metrics.KnownValidatorsRemoved.Add(float64(removedValidators))
 
-		err := hk.redis.SetKnownValidatorNX(types.PubkeyHex(validator.Validator.Pubkey), validator.Index)
-		if err != nil {
-			log.WithError(err).WithField("pubkey", validator.Validator.Pubkey).Error("failed to set known validator in Redis")
-		} else {
-			hk.proposersAlreadySaved[validator.Validator.Pubkey] = true
-			newValidators++
+	// Withdrawal credentials are used by the registration policy, and change with BLS-to-execution changes
+	err = hk.redis.UpdateValidatorWithdrawalCredentials(changedWithdrawalCredentials, exitedValidators)
+	if err != nil {
+		log.WithError(err).Error("failed to update validator withdrawal credentials in Redis")
+	} else {
+		for pubkey, withdrawalCredentials := range changedWithdrawalCredentials {
+			known := hk.knownValidators[pubkey]
+			known.withdrawalCredentials = withdrawalCredentials
+			hk.knownValidators[pubkey] = known
 		}
 	}
 
 	log.WithFields(logrus.Fields{
 		"durationRedisWrite": time.Since(timeStartWriting).Seconds(),
 		"newValidators":      newValidators,
+		"removedValidators":  removedValidators,
 	}).Info("updateKnownValidators done")
 }
 
+// getExitedValidators returns the known validators in Redis which are not in the current validator set, or were slashed
+func (hk *Housekeeper) getExitedValidators(validators map[types.PubkeyHex]beaconclient.ValidatorResponseEntry) ([]types.PubkeyHex, error) {
+	knownValidators, err := hk.redis.GetKnownValidators()
+	if err != nil {
+		return nil, err
+	}
+
+	exitedValidators := []types.PubkeyHex{}
+	for pubkey := range knownValidators {
+		if validator, isCurrent := validators[pubkey]; !isCurrent || validator.IsSlashed() {
+			exitedValidators = append(exitedValidators, pubkey)
+		}
+	}
+	return exitedValidators, nil
+}
+
+// writeKnownValidatorsChange saves the added and removed known validators in Redis. Small changes are saved together
+// with a changelog entry, which the API instances apply incrementally. Large changes (i.e. on the first run) are written
+// in batches, and make the API instances reload all known validators.
+func (hk *Housekeeper) writeKnownValidatorsChange(added map[types.PubkeyHex]uint64, removed []types.PubkeyHex) (numAdded, numRemoved int, err error) {
+	numChanges := len(added) + len(removed)
+	if numChanges == 0 {
+		return 0, 0, nil
+	} else if numChanges <= maxKnownValidatorsChangelogEntry {
+		_, numAdded, numRemoved, err = hk.redis.UpdateKnownValidators(&datastore.KnownValidatorsChange{Added: added, Removed: removed})
+		return numAdded, numRemoved, err
+	}
+
+	printCounter := len(hk.knownValidators) == 0 // only on first round
+	numAdded, err = hk.redis.SetKnownValidators(added, func(numDone int) {
+		if printCounter {
+			hk.log.Debugf("writing to redis: %d / %d", numDone, len(added))
+		}
+	})
+	if err != nil {
+		return numAdded, 0, err
+	}
+
+	numRemoved, err = hk.redis.RemoveKnownValidators(removed)
+	if err != nil {
+		return numAdded, numRemoved, err
+	}
+
+	_, err = hk.redis.BumpKnownValidatorsVersion()
+	return numAdded, numRemoved, err
+}
+
 func (hk *Housekeeper) updateProposerDuties(headSlot uint64) {
 	// Should only happen once at a time
 	if hk.isUpdatingProposerDuties.Swap(true) {
@@ -319,14 +484,19 @@ func (hk *Housekeeper) updateValidatorRegistrationsInRedis() {
 	hk.log.Infof("updating %d validator registrations in Redis...", len(regs))
 	timeStarted := time.Now()
 
+	timestamps := make(map[types.PubkeyHex]uint64, len(regs))
 	for _, reg := range regs {
-		err = hk.redis.SetValidatorRegistrationTimestampIfNewer(types.PubkeyHex(reg.Pubkey), reg.Timestamp)
-		if err != nil {
-			hk.log.WithError(err).Error("failed to set validator registration")
-			continue
-		}
+		timestamps[types.PubkeyHex(reg.Pubkey)] = reg.Timestamp
+	}
+
+	numUpdated, err := hk.redis.SetValidatorRegistrationTimestampsIfNewer(timestamps, func(numDone int) {
+		hk.log.Debugf("updating validator registrations in Redis: %d / %d", numDone, len(timestamps))
+	})
+	if err != nil {
+		hk.log.WithError(err).Error("failed to set validator registrations")
+		return
 	}
-	hk.log.Infof("updating %d validator registrations in Redis done - %f sec", len(regs), time.Since(timeStarted).Seconds())
+	hk.log.Infof("updating %d validator registrations in Redis done (%d updated) - %f sec", len(regs), numUpdated, time.Since(timeStarted).Seconds())
 }
 
 func (hk *Housekeeper) updateBlockBuildersInRedis() {
@@ -344,5 +514,10 @@ func (hk *Housekeeper) updateBlockBuildersInRedis() {
 		if err != nil {
 			hk.log.WithError(err).Error("failed to set block builder status in redis")
 		}
+
+		err = hk.redis.SetBlockBuilderCollateral(builder.BuilderPubkey, builder.Collateral)
+		if err != nil {
+			hk.log.WithError(err).Error("failed to set block builder collateral in redis")
+		}
 	}
 }
@@ -0,0 +1,123 @@
+package housekeeper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/flashbots/go-boost-utils/types"
+	"github.com/flashbots/mev-boost-relay/beaconclient"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/flashbots/mev-boost-relay/database"
+	"github.com/flashbots/mev-boost-relay/datastore"
+	"github.com/stretchr/testify/require"
+)
+
+// statsRollupsDB records the days of the stats rollup updates, and has daily stats for the given days
+type statsRollupsDB struct {
+	database.MockDB
+	days        []time.Time
+	updatedDays []time.Time
+}
+
+func (db *statsRollupsDB) UpdateStatsRollups(day time.Time) error {
+	db.updatedDays = append(db.updatedDays, day)
+	return nil
+}
+
+func (db *statsRollupsDB) GetDailyStats(fromDay, toDay time.Time) ([]*database.DailyStatsEntry, error) {
+	entries := []*database.DailyStatsEntry{}
+	for _, day := range db.days {
+		if !day.Before(fromDay) && !day.After(toDay) {
+			entries = append(entries, &database.DailyStatsEntry{Day: day})
+		}
+	}
+	return entries, nil
+}
+
+func newTestHousekeeper(db database.IDatabaseService) *Housekeeper {
+	return NewHousekeeper(&HousekeeperOpts{Log: common.TestLog, DB: db}) //nolint:exhaustruct
+}
+
+func TestUpdateStatsRollups(t *testing.T) {
+	db := &statsRollupsDB{}
+	today := time.Date(2023, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	// the previous day is completed after midnight
+	newTestHousekeeper(db).updateStatsRollups(today)
+	require.Equal(t, []time.Time{today.AddDate(0, 0, -1), today}, db.updatedDays)
+}
+
+func TestBackfillStatsRollups(t *testing.T) {
+	today := time.Date(2023, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	t.Run("missing days", func(t *testing.T) {
+		db := &statsRollupsDB{}
+		for i := 1; i < database.StatsMaxNumDays; i++ {
+			if i != 3 && i != 200 {
+				db.days = append(db.days, today.AddDate(0, 0, -i))
+			}
+		}
+		newTestHousekeeper(db).backfillStatsRollups(today)
+		require.Equal(t, []time.Time{today.AddDate(0, 0, -200), today.AddDate(0, 0, -3)}, db.updatedDays)
+	})
+
+	t.Run("at most StatsMaxNumDays back", func(t *testing.T) {
+		db := &statsRollupsDB{}
+		newTestHousekeeper(db).backfillStatsRollups(today)
+		require.Len(t, db.updatedDays, database.StatsMaxNumDays-1)
+		require.Equal(t, today.AddDate(0, 0, -(database.StatsMaxNumDays-1)), db.updatedDays[0])
+		require.Equal(t, today.AddDate(0, 0, -1), db.updatedDays[len(db.updatedDays)-1])
+	})
+}
+
+func TestUpdateKnownValidatorsSlashed(t *testing.T) {
+	redisTestServer, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(redisTestServer.Close)
+	redis, err := datastore.NewRedisCache(redisTestServer.Addr(), "")
+	require.NoError(t, err)
+
+	beaconInstance := beaconclient.NewMockBeaconInstance()
+	hk := NewHousekeeper(&HousekeeperOpts{ //nolint:exhaustruct
+		Log:          common.TestLog,
+		Redis:        redis,
+		DB:           database.MockDB{},
+		BeaconClient: beaconclient.NewMultiBeaconClient(common.TestLog, []beaconclient.IBeaconInstance{beaconInstance}),
+	})
+	hk.headSlot.Store(10)
+
+	pubkeys := []string{
+		"0x8a1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca241",
+		"0x8a1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca242",
+		"0x8a1d7b8dd64e0aafe7ea7b6c95065c9364cf99d38470c12ee807d55f7de1529ad29ce2c422e0b65e3d5a05c02caca243",
+	}
+	setValidators := func(statuses []string, slashed []bool) {
+		validators := make(map[types.PubkeyHex]beaconclient.ValidatorResponseEntry)
+		for i, pubkey := range pubkeys {
+			validators[types.PubkeyHex(pubkey)] = beaconclient.ValidatorResponseEntry{ //nolint:exhaustruct
+				Index:     uint64(i),
+				Status:    statuses[i],
+				Validator: beaconclient.ValidatorResponseValidatorData{Pubkey: pubkey, Slashed: slashed[i]}, //nolint:exhaustruct
+			}
+		}
+		beaconInstance.SetValidators(validators)
+	}
+
+	// a slashed validator which is still active is not added
+	setValidators([]string{"active_ongoing", "active_ongoing", "active_slashed"}, []bool{false, false, true})
+	hk.updateKnownValidators()
+	known, err := redis.GetKnownValidators()
+	require.NoError(t, err)
+	require.Len(t, known, 2)
+	require.NotContains(t, known, types.PubkeyHex(pubkeys[2]))
+
+	// a known validator is removed once it's slashed, while it's still active
+	setValidators([]string{"active_ongoing", "active_slashed", "active_slashed"}, []bool{false, true, true})
+	hk.updateKnownValidators()
+	known, err = redis.GetKnownValidators()
+	require.NoError(t, err)
+	require.Len(t, known, 1)
+	require.Contains(t, known, types.PubkeyHex(pubkeys[0]))
+	require.NotContains(t, hk.knownValidators, types.PubkeyHex(pubkeys[1]))
+}
@@ -20,19 +20,21 @@ var (
 )
 
 type StatusHTMLData struct {
-	Network                     string
-	RelayPubkey                 string
-	ValidatorsTotal             uint64
-	ValidatorsRegistered        uint64
-	ValidatorsActive            uint64
-	BellatrixForkVersion        string
-	GenesisForkVersion          string
-	GenesisValidatorsRoot       string
-	BuilderSigningDomain        string
-	BeaconProposerSigningDomain string
-	HeadSlot                    uint64
-	NumPayloadsDelivered        uint64
-	Payloads                    []*database.DeliveredPayloadEntry
+	Network                            string
+	RelayPubkey                        string
+	ValidatorsTotal                    uint64
+	ValidatorsRegistered               uint64
+	ValidatorsActive                   uint64
+	BellatrixForkVersion               string
+	CapellaForkVersion                 string
+	GenesisForkVersion                 string
+	GenesisValidatorsRoot              string
+	BuilderSigningDomain               string
+	BeaconProposerSigningDomain        string
+	BeaconProposerSigningDomainCapella string
+	HeadSlot                           uint64
+	NumPayloadsDelivered               uint64
+	Payloads                           []*database.DeliveredPayloadEntry
 
 	ValueLink      string
 	ValueOrderIcon string
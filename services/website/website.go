@@ -16,6 +16,7 @@ import (
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/flashbots/mev-boost-relay/database"
 	"github.com/flashbots/mev-boost-relay/datastore"
+	"github.com/flashbots/mev-boost-relay/metrics"
 	"github.com/go-redis/redis/v9"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -87,25 +88,27 @@ func NewWebserver(opts *WebserverOpts) (*Webserver, error) {
 	}
 
 	server.statusHTMLData = StatusHTMLData{
-		Network:                     opts.NetworkDetails.Name,
-		RelayPubkey:                 opts.RelayPubkeyHex,
-		ValidatorsActive:            0,
-		ValidatorsTotal:             0,
-		ValidatorsRegistered:        0,
-		BellatrixForkVersion:        opts.NetworkDetails.BellatrixForkVersionHex,
-		GenesisForkVersion:          opts.NetworkDetails.GenesisForkVersionHex,
-		GenesisValidatorsRoot:       opts.NetworkDetails.GenesisValidatorsRootHex,
-		BuilderSigningDomain:        hexutil.Encode(opts.NetworkDetails.DomainBuilder[:]),
-		BeaconProposerSigningDomain: hexutil.Encode(opts.NetworkDetails.DomainBeaconProposer[:]),
-		HeadSlot:                    0,
-		NumPayloadsDelivered:        0,
-		Payloads:                    []*database.DeliveredPayloadEntry{},
-		ValueLink:                   "",
-		ValueOrderIcon:              "",
-		ShowConfigDetails:           opts.ShowConfigDetails,
-		LinkBeaconchain:             opts.LinkBeaconchain,
-		LinkEtherscan:               opts.LinkEtherscan,
-		RelayURL:                    opts.RelayURL,
+		Network:                            opts.NetworkDetails.Name,
+		RelayPubkey:                        opts.RelayPubkeyHex,
+		ValidatorsActive:                   0,
+		ValidatorsTotal:                    0,
+		ValidatorsRegistered:               0,
+		BellatrixForkVersion:               opts.NetworkDetails.BellatrixForkVersionHex,
+		CapellaForkVersion:                 opts.NetworkDetails.CapellaForkVersionHex,
+		GenesisForkVersion:                 opts.NetworkDetails.GenesisForkVersionHex,
+		GenesisValidatorsRoot:              opts.NetworkDetails.GenesisValidatorsRootHex,
+		BuilderSigningDomain:               hexutil.Encode(opts.NetworkDetails.DomainBuilder[:]),
+		BeaconProposerSigningDomain:        hexutil.Encode(opts.NetworkDetails.DomainBeaconProposerBellatrix[:]),
+		BeaconProposerSigningDomainCapella: hexutil.Encode(opts.NetworkDetails.DomainBeaconProposerCapella[:]),
+		HeadSlot:                           0,
+		NumPayloadsDelivered:               0,
+		Payloads:                           []*database.DeliveredPayloadEntry{},
+		ValueLink:                          "",
+		ValueOrderIcon:                     "",
+		ShowConfigDetails:                  opts.ShowConfigDetails,
+		LinkBeaconchain:                    opts.LinkBeaconchain,
+		LinkEtherscan:                      opts.LinkEtherscan,
+		RelayURL:                           opts.RelayURL,
 	}
 
 	return server, nil
@@ -144,6 +147,7 @@ func (srv *Webserver) StartServer() (err error) {
 func (srv *Webserver) getRouter() http.Handler {
 	r := mux.NewRouter()
 	r.HandleFunc("/", srv.handleRoot).Methods(http.MethodGet)
+	r.Use(metrics.HTTPMiddleware)
 	loggedRouter := httplogger.LoggingMiddlewareLogrus(srv.log, r)
 	withGz := gziphandler.GzipHandler(loggedRouter)
 	return withGz
@@ -0,0 +1,69 @@
+// Package tracing sets up optional OpenTelemetry tracing, exported via OTLP/HTTP
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrInvalidEndpointScheme = errors.New("invalid OTLP endpoint scheme, expected http or https")
+
+// Tracer creates the relay spans. Until Setup is called it uses the no-op global provider, so spans are free.
+var Tracer = otel.Tracer("github.com/flashbots/mev-boost-relay")
+
+// Setup installs a global tracer provider which exports spans to the OTLP/HTTP collector at endpoint
+// (i.e. http://localhost:4318), sampling sampleRatio of new traces. The returned function flushes and
+// stops the exporter.
+func Setup(ctx context.Context, serviceName, version, endpoint string, sampleRatio float64) (shutdown func(context.Context) error, err error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpointURL.Host)}
+	switch endpointURL.Scheme {
+	case "http":
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	case "https":
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEndpointScheme, endpoint)
+	}
+	if endpointURL.Path != "" && endpointURL.Path != "/" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithURLPath(endpointURL.Path))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// DetachedContext returns a context carrying the span of ctx, but not its cancellation, for work
+// which continues after a request is done
+func DetachedContext(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}